@@ -0,0 +1,397 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// AssembleError is returned by Assemble when a line of the source can't be
+// assembled.
+type AssembleError struct {
+	// The line number, starting at 1.
+	Line int
+
+	// What's wrong with the line.
+	Msg string
+}
+
+func (e *AssembleError) Error() string {
+	return fmt.Sprintf("chip8: line %d: %s", e.Line, e.Msg)
+}
+
+// Assemble assembles a program written with the same mnemonics that
+// Disassemble uses, for loading at 0x200. Each line holds one instruction,
+// and anything after a ; is a comment. Lines can start with a label, like
+// "loop:", which can be used in place of an address. The DB and DW
+// directives emit comma separated bytes and 16-bit words as is.
+//
+// Numbers can be decimal or hex with a 0x prefix.
+func Assemble(src string) ([]byte, error) {
+	lines := strings.Split(src, "\n")
+
+	// The first pass finds the address of each label.
+	labels := make(map[string]uint16)
+	addr := ProgramStart
+	stmts := make([]asmStmt, 0, len(lines))
+
+	for i, line := range lines {
+		s, err := parseAsmLine(i+1, line)
+		if err != nil {
+			return nil, err
+		}
+
+		if s.label != "" {
+			if _, ok := labels[s.label]; ok {
+				return nil, &AssembleError{s.line, fmt.Sprintf("duplicate label %q", s.label)}
+			}
+			labels[s.label] = uint16(addr)
+		}
+
+		switch s.mnemonic {
+		case "":
+			continue
+		case "DB":
+			addr += len(s.args)
+		case "DW":
+			addr += 2 * len(s.args)
+		default:
+			addr += 2
+		}
+
+		stmts = append(stmts, s)
+	}
+
+	// The second pass emits the bytes.
+	var p []byte
+	for _, s := range stmts {
+		a := &asmArgs{stmt: s, labels: labels}
+
+		switch s.mnemonic {
+		case "DB":
+			for i := range s.args {
+				b := a.number(i, 0xFF)
+				p = append(p, byte(b))
+			}
+		case "DW":
+			for i := range s.args {
+				w := a.number(i, 0xFFFF)
+				p = append(p, byte(w>>8), byte(w))
+			}
+		default:
+			op := a.encode()
+			p = append(p, byte(op>>8), byte(op))
+		}
+
+		if a.err != nil {
+			return nil, a.err
+		}
+	}
+
+	return p, nil
+}
+
+// asmStmt is a parsed line of source.
+type asmStmt struct {
+	line     int
+	label    string
+	mnemonic string
+	args     []string
+}
+
+// parseAsmLine splits a line of source into its label, mnemonic and
+// arguments.
+func parseAsmLine(n int, line string) (asmStmt, error) {
+	s := asmStmt{line: n}
+
+	if i := strings.Index(line, ";"); i >= 0 {
+		line = line[:i]
+	}
+	line = strings.TrimSpace(line)
+
+	if i := strings.Index(line, ":"); i >= 0 {
+		s.label = strings.TrimSpace(line[:i])
+		if !validLabel(s.label) {
+			return s, &AssembleError{n, fmt.Sprintf("invalid label %q", s.label)}
+		}
+		line = strings.TrimSpace(line[i+1:])
+	}
+
+	if line == "" {
+		return s, nil
+	}
+
+	fields := strings.SplitN(line, " ", 2)
+	s.mnemonic = strings.ToUpper(fields[0])
+
+	if len(fields) > 1 {
+		for _, arg := range strings.Split(fields[1], ",") {
+			s.args = append(s.args, strings.TrimSpace(arg))
+		}
+	}
+
+	return s, nil
+}
+
+// validLabel returns true if s can be used as a label.
+func validLabel(s string) bool {
+	if s == "" || (s[0] >= '0' && s[0] <= '9') {
+		return false
+	}
+
+	for _, r := range s {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+
+	// Registers would be ambiguous.
+	_, isReg := register(s)
+	return !isReg
+}
+
+// register parses a register name, like V0 or vf.
+func register(s string) (uint16, bool) {
+	if len(s) != 2 || (s[0] != 'V' && s[0] != 'v') {
+		return 0, false
+	}
+
+	v, err := strconv.ParseUint(s[1:], 16, 8)
+	if err != nil {
+		return 0, false
+	}
+
+	return uint16(v), true
+}
+
+// asmArgs encodes the arguments of a statement, keeping the first error.
+type asmArgs struct {
+	stmt   asmStmt
+	labels map[string]uint16
+	err    error
+}
+
+// fail records an error, if there isn't one already.
+func (a *asmArgs) fail(format string, args ...interface{}) {
+	if a.err == nil {
+		a.err = &AssembleError{a.stmt.line, fmt.Sprintf(format, args...)}
+	}
+}
+
+// arg returns argument i, upper cased.
+func (a *asmArgs) arg(i int) string {
+	return strings.ToUpper(a.stmt.args[i])
+}
+
+// is returns true if argument i is the given keyword, like DT or [I].
+func (a *asmArgs) is(i int, keyword string) bool {
+	return i < len(a.stmt.args) && a.arg(i) == keyword
+}
+
+// isReg returns true if argument i is a V register.
+func (a *asmArgs) isReg(i int) bool {
+	if i >= len(a.stmt.args) {
+		return false
+	}
+	_, ok := register(a.stmt.args[i])
+	return ok
+}
+
+// reg returns argument i as a V register.
+func (a *asmArgs) reg(i int) uint16 {
+	v, ok := register(a.stmt.args[i])
+	if !ok {
+		a.fail("%s: expected a register, got %q", a.stmt.mnemonic, a.stmt.args[i])
+	}
+	return v
+}
+
+// number returns argument i as a number no larger than max.
+func (a *asmArgs) number(i int, max uint64) uint16 {
+	s := a.stmt.args[i]
+
+	var (
+		v   uint64
+		err error
+	)
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		v, err = strconv.ParseUint(s[2:], 16, 16)
+	} else {
+		v, err = strconv.ParseUint(s, 10, 16)
+	}
+
+	if err != nil || v > max {
+		a.fail("%s: invalid value %q", a.stmt.mnemonic, s)
+	}
+
+	return uint16(v)
+}
+
+// addr returns argument i as an address, which can be a label.
+func (a *asmArgs) addr(i int) uint16 {
+	if addr, ok := a.labels[a.stmt.args[i]]; ok {
+		return addr
+	}
+	return a.number(i, 0xFFF)
+}
+
+// want checks the number of arguments.
+func (a *asmArgs) want(n int) bool {
+	if len(a.stmt.args) != n {
+		a.fail("%s: expected %d arguments, got %d", a.stmt.mnemonic, n, len(a.stmt.args))
+		return false
+	}
+	return true
+}
+
+// encode returns the opcode for an instruction.
+func (a *asmArgs) encode() uint16 {
+	m := a.stmt.mnemonic
+	n := len(a.stmt.args)
+
+	switch m {
+	case "CLS":
+		if a.want(0) {
+			return 0x00E0
+		}
+	case "RET":
+		if a.want(0) {
+			return 0x00EE
+		}
+	case "EXIT":
+		if a.want(0) {
+			return 0x00FD
+		}
+	case "SCR":
+		if a.want(0) {
+			return 0x00FB
+		}
+	case "SCL":
+		if a.want(0) {
+			return 0x00FC
+		}
+	case "SCD":
+		if a.want(1) {
+			return 0x00C0 | a.number(0, 0xF)
+		}
+	case "SYS":
+		if a.want(1) {
+			return a.addr(0)
+		}
+	case "JP":
+		if n == 2 && a.is(0, "V0") {
+			return 0xB000 | a.addr(1)
+		}
+		if a.want(1) {
+			return 0x1000 | a.addr(0)
+		}
+	case "CALL":
+		if a.want(1) {
+			return 0x2000 | a.addr(0)
+		}
+	case "SE", "SNE":
+		if !a.want(2) {
+			break
+		}
+		if a.isReg(1) {
+			base := uint16(0x5000)
+			if m == "SNE" {
+				base = 0x9000
+			}
+			return base | a.reg(0)<<8 | a.reg(1)<<4
+		}
+		base := uint16(0x3000)
+		if m == "SNE" {
+			base = 0x4000
+		}
+		return base | a.reg(0)<<8 | a.number(1, 0xFF)
+	case "LD":
+		if a.want(2) {
+			return a.encodeLD()
+		}
+	case "ADD":
+		if !a.want(2) {
+			break
+		}
+		switch {
+		case a.is(0, "I"):
+			return 0xF01E | a.reg(1)<<8
+		case a.isReg(1):
+			return 0x8004 | a.reg(0)<<8 | a.reg(1)<<4
+		default:
+			return 0x7000 | a.reg(0)<<8 | a.number(1, 0xFF)
+		}
+	case "OR", "AND", "XOR", "SUB", "SHR", "SUBN", "SHL":
+		// The shifts can leave out Vy.
+		if (m == "SHR" || m == "SHL") && n == 1 {
+			return 0x8000 | a.reg(0)<<8 | aluNibble(m)
+		}
+		if a.want(2) {
+			return 0x8000 | a.reg(0)<<8 | a.reg(1)<<4 | aluNibble(m)
+		}
+	case "RND":
+		if a.want(2) {
+			return 0xC000 | a.reg(0)<<8 | a.number(1, 0xFF)
+		}
+	case "DRW":
+		if a.want(3) {
+			return 0xD000 | a.reg(0)<<8 | a.reg(1)<<4 | a.number(2, 0xF)
+		}
+	case "SKP":
+		if a.want(1) {
+			return 0xE09E | a.reg(0)<<8
+		}
+	case "SKNP":
+		if a.want(1) {
+			return 0xE0A1 | a.reg(0)<<8
+		}
+	default:
+		a.fail("unknown instruction %q", m)
+	}
+
+	return 0
+}
+
+// encodeLD returns the opcode for one of the many forms of LD.
+func (a *asmArgs) encodeLD() uint16 {
+	switch {
+	case a.is(0, "I"):
+		return 0xA000 | a.addr(1)
+	case a.is(0, "DT"):
+		return 0xF015 | a.reg(1)<<8
+	case a.is(0, "ST"):
+		return 0xF018 | a.reg(1)<<8
+	case a.is(0, "F"):
+		return 0xF029 | a.reg(1)<<8
+	case a.is(0, "B"):
+		return 0xF033 | a.reg(1)<<8
+	case a.is(0, "[I]"):
+		return 0xF055 | a.reg(1)<<8
+	case a.is(1, "DT"):
+		return 0xF007 | a.reg(0)<<8
+	case a.is(1, "K"):
+		return 0xF00A | a.reg(0)<<8
+	case a.is(1, "[I]"):
+		return 0xF065 | a.reg(0)<<8
+	case a.isReg(1):
+		return 0x8000 | a.reg(0)<<8 | a.reg(1)<<4
+	default:
+		return 0x6000 | a.reg(0)<<8 | a.number(1, 0xFF)
+	}
+}
+
+// aluNibble returns the low nibble of the 8xyn opcode for a mnemonic.
+func aluNibble(m string) uint16 {
+	for n, mnemonic := range aluMnemonics {
+		// LD and ADD are handled separately, since they have other
+		// forms.
+		if mnemonic == m && m != "LD" && m != "ADD" {
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,105 @@
+package chip8
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestAssemble(t *testing.T) {
+	tests := []struct {
+		src string
+		out []byte
+	}{
+		{"CLS", []byte{0x00, 0xE0}},
+		{"ld v1, 0x02 ; a comment", []byte{0x61, 0x02}},
+		{"LD V1, 2", []byte{0x61, 0x02}},
+		{"SHR V1", []byte{0x81, 0x06}},
+		{"DB 0xF0, 0x90\nDW 0x1234", []byte{0xF0, 0x90, 0x12, 0x34}},
+
+		// Labels are addressed from 0x200.
+		{"loop: JP loop", []byte{0x12, 0x00}},
+		{"CALL sub\nEXIT\nsub:\nRET", []byte{0x22, 0x04, 0x00, 0xFD, 0x00, 0xEE}},
+		{"LD I, sprite\nsprite: DB 0xF0", []byte{0xA2, 0x02, 0xF0}},
+	}
+
+	for _, tt := range tests {
+		p, err := Assemble(tt.src)
+		if err != nil {
+			t.Errorf("Assemble(%q) => error %v", tt.src, err)
+			continue
+		}
+
+		if !bytes.Equal(p, tt.out) {
+			t.Errorf("Assemble(%q) => % X; want % X", tt.src, p, tt.out)
+		}
+	}
+}
+
+func TestAssemble_Errors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{"NOP", `chip8: line 1: unknown instruction "NOP"`},
+		{"CLS\nLD V1, 0x100", `chip8: line 2: LD: invalid value "0x100"`},
+		{"JP missing", `chip8: line 1: JP: invalid value "missing"`},
+		{"DRW V0, V1", `chip8: line 1: DRW: expected 3 arguments, got 2`},
+		{"SKP I", `chip8: line 1: SKP: expected a register, got "I"`},
+		{"a:\na:", `chip8: line 2: duplicate label "a"`},
+		{"VA: CLS", `chip8: line 1: invalid label "VA"`},
+	}
+
+	for _, tt := range tests {
+		_, err := Assemble(tt.src)
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("Assemble(%q) => error %v; want %s", tt.src, err, tt.err)
+		}
+	}
+}
+
+// Every opcode disassembles to something that assembles back to the same
+// opcode, including the ones that are disassembled as DW directives.
+func TestAssemble_RoundTrip(t *testing.T) {
+	for op := 0; op <= 0xFFFF; op++ {
+		text := Disassemble(uint16(op))
+
+		p, err := Assemble(text)
+		if err != nil {
+			t.Fatalf("Assemble(%q) => error %v", text, err)
+		}
+
+		if want := []byte{byte(op >> 8), byte(op)}; !bytes.Equal(p, want) {
+			t.Fatalf("Assemble(%q) => % X; want % X", text, p, want)
+		}
+	}
+}
+
+// Random programs round trip through DisassembleReachable, which mixes
+// instructions with DB directives for the bytes it treats as data.
+func TestAssemble_RoundTripListing(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 500; i++ {
+		p := make([]byte, 1+r.Intn(64))
+		r.Read(p)
+
+		l := DisassembleReachable(p, ProgramStart, ProgramStart)
+
+		lines := make([]string, len(l))
+		for j, line := range l {
+			lines[j] = line.Text
+		}
+		src := strings.Join(lines, "\n")
+
+		out, err := Assemble(src)
+		if err != nil {
+			t.Fatalf("Assemble() => error %v for\n%s", err, l)
+		}
+
+		if !bytes.Equal(out, p) {
+			t.Fatalf("Assemble() => % X; want % X for\n%s", out, p, l)
+		}
+	}
+}
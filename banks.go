@@ -0,0 +1,71 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+import "errors"
+
+// ErrInvalidBank is returned when switching to a memory bank that doesn't
+// exist.
+var ErrInvalidBank = errors.New("chip8: invalid bank")
+
+// MaxBanks is the largest number of memory banks a CPU can have.
+const MaxBanks = 256
+
+// initBanks sets up n memory banks, with Memory as the first. Every bank
+// starts out with the same interpreter area, so the font is available
+// whichever bank is active.
+func (c *CPU) initBanks(n int) {
+	if n <= 1 {
+		return
+	}
+
+	c.banks = make([][]byte, n)
+	c.banks[0] = c.Memory
+	for i := 1; i < n; i++ {
+		c.banks[i] = append([]byte(nil), c.Memory...)
+	}
+}
+
+// SwitchBank makes bank n the active memory bank, so Memory, and every
+// instruction that reads or writes it, uses that bank from then on. A CPU
+// created without Options.Banks only has bank 0.
+func (c *CPU) SwitchBank(n int) error {
+	if n == c.bank {
+		return nil
+	}
+
+	if n < 0 || n >= len(c.banks) {
+		return ErrInvalidBank
+	}
+
+	c.bank = n
+	c.Memory = c.banks[n]
+
+	// Anything decoded from the old bank is stale.
+	c.resetCaches()
+
+	return nil
+}
+
+// Bank returns the active memory bank.
+func (c *CPU) Bank() int {
+	return c.bank
+}
+
+// dispatchBankOpcode switches banks if op is a bank switching opcode, as
+// decided by BankOpcode.
+func (c *CPU) dispatchBankOpcode(op uint16) (ok bool, err error) {
+	bank, ok := c.BankOpcode(op)
+	if !ok {
+		return false, nil
+	}
+
+	if err := c.SwitchBank(bank); err != nil {
+		return true, err
+	}
+	c.PC += 2
+
+	return true, nil
+}
@@ -0,0 +1,163 @@
+package chip8
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCPU_SwitchBank(t *testing.T) {
+	c, err := NewCPU(&Options{
+		ClockSpeed:  DefaultClockSpeed,
+		Unthrottled: true,
+		Banks:       2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every bank has the font.
+	for bank := 0; bank < 2; bank++ {
+		if err := c.SwitchBank(bank); err != nil {
+			t.Fatal(err)
+		}
+		checkHex(t, "0x000", c.Memory[0x000], FontSet[0])
+	}
+
+	// Stores go to the active bank.
+	store := func(v byte) {
+		c.PC = 0x200
+		c.I = 0x300
+		c.V[0] = v
+		if err := c.Dispatch(0xF055); err != nil { // LD [I], V0
+			t.Fatal(err)
+		}
+	}
+
+	c.SwitchBank(0)
+	store(0xAA)
+	c.SwitchBank(1)
+	store(0xBB)
+
+	// Loads come from the active bank.
+	load := func() byte {
+		c.I = 0x300
+		if err := c.Dispatch(0xF165); err != nil { // LD V1, [I]
+			t.Fatal(err)
+		}
+		return c.V[0]
+	}
+
+	c.SwitchBank(0)
+	checkHex(t, "bank 0", load(), 0xAA)
+	c.SwitchBank(1)
+	checkHex(t, "bank 1", load(), 0xBB)
+
+	if got := c.Bank(); got != 1 {
+		t.Errorf("Bank() => %d; want 1", got)
+	}
+
+	for _, n := range []int{-1, 2} {
+		if err := c.SwitchBank(n); err != ErrInvalidBank {
+			t.Errorf("SwitchBank(%d) => %v; want %v", n, err, ErrInvalidBank)
+		}
+	}
+
+	// Without banks, there's only bank 0.
+	if err := newCPU(t).SwitchBank(1); err != ErrInvalidBank {
+		t.Errorf("SwitchBank(1) => %v; want %v", err, ErrInvalidBank)
+	}
+}
+
+func TestCPU_BankOpcode(t *testing.T) {
+	c, err := NewCPU(&Options{
+		ClockSpeed:  DefaultClockSpeed,
+		Unthrottled: true,
+		Banks:       2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Graphics.Display = NullDisplay
+
+	// 0x00Bn switches to bank n.
+	c.BankOpcode = func(op uint16) (int, bool) {
+		if op&0xFFF0 != 0x00B0 {
+			return 0, false
+		}
+		return int(op & 0xF), true
+	}
+
+	c.LoadBytes([]byte{
+		0x00, 0xB1, // 0x200: BANK 1
+		0x60, 0x01, // 0x202: LD V0, 0x01
+	})
+
+	// The second bank has different code at 0x202.
+	c.banks[1][0x202] = 0x60
+	c.banks[1][0x203] = 0x02 // LD V0, 0x02
+
+	if err := c.RunSteps(2); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := c.Bank(); got != 1 {
+		t.Errorf("Bank() => %d; want 1", got)
+	}
+	checkHex(t, "V0", c.V[0], 0x02)
+
+	// Resetting goes back to the first bank.
+	c.Reset()
+	if got := c.Bank(); got != 0 {
+		t.Errorf("Bank() => %d; want 0 after a reset", got)
+	}
+}
+
+func TestCPU_MarshalBinary_Banks(t *testing.T) {
+	c, err := NewCPU(&Options{
+		ClockSpeed:  DefaultClockSpeed,
+		Unthrottled: true,
+		Banks:       3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for bank := 0; bank < 3; bank++ {
+		c.SwitchBank(bank)
+		c.Memory[0x300] = byte(0xA0 + bank)
+	}
+	c.SwitchBank(2)
+
+	data, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every bank is restored, even into a CPU without any, and the
+	// active one is active again.
+	r := newCPU(t)
+	if err := r.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Bank(); got != 2 {
+		t.Errorf("Bank() => %d; want 2", got)
+	}
+	for _, bank := range []int{2, 0, 1} {
+		if err := r.SwitchBank(bank); err != nil {
+			t.Fatal(err)
+		}
+		checkHex(t, fmt.Sprintf("bank %d: 0x300", bank), r.Memory[0x300], byte(0xA0+bank))
+	}
+
+	// And a state without banks takes them away.
+	data, err = newCPU(t).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SwitchBank(1); err != ErrInvalidBank {
+		t.Errorf("SwitchBank(1) => %v; want %v", err, ErrInvalidBank)
+	}
+}
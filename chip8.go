@@ -21,14 +21,58 @@ import (
 	"io/ioutil"
 	"log"
 	"math/rand"
+	"sync"
 	"time"
 )
 
 var (
 	// ErrQuit is returned by Keypads to indicate a shutdown.
 	ErrQuit = errors.New("chip8: shutting down")
+
+	// ErrReset is returned by Keypads to ask for the program to be
+	// started over, like the TerminalKeypad does when ResetKey is
+	// pressed. Run stops and returns it, leaving the restart to the
+	// caller.
+	ErrReset = errors.New("chip8: reset requested")
+
+	// ErrMemoryAccess is returned when an instruction accesses an address
+	// outside of memory.
+	ErrMemoryAccess = errors.New("chip8: memory access out of bounds")
+
+	// ErrStackOverflow is returned when a CALL is made with every level of
+	// the stack in use.
+	ErrStackOverflow = errors.New("chip8: stack overflow")
+
+	// ErrStackUnderflow is returned when a RET is made with an empty
+	// stack.
+	ErrStackUnderflow = errors.New("chip8: stack underflow")
+
+	// ErrROMTooLarge is returned when a program is too large to fit in
+	// memory after ProgramStart.
+	ErrROMTooLarge = errors.New("chip8: program is too large to fit in memory")
+
+	// ErrHalt is returned when the CPU stops because the program ran off
+	// the end of memory. Like ErrQuit, Run treats it as a clean exit.
+	ErrHalt = errors.New("chip8: halted")
+
+	// ErrExit is returned when the program exits with the SUPER-CHIP 00FD
+	// instruction. Like ErrQuit and ErrHalt, Run treats it as a clean
+	// exit.
+	ErrExit = errors.New("chip8: program exited")
+
+	// ErrCycleBudget is returned by RunUntil when the condition isn't met
+	// within the number of instructions it was allowed to execute.
+	ErrCycleBudget = errors.New("chip8: cycle budget exhausted")
+
+	// ErrBreakpoint is returned when the CPU stops at a breakpoint, like
+	// BreakOnCollision. The instruction has been executed, so calling
+	// Run or Step again carries on from the next one.
+	ErrBreakpoint = errors.New("chip8: breakpoint")
 )
 
+// ProgramStart is the address that programs are loaded at.
+const ProgramStart = 0x200
+
 // Sensible defaults
 var (
 	// DefaultKeypad is the default Keypad to use for input. The default is
@@ -45,16 +89,25 @@ var (
 	// operated at 60 Hz.
 	DefaultClockSpeed = time.Duration(60) // Hz
 
+	// DefaultMemorySize is the default amount of memory, in bytes. The
+	// CHIP-8 had 4096 bytes of RAM.
+	DefaultMemorySize = 4096
+
+	// DefaultStackSize is the default number of stack levels.
+	DefaultStackSize = 16
+
 	// DefaultOptions is the default set of options that's used when calling
 	// NewCPU.
 	DefaultOptions = &Options{
 		ClockSpeed: DefaultClockSpeed,
+		MemorySize: DefaultMemorySize,
+		StackSize:  DefaultStackSize,
 	}
 )
 
 // CPU represents a CHIP-8 CPU.
 type CPU struct {
-	// The 4096 bytes of memory.
+	// The memory, which is 4096 bytes unless configured otherwise.
 	//
 	// Memory Map:
 	// +---------------+= 0xFFF (4095) End of Chip-8 RAM
@@ -79,7 +132,7 @@ type CPU struct {
 	// | Reserved for  |
 	// |  interpreter  |
 	// +---------------+= 0x000 (0) Start of Chip-8 RAM
-	Memory [4096]byte
+	Memory []byte
 
 	// The address register, which is named I, is 16 bits wide and is used
 	// with several opcodes that involve memory operations.
@@ -95,14 +148,19 @@ type CPU struct {
 	// The stack is only used to store return addresses when subroutines are
 	// called. The original 1802 version allocated 48 bytes for up to 12
 	// levels of nesting; modern implementations normally have at least 16
-	// levels.
-	Stack [16]uint16
+	// levels. The number of levels is configured with Options.StackSize.
+	Stack []uint16
 
-	// Stack pointer.
+	// Stack pointer, which is the number of addresses on the stack.
 	SP byte
 
 	// The CHIP-8 timers count down at 60 Hz, so we slow down the cpu clock
-	// to only execute 60 opcodes per second.
+	// to only execute 60 opcodes per second. When nil, Run executes
+	// instructions as fast as possible.
+	//
+	// Ticks can be dropped when the process is under load, so each tick
+	// executes however many instructions should have run since the last
+	// one, up to maxCatchUp worth of instructions.
 	Clock <-chan time.Time
 
 	// Delay timer.
@@ -111,23 +169,300 @@ type CPU struct {
 	// Sound timer.
 	ST byte
 
+	// The number of instructions that have been executed.
+	Cycles uint64
+
+	// The number of times the sound timer has been started.
+	Beeps uint64
+
 	// The graphics array.
 	Graphics
 
-	// The connected Keypad. The zero value is the DefaultKeypad.
+	// The connected Keypad. The zero value is the DefaultKeypad. The
+	// Keypad, like the Display, is called with the registers unlocked, so
+	// it can use the register accessors even while Fx0A waits on it.
 	Keypad Keypad
 
+	// Quirks controls the behavior of opcodes whose semantics differ
+	// between interpreters.
+	Quirks Quirks
+
 	// A logger to log information about the CPU while it's executing. The
 	// zero value is the DefaultLogger.
 	Logger *log.Logger
 
+	// The source of random numbers for Cxkk. Set this to a seeded source
+	// for deterministic runs. The zero value uses a time seeded source.
+	Rand *rand.Rand
+
+	// When true, writes to VF by arithmetic and draw opcodes are recorded
+	// and can be inspected with LastFlagWrite.
+	TraceFlagWrites bool
+
+	// When true, the first Dxyn that reports a collision is recorded and
+	// can be inspected with FirstCollision. It's also logged, along with
+	// the address of the sprite.
+	TraceFirstCollision bool
+
+	// When true, the CPU stops with ErrBreakpoint right after the first
+	// Dxyn that sets VF because of a collision, so the state that caused
+	// it can be inspected. Later collisions don't stop it again until the
+	// program is reset. The collision is recorded as the FirstCollision.
+	BreakOnCollision bool
+
+	// When set, BankOpcode is asked about every opcode before it's
+	// executed. If it returns ok, the opcode switches to the returned
+	// memory bank instead, and execution carries on at the next address
+	// in that bank. This lets a program for a banked machine choose its
+	// own bank switching instruction. It's called with the registers
+	// locked, and mustn't call the register accessors.
+	BankOpcode func(op uint16) (bank int, ok bool)
+
+	// the memory banks, with Options.Banks, and the active one.
+	banks [][]byte
+	bank  int
+
+	// When set, every instruction executed is recorded by the Profiler.
+	Profiler *Profiler
+
+	// When set, OnFrame is called at every 60 Hz frame boundary, right
+	// after the timers count down, with the number of instructions
+	// executed so far and the graphics array. It's called on the
+	// goroutine that's running the CPU, so it's a good place to keep
+	// audio or networking in sync with the program. Like the Display and
+	// the Keypad, it's called with the registers unlocked, so it can use
+	// the register accessors.
+	OnFrame func(cycle uint64, g *Graphics)
+
+	// When set, MemWriteTracer is called with the address, the old value
+	// and the new value of every byte written to memory, including bytes
+	// loaded with Load. This is useful for mapping out the data
+	// structures of a program. It's called in the middle of an
+	// instruction with the registers locked, so it can read the CPU's
+	// fields directly, but calling the register accessors deadlocks.
+	MemWriteTracer func(addr uint16, before, after byte)
+
+	// When set, MemReadTracer is called with the address and value of
+	// every byte the program reads from memory: opcode fetches, the
+	// sprite bytes read by Dxyn and the bytes loaded by Fx65. Together
+	// with MemWriteTracer, this maps out which memory a program
+	// consumes, and when. Like MemWriteTracer, it's called with the
+	// registers locked, and mustn't call the register accessors.
+	MemReadTracer func(addr uint16, value byte)
+
+	// When true, Dxyn caches the decoded rows of the sprites it draws,
+	// which speeds up programs that draw the same sprites every frame.
+	// The cache is invalidated by writes from opcodes and Load, so
+	// sprite data shouldn't be changed through Memory directly while
+	// this is enabled.
+	CacheSprites bool
+
+	// decoded sprites, when CacheSprites is enabled.
+	sprites spriteCache
+
+	// decoded opcodes, once Precompile is called.
+	ops opCache
+
+	// When non-zero, the CPU sleeps for IdleSleep between instructions
+	// once the program has polled a KeyState keypad for IdleThreshold
+	// instructions without drawing or changing any registers or memory.
+	// This saves the host CPU while an unthrottled program waits on
+	// input.
+	IdleThreshold uint64
+
+	// How long to sleep between instructions while idle. The zero value
+	// is the DefaultIdleSleep.
+	IdleSleep time.Duration
+
+	// how long the program has been waiting on input, for
+	// IdleThreshold.
+	idle idle
+
+	// When true, loops that do nothing but wait for the delay timer to
+	// run out, by reading it with Fx07 again and again, are skipped by
+	// counting the timers down to where the loop would exit. This saves
+	// the host CPU when running unthrottled, but programs that use the
+	// delay timer to pace themselves will run faster than they should
+	// in real time.
+	SkipDelayWaits bool
+
+	// the last Fx07, for SkipDelayWaits.
+	delayWait delayWait
+
+	// What happens when PC runs off the end of memory.
+	EndOfMemory EndOfMemory
+
+	// When true, Ex9E and ExA1 read the keys from a snapshot of a KeyState
+	// keypad that's taken once per 60 Hz frame, so every read within a
+	// frame sees the same keys.
+	LatchKeys bool
+
+	// the keys snapshotted for LatchKeys.
+	keyLatch keyLatch
+
+	// When true, Dxyn doesn't render to the Display. Callers that drive
+	// the CPU with StepInfo can use Drawn to decide when to call
+	// Graphics.Draw themselves.
+	DeferDraw bool
+
+	// whether the current instruction changed the graphics array, and
+	// whether it reported the first collision.
+	drawn    bool
+	collided bool
+
+	// the instructions executed recently, for RecentInstructions.
+	trace traceRing
+
+	// When true, the CPU fails with a Violation when a program does
+	// something the specification leaves undefined, that's otherwise
+	// tolerated: using a key or font digit above 0xF, or pushing I past
+	// 0xFFF with Fx1E. Running off the end of memory fails with
+	// ErrMemoryAccess, regardless of the EndOfMemory policy, and unknown
+	// opcodes fail even with SkipUnknownOpcodes.
+	Strict bool
+
+	// When true, an unknown opcode is logged to the Logger, along with
+	// the instructions around it, and skipped instead of stopping the
+	// CPU. This lets a program limp past a corrupt instruction in a bad
+	// ROM dump. It's ignored in Strict mode.
+	SkipUnknownOpcodes bool
+
+	// The number of unknown opcodes skipped with SkipUnknownOpcodes.
+	SkippedOpcodes uint64
+
+	// where the time comes from.
+	timeSource TimeSource
+
+	// instructions executed towards the next frame, in 60ths.
+	frameAcc int64
+
+	// the address that PC is set to on a reset.
+	entryPoint uint16
+
+	// the most recent write to VF, when TraceFlagWrites is enabled.
+	flagWrite flagWrite
+
+	// the first collision, when TraceFirstCollision is enabled.
+	firstCollision firstCollision
+
+	// the key press that Fx0A is waiting on, with the KeyReleaseWait
+	// quirk.
+	keyWait keyWait
+
+	// the number of instructions executed per second.
+	clockSpeed time.Duration
+
+	// the range of memory that's persisted, and the file it's persisted
+	// to for the loaded program.
+	store     *PersistentStore
+	storePath string
+
+	// scales the clock speed, set by SetSpeedMultiplier.
+	speedMu         sync.Mutex
+	speedMultiplier float64
+
+	// the last time Run caught up with real time, and the time left over
+	// that wasn't enough for a whole instruction.
+	checkpoint time.Time
+	lag        time.Duration
+
+	// held while instructions are executed, and by the register
+	// accessors, so registers can be read and written from other
+	// goroutines while the CPU is running.
+	regMu sync.Mutex
+
+	// held while the Display is read or swapped by SetDisplay. When both
+	// are needed, regMu is locked first.
+	displayMu sync.Mutex
+
+	// a snapshot of the counters, published by Run for other goroutines.
+	statsMu sync.Mutex
+	stats   Stats
+
 	// channel used to indicate a shutdown.
 	stop chan struct{}
 }
 
+// EndOfMemory determines what happens when PC runs off the end of memory
+// without jumping back.
+type EndOfMemory int
+
+const (
+	// EndOfMemoryHalt stops the CPU with ErrHalt.
+	EndOfMemoryHalt EndOfMemory = iota
+
+	// EndOfMemoryWrap continues executing from 0x200.
+	EndOfMemoryWrap
+
+	// EndOfMemoryError stops the CPU with ErrMemoryAccess.
+	EndOfMemoryError
+)
+
 // Options provides a means of configuring the CPU.
 type Options struct {
+	// The number of instructions executed per second, in Hz. The timers
+	// count down at 60 Hz, regardless of the clock speed.
 	ClockSpeed time.Duration
+
+	// Unthrottled leaves the CPU without a Clock, so Run executes
+	// instructions as fast as possible.
+	Unthrottled bool
+
+	// Quirks selects interpreter specific opcode behavior. The zero value
+	// is the behavior this package has always had.
+	Quirks Quirks
+
+	// The amount of memory, in bytes. The zero value is the
+	// DefaultMemorySize. Smaller sizes can be used to emulate 2K
+	// machines, like the ETI 660.
+	MemorySize int
+
+	// SkipFontLoad leaves the interpreter area of memory zeroed, or filled
+	// with the MemoryFill, instead of loading the FontSet into it.
+	// Programs that use Fx29 won't draw anything useful.
+	SkipFontLoad bool
+
+	// The number of recently executed instructions to keep for
+	// RecentInstructions and UnknownOpcode errors. The zero value keeps
+	// none.
+	TraceSize int
+
+	// Where the time comes from. The zero value is RealTime.
+	TimeSource TimeSource
+
+	// Strict makes the CPU fail with an error when a program does
+	// anything the specification leaves undefined. See CPU.Strict.
+	Strict bool
+
+	// The byte that memory is filled with before the font and program are
+	// loaded. The zero value leaves memory zeroed. A recognizable value
+	// like 0xCC makes reads of uninitialized memory stand out, in a
+	// MemWriteTracer or a dump.
+	MemoryFill byte
+
+	// What happens when PC runs off the end of memory. The zero value is
+	// EndOfMemoryHalt.
+	EndOfMemory EndOfMemory
+
+	// The number of stack levels, which limits how deeply subroutines can
+	// be nested. The zero value is the DefaultStackSize. The original 1802
+	// version had 12.
+	StackSize int
+
+	// The address of the first instruction to execute. The zero value is
+	// 0x200, where most programs start. Programs for the ETI 660 start at
+	// 0x600.
+	EntryPoint uint16
+
+	// PersistentStore maps a range of memory to a file that's kept
+	// between runs. The zero value doesn't persist anything.
+	PersistentStore *PersistentStore
+
+	// The number of memory banks, each MemorySize bytes, for programs
+	// that need more memory than can be addressed at once. Only one bank
+	// is active at a time; see CPU.SwitchBank. The zero value is a single
+	// bank.
+	Banks int
 }
 
 // NewCPU returns a new CPU instance.
@@ -136,19 +471,157 @@ func NewCPU(options *Options) (*CPU, error) {
 		options = DefaultOptions
 	}
 
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
 	c := &CPU{
-		PC:    0x200,
-		Clock: time.Tick(time.Second / options.ClockSpeed),
-		stop:  make(chan struct{}),
+		Memory:      make([]byte, options.memorySize()),
+		Stack:       make([]uint16, options.stackSize()),
+		PC:          ProgramStart,
+		Quirks:      options.Quirks,
+		EndOfMemory: options.EndOfMemory,
+		Strict:      options.Strict,
+		clockSpeed:  options.ClockSpeed,
+		timeSource:  options.TimeSource,
+		store:       options.PersistentStore,
+		stop:        make(chan struct{}),
+	}
+
+	if c.timeSource == nil {
+		c.timeSource = RealTime
+	}
+
+	if options.TraceSize > 0 {
+		c.trace.buf = make([]TraceEntry, options.TraceSize)
+	}
+
+	if options.MemoryFill != 0 {
+		for i := range c.Memory {
+			c.Memory[i] = options.MemoryFill
+		}
+	}
+
+	if options.EntryPoint != 0 {
+		c.PC = options.EntryPoint
 	}
+	c.entryPoint = c.PC
 
-	return c, c.init()
+	if !options.Unthrottled {
+		c.Clock = c.timeSource.Tick(time.Second / options.ClockSpeed)
+	}
+
+	if !options.SkipFontLoad {
+		if err := c.init(); err != nil {
+			return c, err
+		}
+	}
+
+	c.initBanks(options.Banks)
+
+	return c, nil
+}
+
+// Jump sets the program counter to addr, so the next instruction executed is
+// the one at addr. It returns ErrMemoryAccess if there's no instruction at
+// addr.
+func (c *CPU) Jump(addr uint16) error {
+	if int(addr)+1 >= len(c.Memory) {
+		return ErrMemoryAccess
+	}
+
+	c.PC = addr
+	return nil
+}
+
+// SoftReset restarts the loaded program, like a reset key that doesn't blank
+// the display. The registers, stack and timers are cleared and PC is set
+// back to the entry point, but memory and the graphics array are kept.
+func (c *CPU) SoftReset() {
+	c.V = [16]byte{}
+	c.I = 0
+	c.PC = c.entryPoint
+	for i := range c.Stack {
+		c.Stack[i] = 0
+	}
+	c.SP = 0
+	c.DT = 0
+	c.ST = 0
+	c.keyWait = keyWait{}
+	c.keyLatch = keyLatch{}
+	c.frameAcc = 0
+	c.flagWrite = flagWrite{}
+	c.firstCollision = firstCollision{}
+	c.idle = idle{}
+
+	// The program starts in the first bank.
+	c.SwitchBank(0)
+}
+
+// Reset restarts the loaded program like the VIP did, which is a SoftReset
+// that also clears the graphics array and returns it to low resolution.
+func (c *CPU) Reset() {
+	c.SoftReset()
+	c.Graphics.HiRes = false
+	c.Graphics.Pixels = [HiResWidth * HiResHeight]byte{}
+}
+
+// Reload starts the program over from scratch. It's a Reset that also
+// clears the program's memory in every bank, keeping the interpreter area
+// below ProgramStart, and then loads p like LoadBytes. Unlike Reset, it
+// undoes anything the program wrote to memory.
+func (c *CPU) Reload(p []byte) (int, error) {
+	c.Reset()
+
+	banks := c.banks
+	if banks == nil {
+		banks = [][]byte{c.Memory}
+	}
+	for _, m := range banks {
+		for i := ProgramStart; i < len(m); i++ {
+			m[i] = 0x00
+		}
+	}
+	c.resetCaches()
+
+	return c.LoadBytes(p)
+}
+
+// resetCaches throws away everything decoded from memory, for when memory is
+// replaced or changed without writeByte.
+func (c *CPU) resetCaches() {
+	c.sprites = spriteCache{}
+	if c.ops.ops != nil {
+		c.Precompile()
+	}
 }
 
 // Load reads from the reader and loads the bytes into memory starting at
-// address 200.
+// ProgramStart. If the program doesn't fit in memory, ErrROMTooLarge is
+// returned and nothing is loaded.
+//
+// With a PersistentStore, anything the program saved on a previous run is
+// restored after it's loaded.
 func (c *CPU) Load(r io.Reader) (int, error) {
-	return c.load(0x200, r)
+	if c.store == nil {
+		return c.load(ProgramStart, r)
+	}
+
+	var p bytes.Buffer
+	n, err := c.load(ProgramStart, io.TeeReader(r, &p))
+	if err != nil {
+		return n, err
+	}
+
+	return n, c.restore(p.Bytes())
+}
+
+// ROMSize returns the size of the program in r, in bytes. A program fits in
+// the default amount of memory if it's no larger than DefaultMemorySize -
+// ProgramStart.
+func ROMSize(r io.Reader) (int, error) {
+	n, err := io.Copy(ioutil.Discard, r)
+	return int(n), err
 }
 
 // LoadBytes loads the bytes into memory.
@@ -157,7 +630,21 @@ func (c *CPU) LoadBytes(p []byte) (int, error) {
 }
 
 func (c *CPU) load(offset int, r io.Reader) (int, error) {
-	return r.Read(c.Memory[offset:])
+	// Read one byte more than fits, to tell if the program is too large.
+	p, err := ioutil.ReadAll(io.LimitReader(r, int64(len(c.Memory)-offset)+1))
+	if err != nil {
+		return 0, err
+	}
+
+	if len(p) > len(c.Memory)-offset {
+		return 0, ErrROMTooLarge
+	}
+
+	for i, b := range p {
+		c.writeByte(offset+i, b)
+	}
+
+	return len(p), nil
 }
 
 // init loads initalizes the cpu by loading the fontset into RAM.
@@ -169,18 +656,120 @@ func (c *CPU) init() error {
 	return nil
 }
 
+// StepInfo describes a single CPU cycle.
+type StepInfo struct {
+	// The opcode that was executed.
+	Opcode uint16
+
+	// Whether the opcode changed the graphics array, like Dxyn and CLS
+	// do.
+	Drawn bool
+}
+
 // Step runs a single CPU cycle.
 func (c *CPU) Step() (uint16, error) {
+	info, err := c.StepInfo()
+	return info.Opcode, err
+}
+
+// StepInfo runs a single CPU cycle, like Step, and describes what happened.
+func (c *CPU) StepInfo() (StepInfo, error) {
+	c.regMu.Lock()
+	defer c.regMu.Unlock()
+	return c.step()
+}
+
+// step is StepInfo, with regMu held.
+func (c *CPU) step() (StepInfo, error) {
+	c.drawn = false
+	c.collided = false
+
 	// Decode the opcode.
-	op := c.decodeOp()
+	d, err := c.decodeOp()
+	op := d.Opcode
+	if err != nil {
+		return StepInfo{Opcode: op}, err
+	}
 
 	c.logger().Printf("op=0x%04X %s\n", op, c)
 
+	c.trace.add(c.PC, op)
+
+	var start time.Time
+	if c.Profiler != nil {
+		start = c.now()
+	}
+
 	// Dispatch the opcode.
-	if err := c.Dispatch(op); err != nil {
-		return op, err
+	if err := c.execute(d); err != nil {
+		unknown, ok := err.(*UnknownOpcode)
+		if !ok || !c.SkipUnknownOpcodes || c.Strict {
+			return StepInfo{Opcode: op, Drawn: c.drawn}, err
+		}
+
+		c.skipUnknownOpcode(unknown)
+	}
+
+	if c.Profiler != nil {
+		c.Profiler.record(op, c.now().Sub(start))
+	}
+
+	if c.IdleThreshold > 0 {
+		c.checkIdle()
+	}
+
+	boundary := c.frame()
+
+	c.Cycles++
+
+	if boundary && c.OnFrame != nil {
+		c.unlocked(func() {
+			c.OnFrame(c.Cycles, &c.Graphics)
+		})
+	}
+
+	if c.BreakOnCollision && c.collided {
+		return StepInfo{Opcode: op, Drawn: c.drawn}, ErrBreakpoint
 	}
 
+	return StepInfo{Opcode: op, Drawn: c.drawn}, nil
+}
+
+// frame advances the 60 Hz frame clock by an instruction. At each frame
+// boundary, the timers count down and the key latch is released. CPUs
+// running at 60 Hz or less have a frame boundary after every instruction.
+// It returns true at a frame boundary.
+func (c *CPU) frame() bool {
+	if hz := int64(c.speed()); hz > 60 {
+		c.frameAcc += 60
+		if c.frameAcc < hz {
+			return false
+		}
+		c.frameAcc -= hz
+	}
+
+	c.tickTimers()
+
+	c.keyLatch = keyLatch{}
+	c.keyWait.polled = false
+
+	return true
+}
+
+// TickTimers counts the delay and sound timers down once, stopping at zero,
+// like they are at every frame boundary. It's independent of the
+// instructions executed, so tests and debuggers can use it to advance the
+// timers deterministically. The sound stops once ST reaches zero, and the
+// next Fx18 that sets it counts as another beep. Like the register
+// accessors, it's safe to call while the CPU is running.
+func (c *CPU) TickTimers() {
+	c.regMu.Lock()
+	defer c.regMu.Unlock()
+	c.tickTimers()
+}
+
+// tickTimers is TickTimers, with regMu held.
+func (c *CPU) tickTimers() {
 	if c.DT > 0 {
 		c.DT--
 	}
@@ -188,32 +777,221 @@ func (c *CPU) Step() (uint16, error) {
 	if c.ST > 0 {
 		c.ST--
 	}
+}
 
-	return op, nil
+// keyLatch is a snapshot of the keys that are pressed, for LatchKeys.
+type keyLatch struct {
+	valid   bool
+	pressed uint16
 }
 
-// Run does the thing.
+// maxCatchUp is how far behind real time the CPU can fall before it stops
+// trying to catch up. Without a bound, a CPU that can't keep up would
+// execute ever larger batches of instructions.
+const maxCatchUp = 250 * time.Millisecond
+
+// statsInterval is how many instructions Run executes between publishing
+// Stats when it's not throttled by a Clock.
+const statsInterval = 1000
+
+// Run executes instructions until the CPU is stopped, the Keypad signals a
+// shutdown with ErrQuit, the program halts, or an instruction fails.
+// Anything but a failure is a clean exit, so Run only returns an error for
+// the latter.
+//
+// Before executing anything, Run renders the graphics array, so the Display
+// starts out blank instead of showing whatever was there before, or, when
+// resuming, shows where the program left off.
 func (c *CPU) Run() error {
-	// Simulate the clock speed of the CHIP-8 CPU.
+	return c.run(c.stop)
+}
+
+// run is Run, stopping when stop is closed.
+func (c *CPU) run(stop <-chan struct{}) error {
+	c.checkpoint = c.now()
+	c.lag = 0
+
+	defer c.publishStats()
+
+	if !c.DeferDraw {
+		if err := c.Graphics.renderTo(c.display()); err != nil {
+			return err
+		}
+	}
+
 	for {
-		select {
-		case <-c.stop:
-			return nil
-		case <-c.Clock:
-			_, err := c.Step()
-			if err != nil {
-				if err == ErrQuit {
-					return nil
-				}
+		n := 1
+
+		// Simulate the clock speed of the CHIP-8 CPU.
+		if c.Clock != nil {
+			select {
+			case <-stop:
+				return nil
+			case <-c.Clock:
+			}
 
-				return err
+			n = c.due(c.now())
+		} else {
+			select {
+			case <-stop:
+				return nil
+			default:
 			}
 		}
+
+		err := c.steps(n)
+
+		// Publishing takes a lock, so only do it once per tick, or every
+		// so often when running unthrottled.
+		if c.Clock != nil || c.Cycles%statsInterval == 0 {
+			c.publishStats()
+		}
+
+		if err != nil {
+			if cleanExit(err) {
+				return nil
+			}
+
+			return err
+		}
+	}
+}
+
+// RunSteps executes n instructions without waiting on the Clock, which is
+// useful for running programs headless.
+func (c *CPU) RunSteps(n int) error {
+	err := c.steps(n)
+	c.publishStats()
+
+	if err != nil && !cleanExit(err) {
+		return err
+	}
+
+	return nil
+}
+
+// RunN executes up to n instructions without waiting on the Clock, like
+// RunSteps, and returns the opcodes that were executed, in order. It's
+// useful for checking which path a program takes. The instruction that
+// stops the CPU, by failing or by halting the program, isn't included.
+func (c *CPU) RunN(n int) ([]uint16, error) {
+	defer c.publishStats()
+
+	var ops []uint16
+	for i := 0; i < n; i++ {
+		op, err := c.Step()
+		if err != nil {
+			if cleanExit(err) {
+				return ops, nil
+			}
+
+			return ops, err
+		}
+
+		ops = append(ops, op)
+	}
+
+	return ops, nil
+}
+
+// cleanExit returns true for the errors that Run treats as the program
+// finishing, rather than failing.
+func cleanExit(err error) bool {
+	return err == ErrQuit || err == ErrHalt || err == ErrExit
+}
+
+// RunUntil executes instructions until pred returns true, which is checked
+// before each instruction. If pred isn't true after maxCycles instructions,
+// ErrCycleBudget is returned. Errors from instructions, including ErrQuit,
+// are returned as is.
+func (c *CPU) RunUntil(pred func(*CPU) bool, maxCycles int) error {
+	defer c.publishStats()
+
+	for i := 0; i < maxCycles; i++ {
+		if pred(c) {
+			return nil
+		}
+
+		if _, err := c.Step(); err != nil {
+			return err
+		}
+	}
+
+	if pred(c) {
+		return nil
+	}
+
+	return ErrCycleBudget
+}
+
+// MaxStepOverCycles is how many instructions StepOver executes in a
+// subroutine before giving up on it returning.
+const MaxStepOverCycles = 1000000
+
+// StepOver executes the next instruction like Step, except that a 2nnn CALL
+// runs the whole subroutine, stopping at the instruction after the CALL once
+// it returns. If the subroutine doesn't return within MaxStepOverCycles
+// instructions, ErrCycleBudget is returned. Errors from instructions are
+// returned as is, with the CPU stopped wherever they happened.
+func (c *CPU) StepOver() error {
+	if int(c.PC)+1 >= len(c.Memory) || c.Memory[c.PC]&0xF0 != 0x20 {
+		_, err := c.Step()
+		return err
+	}
+
+	sp := c.SP
+	if _, err := c.Step(); err != nil {
+		return err
+	}
+
+	return c.RunUntil(func(c *CPU) bool {
+		return c.SP == sp
+	}, MaxStepOverCycles)
+}
+
+// steps executes n instructions.
+func (c *CPU) steps(n int) error {
+	for i := 0; i < n; i++ {
+		if _, err := c.Step(); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// unlocked runs fn with regMu released, for calling back into the Display,
+// the Keypad and OnFrame in the middle of an instruction. They can then use
+// the register accessors, or wait on another goroutine that does, without
+// deadlocking.
+func (c *CPU) unlocked(fn func()) {
+	c.regMu.Unlock()
+	defer c.regMu.Lock()
+	fn()
+}
+
+// due returns the number of instructions that should be executed to catch up
+// with real time at t, and moves the checkpoint to t.
+func (c *CPU) due(t time.Time) int {
+	// Without a clock speed, there's nothing to catch up with.
+	hz := c.speed()
+	if hz <= 0 {
+		return 1
+	}
+
+	elapsed := t.Sub(c.checkpoint) + c.lag
+	c.checkpoint = t
+
+	if elapsed > maxCatchUp {
+		elapsed = maxCatchUp
+	}
+
+	n := elapsed * hz / time.Second
+	c.lag = elapsed - n*time.Second/hz
+
+	return int(n)
+}
+
 // Stop stops the CPU from executing.
 func (c *CPU) Stop() {
 	close(c.stop)
@@ -221,6 +999,16 @@ func (c *CPU) Stop() {
 
 // Dispatch executes the given opcode.
 func (c *CPU) Dispatch(op uint16) error {
+	c.regMu.Lock()
+	defer c.regMu.Unlock()
+	return c.execute(decode(c.PC, op))
+}
+
+// execute is Dispatch, with regMu held, for an opcode that's already been
+// split into its fields, so they don't have to be masked out again.
+func (c *CPU) execute(d DecodedOp) error {
+	op := d.Opcode
+
 	// In these listings, the following variables are used:
 	//
 	// nnn or addr - A 12-bit value, the lowest 12 bits of the instruction
@@ -229,6 +1017,12 @@ func (c *CPU) Dispatch(op uint16) error {
 	// y - A 4-bit value, the upper 4 bits of the low byte of the instruction
 	// kk or byte - An 8-bit value, the lowest 8 bits of the instruction
 
+	if c.BankOpcode != nil {
+		if ok, err := c.dispatchBankOpcode(op); ok {
+			return err
+		}
+	}
+
 	switch op & 0xF000 {
 	// 0nnn - SYS addr
 	case 0x0000:
@@ -236,6 +1030,7 @@ func (c *CPU) Dispatch(op uint16) error {
 		// 00E0 - CLS
 		case 0x00E0:
 			c.Graphics.Clear()
+			c.drawn = true
 
 			c.PC += 2
 
@@ -249,21 +1044,76 @@ func (c *CPU) Dispatch(op uint16) error {
 			// address at the top of the stack, then subtracts 1
 			// from the stack pointer.
 
-			c.PC = c.Stack[c.SP]
+			// SP counts the addresses on the stack, so the top is
+			// below it.
+			if c.SP == 0 {
+				return ErrStackUnderflow
+			}
+
 			c.SP--
+			c.PC = c.Stack[c.SP]
 
 			c.PC += 2
 
 			break
 
+		// 00FB - SCR
+		case 0x00FB:
+			// Scroll the display right by 4 pixels.
+			//
+			// This is a SUPER-CHIP instruction.
+
+			c.Graphics.Scroll(4, 0)
+			c.drawn = true
+
+			c.PC += 2
+
+			break
+
+		// 00FC - SCL
+		case 0x00FC:
+			// Scroll the display left by 4 pixels.
+			//
+			// This is a SUPER-CHIP instruction.
+
+			c.Graphics.Scroll(-4, 0)
+			c.drawn = true
+
+			c.PC += 2
+
+			break
+
+		// 00FD - EXIT
+		case 0x00FD:
+			// Exit the interpreter.
+			//
+			// This is a SUPER-CHIP instruction. PC isn't advanced,
+			// so the program exits again if it's resumed.
+
+			return ErrExit
+
 		default:
+			// 00Cn - SCD nibble
+			if op&0xFFF0 == 0x00C0 {
+				// Scroll the display down by n pixels.
+				//
+				// This is a SUPER-CHIP instruction.
+
+				c.Graphics.Scroll(0, int(d.N))
+				c.drawn = true
+
+				c.PC += 2
+
+				break
+			}
+
 			// Jump to a machine code routine at nnn.
 			//
 			// This instruction is only used on the old computers on
 			// which Chip-8 was originally implemented. It is
 			// ignored by modern interpreters.
 
-			return &UnknownOpcode{Opcode: op}
+			return c.unknownOpcode(op)
 		}
 
 		break
@@ -274,7 +1124,7 @@ func (c *CPU) Dispatch(op uint16) error {
 		//
 		// The interpreter sets the program counter to nnn.
 
-		c.PC = op & 0x0FFF
+		c.PC = d.NNN
 
 		break
 
@@ -286,9 +1136,14 @@ func (c *CPU) Dispatch(op uint16) error {
 		// current PC on the top of the stack. The PC is then set to
 		// nnn.
 
-		c.SP++
+		// SP counts the addresses on the stack, so the top is at SP.
+		if int(c.SP) >= len(c.Stack) {
+			return ErrStackOverflow
+		}
+
 		c.Stack[c.SP] = c.PC
-		c.PC = op & 0x0FFF
+		c.SP++
+		c.PC = d.NNN
 
 		break
 
@@ -299,8 +1154,8 @@ func (c *CPU) Dispatch(op uint16) error {
 		// The interpreter compares register Vx to kk, and if they are
 		// equal, increments the program counter by 2.
 
-		x := (op & 0x0F00) >> 8
-		kk := byte(op)
+		x := uint16(d.X)
+		kk := d.KK
 
 		c.PC += 2
 		if c.V[x] == kk {
@@ -316,8 +1171,8 @@ func (c *CPU) Dispatch(op uint16) error {
 		// The interpreter compares register Vx to kk, and if they are
 		// not equal, increments the program counter by 2.
 
-		x := (op & 0x0F00) >> 8
-		kk := byte(op)
+		x := uint16(d.X)
+		kk := d.KK
 
 		c.PC += 2
 		if c.V[x] != kk {
@@ -335,8 +1190,8 @@ func (c *CPU) Dispatch(op uint16) error {
 			// The interpreter compares register Vx to register Vy, and if
 			// they are equal, increments the program counter by 2.
 
-			x := (op & 0x0F00) >> 8
-			y := (op & 0x00F0) >> 4
+			x := uint16(d.X)
+			y := uint16(d.Y)
 
 			c.PC += 2
 			if c.V[x] == c.V[y] {
@@ -345,7 +1200,7 @@ func (c *CPU) Dispatch(op uint16) error {
 
 			break
 		default:
-			return &UnknownOpcode{Opcode: op}
+			return c.unknownOpcode(op)
 		}
 
 		break
@@ -356,8 +1211,8 @@ func (c *CPU) Dispatch(op uint16) error {
 		//
 		// The interpreter puts the value kk into register Vx.
 
-		x := (op & 0x0F00) >> 8
-		kk := byte(op)
+		x := uint16(d.X)
+		kk := d.KK
 
 		c.V[x] = kk
 
@@ -372,8 +1227,8 @@ func (c *CPU) Dispatch(op uint16) error {
 		// Adds the value kk to the value of register Vx, then stores
 		// the result in Vx.
 
-		x := (op & 0x0F00) >> 8
-		kk := byte(op)
+		x := uint16(d.X)
+		kk := d.KK
 
 		c.V[x] = c.V[x] + kk
 
@@ -382,10 +1237,10 @@ func (c *CPU) Dispatch(op uint16) error {
 		break
 
 	case 0x8000:
-		x := (op & 0x0F00) >> 8
-		y := (op & 0x00F0) >> 4
+		x := uint16(d.X)
+		y := uint16(d.Y)
 
-		switch op & 0x000F {
+		switch d.N {
 		// 8xy0 - LD Vx, Vy
 		case 0x0000:
 			// Set Vx = Vy.
@@ -410,6 +1265,10 @@ func (c *CPU) Dispatch(op uint16) error {
 
 			c.V[x] = c.V[y] | c.V[x]
 
+			if c.Quirks.ResetVF {
+				c.setVF(op, 0)
+			}
+
 			c.PC += 2
 
 			break
@@ -426,6 +1285,10 @@ func (c *CPU) Dispatch(op uint16) error {
 
 			c.V[x] = c.V[y] & c.V[x]
 
+			if c.Quirks.ResetVF {
+				c.setVF(op, 0)
+			}
+
 			c.PC += 2
 
 			break
@@ -443,6 +1306,10 @@ func (c *CPU) Dispatch(op uint16) error {
 
 			c.V[x] = c.V[y] ^ c.V[x]
 
+			if c.Quirks.ResetVF {
+				c.setVF(op, 0)
+			}
+
 			c.PC += 2
 
 			break
@@ -462,7 +1329,7 @@ func (c *CPU) Dispatch(op uint16) error {
 			if r > 0xFF {
 				cf = 1
 			}
-			c.V[0xF] = cf
+			c.setVF(op, cf)
 
 			c.V[x] = byte(r)
 
@@ -481,7 +1348,7 @@ func (c *CPU) Dispatch(op uint16) error {
 			if c.V[x] > c.V[y] {
 				cf = 1
 			}
-			c.V[0xF] = cf
+			c.setVF(op, cf)
 
 			c.V[x] = c.V[x] - c.V[y]
 
@@ -495,12 +1362,20 @@ func (c *CPU) Dispatch(op uint16) error {
 			//
 			// If the least-significant bit of Vx is 1, then VF is
 			// set to 1, otherwise 0. Then Vx is divided by 2.
+			//
+			// With the ShiftUsesVy quirk, Vy is shifted instead
+			// and the result is stored in Vx, so VF is the bit
+			// shifted out of Vy.
+
+			if c.Quirks.ShiftUsesVy {
+				c.V[x] = c.V[y]
+			}
 
 			var cf byte
 			if (c.V[x] & 0x01) == 0x01 {
 				cf = 1
 			}
-			c.V[0xF] = cf
+			c.setVF(op, cf)
 
 			c.V[x] = c.V[x] / 2
 
@@ -519,7 +1394,7 @@ func (c *CPU) Dispatch(op uint16) error {
 			if c.V[y] > c.V[x] {
 				cf = 1
 			}
-			c.V[0xF] = cf
+			c.setVF(op, cf)
 
 			c.V[x] = c.V[y] - c.V[x]
 
@@ -533,18 +1408,28 @@ func (c *CPU) Dispatch(op uint16) error {
 			//
 			// If the most-significant bit of Vx is 1, then VF is
 			// set to 1, otherwise to 0. Then Vx is multiplied by 2.
+			//
+			// With the ShiftUsesVy quirk, Vy is shifted instead
+			// and the result is stored in Vx, so VF is the bit
+			// shifted out of Vy.
+
+			if c.Quirks.ShiftUsesVy {
+				c.V[x] = c.V[y]
+			}
 
 			var cf byte
 			if (c.V[x] & 0x80) == 0x80 {
 				cf = 1
 			}
-			c.V[0xF] = cf
+			c.setVF(op, cf)
 
 			c.V[x] = c.V[x] * 2
 
 			c.PC += 2
 
 			break
+		default:
+			return c.unknownOpcode(op)
 		}
 
 		break
@@ -552,10 +1437,10 @@ func (c *CPU) Dispatch(op uint16) error {
 	// Skips the next instruction if VX doesn't equal VY.
 	//   0x9XY0
 	case 0x9000:
-		x := (op & 0x0F00) >> 8
-		y := (op & 0x00F0) >> 4
+		x := uint16(d.X)
+		y := uint16(d.Y)
 
-		switch op & 0x000F {
+		switch d.N {
 		// 9xy0 - SNE Vx, Vy
 		case 0x0000:
 			// Skip next instruction if Vx != Vy.
@@ -570,7 +1455,7 @@ func (c *CPU) Dispatch(op uint16) error {
 
 			break
 		default:
-			return &UnknownOpcode{Opcode: op}
+			return c.unknownOpcode(op)
 		}
 
 		break
@@ -581,7 +1466,7 @@ func (c *CPU) Dispatch(op uint16) error {
 		//
 		// The value of register I is set to nnn.
 
-		c.I = op & 0x0FFF
+		c.I = d.NNN
 		c.PC += 2
 
 		break
@@ -592,7 +1477,13 @@ func (c *CPU) Dispatch(op uint16) error {
 		//
 		// The program counter is set to nnn plus the value of V0.
 
-		c.PC = op&0x0FFF + uint16(c.V[0])
+		// SUPER-CHIP uses the register in the high nibble instead.
+		r := 0
+		if c.Quirks.JumpUsesVx {
+			r = int(d.X)
+		}
+
+		c.PC = d.NNN + uint16(c.V[r])
 
 		break
 
@@ -604,10 +1495,10 @@ func (c *CPU) Dispatch(op uint16) error {
 		// which is then ANDed with the value kk. The results are stored
 		// in Vx. See instruction 8xy2 for more information on AND.
 
-		x := (op & 0x0F00) >> 8
-		kk := byte(op)
+		x := uint16(d.X)
+		kk := d.KK
 
-		c.V[x] = kk + randByte()
+		c.V[x] = kk & c.random()
 
 		c.PC += 2
 
@@ -632,29 +1523,57 @@ func (c *CPU) Dispatch(op uint16) error {
 		var cf byte
 
 		// The starting X coordinate on the graphics array.
-		x := c.V[(op&0x0F00)>>8]
+		x := c.V[d.X]
 
 		// The starting Y coordinate on the graphics array.
-		y := c.V[(op&0x00F0)>>4]
+		y := c.V[d.Y]
 
 		// The height of the sprite.
-		n := op & 0x000F
+		n := uint16(d.N)
 
-		if c.Graphics.WriteSprite(c.Memory[c.I:c.I+n], x, y) {
+		sprite, err := c.readBytes(int(c.I), int(n))
+		if err != nil {
+			return err
+		}
+
+		e := c.Graphics.edges()
+		e.clipX = e.clipX || c.Quirks.ClipX
+		e.clipY = e.clipY || c.Quirks.ClipY
+		e.wrapCollisions = !c.Quirks.IgnoreWrapCollisions
+
+		var collision bool
+		if c.CacheSprites {
+			rows := c.sprites.get(c.Memory, int(c.I), int(n))
+			collision, _ = c.Graphics.writeSprite(rows, x, y, e)
+		} else {
+			collision, _ = c.Graphics.writeSpriteBytes(sprite, x, y, e)
+		}
+
+		if collision {
 			cf = 0x01
+
+			if (c.TraceFirstCollision || c.BreakOnCollision) && !c.firstCollision.ok {
+				c.recordCollision(x, y)
+				c.collided = true
+			}
 		}
 
-		c.V[0xF] = cf
+		if collision || !c.Quirks.DrawVFUnchangedOnNoCollision {
+			c.setVF(op, cf)
+		}
 		c.PC += 2
 
-		c.Graphics.Draw()
+		c.drawn = true
+		if !c.DeferDraw {
+			c.draw()
+		}
 
 		break
 
 	case 0xE000:
-		x := (op & 0x0F00) >> 8
+		x := uint16(d.X)
 
-		switch op & 0x00FF {
+		switch d.KK {
 		// Ex9E - SKP Vx
 		case 0x9E:
 			// Skip next instruction if key with the value of Vx is
@@ -664,14 +1583,18 @@ func (c *CPU) Dispatch(op uint16) error {
 			// the value of Vx is currently in the down position, PC
 			// is increased by 2.
 
+			if err := c.checkKey(op, c.V[x]); err != nil {
+				return err
+			}
+
 			c.PC += 2
 
-			b, err := c.getKey()
+			pressed, err := c.keyPressed(c.V[x])
 			if err != nil {
 				return err
 			}
 
-			if c.V[x] == b {
+			if pressed {
 				c.PC += 2
 			}
 
@@ -686,34 +1609,42 @@ func (c *CPU) Dispatch(op uint16) error {
 			// the value of Vx is currently in the up position, PC
 			// is increased by 2.
 
+			if err := c.checkKey(op, c.V[x]); err != nil {
+				return err
+			}
+
 			c.PC += 2
 
-			b, err := c.getKey()
+			pressed, err := c.keyPressed(c.V[x])
 			if err != nil {
 				return err
 			}
 
-			if c.V[x] != b {
+			if !pressed {
 				c.PC += 2
 			}
 
 			break
 
 		default:
-			return &UnknownOpcode{Opcode: op}
+			return c.unknownOpcode(op)
 		}
 
 		break
 	case 0xF000:
-		x := (op & 0x0F00) >> 8
+		x := uint16(d.X)
 
-		switch op & 0x00FF {
+		switch d.KK {
 		// Fx07 - LD Vx, DT
 		case 0x07:
 			// Set Vx = delay timer value.
 			//
 			// The value of DT is placed into Vx.
 
+			if c.SkipDelayWaits {
+				c.skipDelayWait(x)
+			}
+
 			c.V[x] = c.DT
 			c.PC += 2
 
@@ -726,12 +1657,25 @@ func (c *CPU) Dispatch(op uint16) error {
 			//
 			// All execution stops until a key is pressed, then the
 			// value of that key is stored in Vx.
-
-			b, err := c.getKey()
+			//
+			// When the Keypad implements KeyState, the CPU doesn't
+			// block. Instead, PC isn't advanced so this instruction
+			// is executed again until a key is available. Each
+			// execution counts as an instruction, so Cycles keeps
+			// counting and the timers keep counting down at 60 Hz
+			// while waiting. Like the COSMAC VIP, which reads the
+			// keypad in its 60 Hz interrupt, the keypad is only
+			// polled once per frame.
+
+			b, ok, err := c.waitKey()
 			if err != nil {
 				return err
 			}
 
+			if !ok {
+				break
+			}
+
 			c.V[x] = b
 
 			c.PC += 2
@@ -755,6 +1699,10 @@ func (c *CPU) Dispatch(op uint16) error {
 			//
 			// ST is set equal to the value of Vx.
 
+			if c.ST == 0 && c.V[x] > 0 {
+				c.Beeps++
+			}
+
 			c.ST = c.V[x]
 			c.PC += 2
 
@@ -767,7 +1715,12 @@ func (c *CPU) Dispatch(op uint16) error {
 			// The values of I and Vx are added, and the results are
 			// stored in I.
 
-			c.I = c.I + uint16(c.V[x])
+			i := c.I + uint16(c.V[x])
+			if c.Strict && i > 0xFFF {
+				return c.violation(op, fmt.Sprintf("I overflowed to 0x%04X", i))
+			}
+
+			c.I = i
 
 			c.PC += 2
 
@@ -782,6 +1735,10 @@ func (c *CPU) Dispatch(op uint16) error {
 			// See section 2.4, Display, for more information on the
 			// Chip-8 hexadecimal font.
 
+			if c.Strict && c.V[x] > 0xF {
+				return c.violation(op, fmt.Sprintf("there's no font sprite for 0x%02X", c.V[x]))
+			}
+
 			c.I = uint16(c.V[x]) * uint16(0x05)
 
 			c.PC += 2
@@ -798,9 +1755,15 @@ func (c *CPU) Dispatch(op uint16) error {
 			// the tens digit at location I+1, and the ones digit at
 			// location I+2.
 
-			c.Memory[c.I] = c.V[x] / 100
-			c.Memory[c.I+1] = (c.V[x] / 10) % 10
-			c.Memory[c.I+2] = (c.V[x] % 100) % 10
+			bcd := []byte{
+				c.V[x] / 100,
+				(c.V[x] / 10) % 10,
+				(c.V[x] % 100) % 10,
+			}
+
+			if err := c.writeBytes(int(c.I), bcd); err != nil {
+				return err
+			}
 
 			c.PC += 2
 
@@ -813,9 +1776,20 @@ func (c *CPU) Dispatch(op uint16) error {
 			//
 			// The interpreter copies the values of registers V0
 			// through Vx into memory, starting at the address in I.
+			//
+			// Vx is included, so F055 stores V0 alone and FF55
+			// stores all 16 registers.
 
-			for i := 0; uint16(i) <= x; i++ {
-				c.Memory[c.I+uint16(i)] = c.V[i]
+			if err := c.writeBytes(int(c.I), c.V[:x&0xF+1]); err != nil {
+				return err
+			}
+
+			if err := c.persist(int(c.I), int(c.I)+int(x&0xF)); err != nil {
+				return err
+			}
+
+			if c.Quirks.LoadStoreIncrementsI {
+				c.I += x + 1
 			}
 
 			c.PC += 2
@@ -829,35 +1803,201 @@ func (c *CPU) Dispatch(op uint16) error {
 			//
 			// The interpreter reads values from memory starting at
 			// location I into registers V0 through Vx.
+			//
+			// Vx is included, so F065 loads V0 alone and FF65 loads
+			// all 16 registers.
 
-			for i := 0; byte(i) <= byte(x); i++ {
-				c.V[uint16(i)] = c.Memory[c.I+uint16(i)]
+			b, err := c.readBytes(int(c.I), int(x&0xF)+1)
+			if err != nil {
+				return err
+			}
+			copy(c.V[:], b)
+
+			if c.Quirks.LoadStoreIncrementsI {
+				c.I += x + 1
 			}
 
 			c.PC += 2
 
 			break
 		default:
-			return &UnknownOpcode{Opcode: op}
+			return c.unknownOpcode(op)
 		}
 	default:
-		return &UnknownOpcode{Opcode: op}
+		return c.unknownOpcode(op)
 	}
 
 	return nil
 }
 
-// op returns the next op code.
-func (c *CPU) decodeOp() uint16 {
-	return uint16(c.Memory[c.PC])<<8 | uint16(c.Memory[c.PC+1])
+// setVF sets the VF register to the carry, borrow or collision flag produced
+// by op.
+func (c *CPU) setVF(op uint16, v byte) {
+	c.V[0xF] = v
+
+	if c.TraceFlagWrites {
+		c.flagWrite = flagWrite{cycle: c.Cycles, op: op, value: v}
+	}
+}
+
+// LastFlagWrite returns the cycle, opcode and value of the most recent write
+// to VF by an arithmetic or draw opcode. Writes are only recorded when
+// TraceFlagWrites is enabled.
+func (c *CPU) LastFlagWrite() (cycle uint64, op uint16, value byte) {
+	return c.flagWrite.cycle, c.flagWrite.op, c.flagWrite.value
+}
+
+// FirstCollision returns the cycle and the coordinates of the first sprite
+// drawn with Dxyn that collided with lit pixels. It returns false if there
+// hasn't been a collision since TraceFirstCollision or BreakOnCollision was
+// enabled, or since the last reset.
+func (c *CPU) FirstCollision() (cycle uint64, x, y byte, ok bool) {
+	f := c.firstCollision
+	return f.cycle, f.x, f.y, f.ok
+}
+
+// recordCollision records the sprite at I, drawn at x, y, as the first
+// collision, and logs it when TraceFirstCollision is enabled.
+func (c *CPU) recordCollision(x, y byte) {
+	c.firstCollision = firstCollision{cycle: c.Cycles, x: x, y: y, ok: true}
+	if c.TraceFirstCollision {
+		c.logger().Printf("First collision: cycle=%d sprite=0x%03X x=%d y=%d\n", c.Cycles, c.I, x, y)
+	}
+}
+
+// firstCollision records the first Dxyn collision.
+type firstCollision struct {
+	cycle uint64
+	x, y  byte
+	ok    bool
+}
+
+// flagWrite records a write to VF.
+type flagWrite struct {
+	cycle uint64
+	op    uint16
+	value byte
+}
+
+// decodeOp returns the next opcode, split into its fields. Opcodes decoded
+// by Precompile are used as they are.
+func (c *CPU) decodeOp() (DecodedOp, error) {
+	if int(c.PC)+2 > len(c.Memory) {
+		if c.Strict {
+			return DecodedOp{}, ErrMemoryAccess
+		}
+
+		switch c.EndOfMemory {
+		case EndOfMemoryWrap:
+			c.PC = 0x200
+			break
+		case EndOfMemoryError:
+			return DecodedOp{}, ErrMemoryAccess
+		default:
+			return DecodedOp{}, ErrHalt
+		}
+	}
+
+	if d, ok := c.ops.get(c.PC); ok {
+		// The decoded op is cached, but the program still reads it.
+		c.traceReads(int(c.PC), c.Memory[c.PC:c.PC+2])
+		return d, nil
+	}
+
+	p, err := c.readBytes(int(c.PC), 2)
+	if err != nil {
+		return DecodedOp{}, err
+	}
+
+	d := decode(c.PC, uint16(p[0])<<8|uint16(p[1]))
+
+	if c.ops.ops != nil {
+		c.ops.put(d)
+	}
+
+	return d, nil
+}
+
+// readByte returns the byte at addr.
+func (c *CPU) readByte(addr int) (byte, error) {
+	if addr < 0 || addr >= len(c.Memory) {
+		return 0, ErrMemoryAccess
+	}
+
+	c.traceReads(addr, c.Memory[addr:addr+1])
+
+	return c.Memory[addr], nil
+}
+
+// readBytes returns the n bytes of memory starting at addr.
+func (c *CPU) readBytes(addr, n int) ([]byte, error) {
+	if addr < 0 || n < 0 || addr+n > len(c.Memory) {
+		return nil, ErrMemoryAccess
+	}
+
+	c.traceReads(addr, c.Memory[addr:addr+n])
+
+	return c.Memory[addr : addr+n], nil
+}
+
+// traceReads calls the MemReadTracer, if there is one, with each of the
+// bytes b that were read starting at addr.
+func (c *CPU) traceReads(addr int, b []byte) {
+	if c.MemReadTracer == nil {
+		return
+	}
+
+	for i, v := range b {
+		c.MemReadTracer(uint16(addr+i), v)
+	}
+}
+
+// writeByte stores b at addr.
+func (c *CPU) writeByte(addr int, b byte) error {
+	if addr < 0 || addr >= len(c.Memory) {
+		return ErrMemoryAccess
+	}
+
+	if c.MemWriteTracer != nil {
+		c.MemWriteTracer(uint16(addr), c.Memory[addr], b)
+	}
+
+	c.sprites.invalidate(addr)
+	c.ops.invalidate(addr)
+	c.idle.writes++
+
+	c.Memory[addr] = b
+	return nil
+}
+
+// writeBytes stores p starting at addr. Nothing is written unless all of p
+// fits in memory.
+func (c *CPU) writeBytes(addr int, p []byte) error {
+	if addr < 0 || addr+len(p) > len(c.Memory) {
+		return ErrMemoryAccess
+	}
+
+	for i, b := range p {
+		c.writeByte(addr+i, b)
+	}
+	return nil
 }
 
 func (c *CPU) getKey() (byte, error) {
 	c.logger().Println("Waiting for user input")
 
-	b, err := c.keypad().ReadByte()
+	var (
+		b   byte
+		err error
+	)
+	c.unlocked(func() {
+		b, err = c.keypad().ReadByte()
+	})
 	if err != nil {
-		if err == ErrQuit {
+		if err == ErrQuit || err == ErrReset {
+			return b, err
+		}
+		if _, ok := err.(*UnknownKey); ok {
 			return b, err
 		}
 
@@ -869,6 +2009,127 @@ func (c *CPU) getKey() (byte, error) {
 	return b, nil
 }
 
+// draw counts a frame and renders it to the Display, with the registers
+// unlocked. Like Graphics.Draw, errors from the Display are ignored.
+func (c *CPU) draw() {
+	c.Graphics.Frames++
+
+	d := c.display()
+	c.unlocked(func() {
+		c.Graphics.renderTo(d)
+	})
+}
+
+// pressed polls a KeyState keypad, with the registers unlocked.
+func (c *CPU) pressed(ks KeyState) (pressed uint16, err error) {
+	c.unlocked(func() {
+		pressed, err = ks.Pressed()
+	})
+	return
+}
+
+// keyPressed returns whether key is pressed. Keypads that implement KeyState
+// are checked without blocking; others are waited on for a key.
+func (c *CPU) keyPressed(key byte) (bool, error) {
+	if ks, ok := c.keypad().(KeyState); ok {
+		if c.LatchKeys && c.keyLatch.valid {
+			return key < 16 && c.keyLatch.pressed&(1<<key) != 0, nil
+		}
+
+		pressed, err := c.pressed(ks)
+		if err != nil {
+			return false, err
+		}
+
+		c.poll(pressed)
+
+		if c.LatchKeys {
+			c.keyLatch = keyLatch{valid: true, pressed: pressed}
+		}
+
+		return key < 16 && pressed&(1<<key) != 0, nil
+	}
+
+	b, err := c.getKey()
+	if err != nil {
+		return false, err
+	}
+
+	return b == key, nil
+}
+
+// waitKey returns the key for Fx0A. Keypads that implement KeyState are
+// polled without blocking, and ok is false until a key is available. A key
+// is only available once it's freshly pressed, so holding a key down only
+// satisfies the first Fx0A until it's released and pressed again. With the
+// KeyReleaseWait quirk, a key is only available once it's been pressed and
+// released.
+func (c *CPU) waitKey() (key byte, ok bool, err error) {
+	ks, isState := c.keypad().(KeyState)
+	if !isState {
+		b, err := c.getKey()
+		return b, err == nil, err
+	}
+
+	// The keypad has already been polled this frame.
+	if c.keyWait.polled {
+		return 0, false, nil
+	}
+
+	pressed, err := c.pressed(ks)
+	if err != nil {
+		return 0, false, err
+	}
+
+	c.poll(pressed)
+	c.keyWait.polled = true
+
+	if !c.Quirks.KeyReleaseWait {
+		// Only keys that have been pressed since the last Fx0A
+		// count, so a key that's held down doesn't satisfy every
+		// Fx0A that's executed while it's held.
+		fresh := pressed &^ c.keyWait.held
+		if fresh == 0 {
+			return 0, false, nil
+		}
+
+		c.keyWait = keyWait{held: pressed}
+
+		return lowestKey(fresh), true, nil
+	}
+
+	if !c.keyWait.pressed {
+		if pressed != 0 {
+			c.keyWait.pressed = true
+			c.keyWait.key = lowestKey(pressed)
+		}
+
+		return 0, false, nil
+	}
+
+	if pressed&(1<<c.keyWait.key) != 0 {
+		return 0, false, nil
+	}
+
+	key = c.keyWait.key
+	c.keyWait = keyWait{held: c.keyWait.held}
+
+	return key, true, nil
+}
+
+// keyWait tracks a key press that Fx0A is waiting to see released.
+type keyWait struct {
+	pressed bool
+	key     byte
+
+	// whether the keypad has been polled this frame.
+	polled bool
+
+	// the keys that were down when Fx0A last returned, and haven't been
+	// seen released since.
+	held uint16
+}
+
 func (c *CPU) keypad() Keypad {
 	if c.Keypad == nil {
 		return DefaultKeypad
@@ -885,6 +2146,24 @@ func (c *CPU) String() string {
 	)
 }
 
+// now returns the current time from the TimeSource.
+func (c *CPU) now() time.Time {
+	if c.timeSource == nil {
+		return now()
+	}
+
+	return c.timeSource.Now()
+}
+
+// random returns a random byte for Cxkk.
+func (c *CPU) random() byte {
+	if c.Rand == nil {
+		return randByte()
+	}
+
+	return byte(c.Rand.Intn(256))
+}
+
 // logger returns the logger to use for debugging.
 func (c *CPU) logger() *log.Logger {
 	if c.Logger == nil {
@@ -894,15 +2173,121 @@ func (c *CPU) logger() *log.Logger {
 	return c.Logger
 }
 
+// unknownOpcode returns an UnknownOpcode error for op, which is located at
+// the current PC.
+func (c *CPU) unknownOpcode(op uint16) *UnknownOpcode {
+	return &UnknownOpcode{
+		Opcode:  op,
+		PC:      c.PC,
+		Listing: disassembleAround(c.Memory, int(c.PC)),
+		Recent:  c.RecentInstructions(),
+	}
+}
+
+// skipUnknownOpcode logs the unknown opcode and moves on to the next
+// instruction, for SkipUnknownOpcodes.
+func (c *CPU) skipUnknownOpcode(err *UnknownOpcode) {
+	c.logger().Printf("skipping %s\n", err)
+	c.SkippedOpcodes++
+	c.PC += 2
+}
+
+// Violation is returned by a Strict CPU when a program does something that
+// the specification leaves undefined.
+type Violation struct {
+	Opcode uint16
+
+	// The address of the opcode.
+	PC uint16
+
+	// What the opcode did.
+	Reason string
+}
+
+func (e *Violation) Error() string {
+	return fmt.Sprintf("chip8: undefined behavior: 0x%04X at 0x%03X (%s)", e.Opcode, e.PC, e.Reason)
+}
+
+// violation returns a Violation for op at PC.
+func (c *CPU) violation(op uint16, reason string) *Violation {
+	return &Violation{Opcode: op, PC: c.PC, Reason: reason}
+}
+
+// checkKey returns a Violation in Strict mode if key isn't on the keypad.
+func (c *CPU) checkKey(op uint16, key byte) error {
+	if c.Strict && key > 0xF {
+		return c.violation(op, fmt.Sprintf("key 0x%02X isn't on the keypad", key))
+	}
+
+	return nil
+}
+
 // UnknownOpcode is return when the opcode is not recognized.
 type UnknownOpcode struct {
 	Opcode uint16
+
+	// The address of the opcode.
+	PC uint16
+
+	// A disassembly of the instructions surrounding the opcode, if
+	// available.
+	Listing string
+
+	// The instructions executed leading up to the opcode, including it,
+	// when Options.TraceSize is set.
+	Recent []TraceEntry
 }
 
 func (e *UnknownOpcode) Error() string {
-	return fmt.Sprintf("chip8: unknown opcode: 0x%04X", e.Opcode)
+	_, why := e.Family()
+
+	if e.Listing == "" {
+		return fmt.Sprintf("chip8: unknown opcode: 0x%04X (%s)", e.Opcode, why)
+	}
+
+	s := fmt.Sprintf("chip8: unknown opcode: 0x%04X at 0x%03X (%s)\n%s", e.Opcode, e.PC, why, e.Listing)
+
+	if len(e.Recent) > 0 {
+		s += "Recent instructions:\n"
+		for _, r := range e.Recent {
+			s += fmt.Sprintf("  %s\n", r)
+		}
+	}
+
+	return s
 }
 
+// Family returns the class of the opcode, which is its high nibble (e.g.
+// "0x5xxx"), and an explanation of why it couldn't be executed. Opcodes from
+// the SUPER-CHIP and XO-CHIP extensions are called out, since they're valid
+// for other interpreters.
+func (e *UnknownOpcode) Family() (family, why string) {
+	op := e.Opcode
+	family = fmt.Sprintf("0x%Xxxx", op>>12)
+
+	if ext := extension(op); ext != "" {
+		return family, fmt.Sprintf("%s instruction, which isn't implemented", ext)
+	}
+
+	switch op & 0xF000 {
+	case 0x0000:
+		why = "0nnn machine code routines aren't supported"
+	case 0x5000, 0x9000:
+		why = fmt.Sprintf("%s with a non-zero low nibble", family)
+	case 0x8000:
+		why = fmt.Sprintf("%s with an undefined low nibble", family)
+	case 0xE000, 0xF000:
+		why = fmt.Sprintf("%s with an undefined low byte", family)
+	default:
+		why = "not a CHIP-8 instruction"
+	}
+
+	return family, why
+}
+
+// now returns the current time. It's a variable so tests can control time.
+var now = time.Now
+
 // randByte returns a random value between 0 and 255.
 var randByte = func() byte {
 	return byte(rand.New(rand.NewSource(time.Now().UnixNano())).Intn(255))
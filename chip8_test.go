@@ -1,9 +1,15 @@
 package chip8
 
 import (
-	"crypto/sha256"
+	"bytes"
 	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func init() {
@@ -23,13 +29,27 @@ var opcodeTests = map[string][]struct {
 			0x2100,
 			nil,
 			func(t *testing.T, c *CPU) {
-				checkHex(t, "Stack[1]", c.Stack[1], 0x200)
+				checkHex(t, "Stack[0]", c.Stack[0], 0x200)
 				checkHex(t, "SP", c.SP, 0x1)
 				checkHex(t, "PC", c.PC, 0x100)
 			},
 		},
 	},
 
+	"00EE - RET": {
+		{
+			0x00EE,
+			func(t *testing.T, c *CPU) {
+				c.Stack[0] = 0x300
+				c.SP = 0x1
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "SP", c.SP, 0x0)
+				checkHex(t, "PC", c.PC, 0x302)
+			},
+		},
+	},
+
 	"3xkk - SE Vx, byte": {
 		{
 			0x3123,
@@ -122,6 +142,43 @@ var opcodeTests = map[string][]struct {
 				checkHex(t, "V[1]", c.V[1], 0x03)
 			},
 		},
+
+		// Overflow wraps, and doesn't set VF like 8xy4 does.
+		{
+			0x7102,
+			func(t *testing.T, c *CPU) {
+				c.V[1] = 0xFF
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "V[1]", c.V[1], 0x01)
+				checkHex(t, "V[F]", c.V[0xF], 0x00)
+			},
+		},
+
+		// VF is left alone, with or without an overflow.
+		{
+			0x7102,
+			func(t *testing.T, c *CPU) {
+				c.V[1] = 0xFF
+				c.V[0xF] = 0x01
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "V[1]", c.V[1], 0x01)
+				checkHex(t, "V[F]", c.V[0xF], 0x01)
+			},
+		},
+
+		{
+			0x7102,
+			func(t *testing.T, c *CPU) {
+				c.V[1] = 0x01
+				c.V[0xF] = 0x01
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "V[1]", c.V[1], 0x03)
+				checkHex(t, "V[F]", c.V[0xF], 0x01)
+			},
+		},
 	},
 
 	"8xy0 - LD Vx, Vy": {
@@ -147,6 +204,21 @@ var opcodeTests = map[string][]struct {
 				checkHex(t, "V[1]", c.V[1], 0x11)
 			},
 		},
+
+		// ResetVF quirk
+		{
+			0x8121,
+			func(t *testing.T, c *CPU) {
+				c.Quirks.ResetVF = true
+				c.V[1] = 0x10
+				c.V[2] = 0x01
+				c.V[0xF] = 0x01
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "V[1]", c.V[1], 0x11)
+				checkHex(t, "VF", c.V[0xF], 0x00)
+			},
+		},
 	},
 
 	"8xy2 - AND Vx, Vy": {
@@ -249,6 +321,49 @@ var opcodeTests = map[string][]struct {
 				checkHex(t, "V[1]", c.V[1], 0x1)
 			},
 		},
+
+		// ShiftUsesVy quirk
+		{
+			0x8126,
+			func(t *testing.T, c *CPU) {
+				c.Quirks.ShiftUsesVy = true
+				c.V[1] = 0x02
+				c.V[2] = 0x07
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "VF", c.V[0xF], 0x1)
+				checkHex(t, "V[1]", c.V[1], 0x3)
+				checkHex(t, "V[2]", c.V[2], 0x7)
+			},
+		},
+
+		// VF is the bit shifted out of Vy with the ShiftUsesVy
+		// quirk, and out of Vx without it.
+		{
+			0x8126,
+			func(t *testing.T, c *CPU) {
+				c.Quirks.ShiftUsesVy = true
+				c.V[1] = 0x01
+				c.V[2] = 0x06
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "VF", c.V[0xF], 0x0)
+				checkHex(t, "V[1]", c.V[1], 0x3)
+			},
+		},
+
+		{
+			0x8126,
+			func(t *testing.T, c *CPU) {
+				c.V[1] = 0x06
+				c.V[2] = 0x01
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "VF", c.V[0xF], 0x0)
+				checkHex(t, "V[1]", c.V[1], 0x3)
+				checkHex(t, "V[2]", c.V[2], 0x1)
+			},
+		},
 	},
 
 	"8xy7 - SUBN Vx, Vy": {
@@ -299,6 +414,49 @@ var opcodeTests = map[string][]struct {
 				checkHex(t, "V[1]", c.V[1], 0x2)
 			},
 		},
+
+		// ShiftUsesVy quirk
+		{
+			0x812E,
+			func(t *testing.T, c *CPU) {
+				c.Quirks.ShiftUsesVy = true
+				c.V[1] = 0x01
+				c.V[2] = 0x81
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "VF", c.V[0xF], 0x1)
+				checkHex(t, "V[1]", c.V[1], 0x2)
+				checkHex(t, "V[2]", c.V[2], 0x81)
+			},
+		},
+
+		// VF is the bit shifted out of Vy with the ShiftUsesVy
+		// quirk, and out of Vx without it.
+		{
+			0x812E,
+			func(t *testing.T, c *CPU) {
+				c.Quirks.ShiftUsesVy = true
+				c.V[1] = 0x80
+				c.V[2] = 0x41
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "VF", c.V[0xF], 0x0)
+				checkHex(t, "V[1]", c.V[1], 0x82)
+			},
+		},
+
+		{
+			0x812E,
+			func(t *testing.T, c *CPU) {
+				c.V[1] = 0x41
+				c.V[2] = 0x80
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "VF", c.V[0xF], 0x0)
+				checkHex(t, "V[1]", c.V[1], 0x82)
+				checkHex(t, "V[2]", c.V[2], 0x80)
+			},
+		},
 	},
 
 	"9xy0 - SNE Vx, Vy": {
@@ -353,15 +511,50 @@ var opcodeTests = map[string][]struct {
 				checkHex(t, "PC", c.PC, 0x211)
 			},
 		},
+
+		// V2 is ignored without the JumpUsesVx quirk.
+		{
+			0xB210,
+			func(t *testing.T, c *CPU) {
+				c.V[0] = 0x01
+				c.V[2] = 0x08
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "PC", c.PC, 0x211)
+			},
+		},
+
+		// JumpUsesVx quirk
+		{
+			0xB210,
+			func(t *testing.T, c *CPU) {
+				c.Quirks.JumpUsesVx = true
+				c.V[0] = 0x01
+				c.V[2] = 0x08
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "PC", c.PC, 0x218)
+			},
+		},
 	},
 
 	"Cxkk - RND Vx, byte": {
+		{
+			0xC1FF,
+			func(t *testing.T, c *CPU) {
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "V[1]", c.V[1], 0x01)
+			},
+		},
+
+		// The random byte is masked with kk.
 		{
 			0xC110,
 			func(t *testing.T, c *CPU) {
 			},
 			func(t *testing.T, c *CPU) {
-				checkHex(t, "V[1]", c.V[1], 0x11)
+				checkHex(t, "V[1]", c.V[1], 0x00)
 			},
 		},
 	},
@@ -379,6 +572,8 @@ var opcodeTests = map[string][]struct {
 			},
 		},
 
+		// A bit that isn't set doesn't collide with the lit pixel
+		// under it.
 		{
 			0xD001,
 			func(t *testing.T, c *CPU) {
@@ -388,6 +583,19 @@ var opcodeTests = map[string][]struct {
 			},
 			func(t *testing.T, c *CPU) {
 				checkHex(t, "Pixel", c.Pixels[0], 0x01)
+				checkHex(t, "VF", c.V[0xF], 0x0)
+			},
+		},
+
+		{
+			0xD001,
+			func(t *testing.T, c *CPU) {
+				c.I = 0x200
+				c.Memory[0x200] = 0x80
+				c.Pixels[0x0] = 0x01
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "Pixel", c.Pixels[0], 0x00)
 				checkHex(t, "VF", c.V[0xF], 0x1)
 			},
 		},
@@ -424,6 +632,133 @@ var opcodeTests = map[string][]struct {
 				checkGraphics(t, &c.Graphics, "796405cda1fa18bbd6e42dd2643af022793a37bc917b24c4bc8f88c242122a93")
 			},
 		},
+
+		// VF is cleared when there's no collision.
+		{
+			0xD001,
+			func(t *testing.T, c *CPU) {
+				c.I = 0x300
+				c.Memory[0x300] = 0x80
+				c.V[0xF] = 0x05
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "VF", c.V[0xF], 0x0)
+			},
+		},
+
+		// DrawVFUnchangedOnNoCollision quirk
+		{
+			0xD001,
+			func(t *testing.T, c *CPU) {
+				c.Quirks.DrawVFUnchangedOnNoCollision = true
+				c.I = 0x300
+				c.Memory[0x300] = 0x80
+				c.V[0xF] = 0x05
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "Pixel", c.Pixels[0], 0x01)
+				checkHex(t, "VF", c.V[0xF], 0x05)
+			},
+		},
+
+		// DrawVFUnchangedOnNoCollision quirk, with a collision
+		{
+			0xD001,
+			func(t *testing.T, c *CPU) {
+				c.Quirks.DrawVFUnchangedOnNoCollision = true
+				c.I = 0x300
+				c.Memory[0x300] = 0x80
+				c.Pixels[0] = 0x01
+				c.V[0xF] = 0x05
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "VF", c.V[0xF], 0x1)
+			},
+		},
+
+		// Collision with a wrapped pixel
+		{
+			0xD011,
+			func(t *testing.T, c *CPU) {
+				c.V[0] = 62
+				c.I = 0x300
+				c.Memory[0x300] = 0xFF
+				c.Pixels[0] = 0x01
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "Pixel", c.Pixels[0], 0x00)
+				checkHex(t, "VF", c.V[0xF], 0x1)
+			},
+		},
+
+		// Collision with a wrapped pixel, with the IgnoreWrapCollisions
+		// quirk
+		{
+			0xD011,
+			func(t *testing.T, c *CPU) {
+				c.Quirks.IgnoreWrapCollisions = true
+				c.V[0] = 62
+				c.I = 0x300
+				c.Memory[0x300] = 0xFF
+				c.Pixels[0] = 0x01
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "Pixel", c.Pixels[0], 0x00)
+				checkHex(t, "VF", c.V[0xF], 0x0)
+			},
+		},
+
+		// Collision within the sprite, with the IgnoreWrapCollisions
+		// quirk
+		{
+			0xD011,
+			func(t *testing.T, c *CPU) {
+				c.Quirks.IgnoreWrapCollisions = true
+				c.V[0] = 62
+				c.I = 0x300
+				c.Memory[0x300] = 0xFF
+				c.Pixels[63] = 0x01
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "Pixel", c.Pixels[63], 0x00)
+				checkHex(t, "VF", c.V[0xF], 0x1)
+			},
+		},
+
+		// A sprite off the right edge, with the ClipX quirk
+		{
+			0xD011,
+			func(t *testing.T, c *CPU) {
+				c.Quirks.ClipX = true
+				c.V[0] = 62
+				c.I = 0x300
+				c.Memory[0x300] = 0xFF
+				c.Pixels[0] = 0x01
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "Pixel", c.Pixels[63], 0x01)
+				checkHex(t, "Pixel", c.Pixels[0], 0x01)
+				checkHex(t, "VF", c.V[0xF], 0x0)
+			},
+		},
+
+		// A sprite off the bottom edge, with the ClipY quirk
+		{
+			0xD012,
+			func(t *testing.T, c *CPU) {
+				c.Quirks.ClipY = true
+				c.V[1] = 31
+				c.I = 0x300
+				c.Memory[0x300] = 0x80
+				c.Memory[0x301] = 0x80
+				c.Pixels[0] = 0x01
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "Pixel", c.Pixels[31*GraphicsWidth], 0x01)
+				checkHex(t, "Pixel", c.Pixels[0], 0x01)
+				checkHex(t, "VF", c.V[0xF], 0x0)
+			},
+		},
 	},
 
 	"Ex9E - SKP Vx": {
@@ -453,6 +788,32 @@ var opcodeTests = map[string][]struct {
 		},
 	},
 
+	"Ex9E - SKP Vx (KeyState)": {
+		{
+			0xE19E,
+			func(t *testing.T, c *CPU) {
+				c.V[0x01] = 0x02
+				c.Keypad = new(MemoryKeypad)
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "PC", c.PC, 0x202)
+			},
+		},
+
+		{
+			0xE19E,
+			func(t *testing.T, c *CPU) {
+				k := new(MemoryKeypad)
+				k.Press(0x02)
+				c.V[0x01] = 0x02
+				c.Keypad = k
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "PC", c.PC, 0x204)
+			},
+		},
+	},
+
 	"ExA1 - SKNP Vx": {
 		{
 			0xE1A1,
@@ -556,7 +917,7 @@ var opcodeTests = map[string][]struct {
 			func(t *testing.T, c *CPU) {
 				checkHex(t, "Memory[0x200]", c.Memory[0x200], 0x02)
 				checkHex(t, "Memory[0x201]", c.Memory[0x201], 0x05)
-				checkHex(t, "Memory[0x201]", c.Memory[0x201], 0x05)
+				checkHex(t, "Memory[0x202]", c.Memory[0x202], 0x05)
 			},
 		},
 	},
@@ -576,25 +937,55 @@ var opcodeTests = map[string][]struct {
 				checkHex(t, "Memory[0x222]", c.Memory[0x222], 0x01)
 			},
 		},
-	},
 
-	"Fx65 - LD Vx, [I]": {
+		// All registers
 		{
-			0xF165,
+			0xFF55,
 			func(t *testing.T, c *CPU) {
-				c.Memory[0x200] = 0x01
-				c.Memory[0x201] = 0x02
-				c.I = 0x200
+				for i := range c.V {
+					c.V[i] = byte(i + 1)
+				}
+				c.Memory[0x230] = 0xAA
+				c.I = 0x220
 			},
 			func(t *testing.T, c *CPU) {
-				checkHex(t, "V[0]", c.V[0], 0x01)
-				checkHex(t, "V[1]", c.V[1], 0x02)
-				checkHex(t, "V[2]", c.V[2], 0x00)
+				for i := 0; i < 16; i++ {
+					checkHex(t, fmt.Sprintf("Memory[0x%03X]", 0x220+i), c.Memory[0x220+i], i+1)
+				}
+				checkHex(t, "Memory[0x230]", c.Memory[0x230], 0xAA)
 			},
 		},
 
+		// LoadStoreIncrementsI quirk
 		{
-			0xF265,
+			0xF255,
+			func(t *testing.T, c *CPU) {
+				c.Quirks.LoadStoreIncrementsI = true
+				c.I = 0x220
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "I", c.I, 0x223)
+			},
+		},
+	},
+
+	"Fx65 - LD Vx, [I]": {
+		{
+			0xF165,
+			func(t *testing.T, c *CPU) {
+				c.Memory[0x200] = 0x01
+				c.Memory[0x201] = 0x02
+				c.I = 0x200
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "V[0]", c.V[0], 0x01)
+				checkHex(t, "V[1]", c.V[1], 0x02)
+				checkHex(t, "V[2]", c.V[2], 0x00)
+			},
+		},
+
+		{
+			0xF265,
 			func(t *testing.T, c *CPU) {
 				c.Memory[0x200] = 0x01
 				c.Memory[0x201] = 0x02
@@ -605,6 +996,35 @@ var opcodeTests = map[string][]struct {
 				checkHex(t, "V[1]", c.V[1], 0x02)
 			},
 		},
+
+		// All registers
+		{
+			0xFF65,
+			func(t *testing.T, c *CPU) {
+				for i := 0; i < 17; i++ {
+					c.Memory[0x220+i] = byte(i + 1)
+				}
+				c.I = 0x220
+			},
+			func(t *testing.T, c *CPU) {
+				for i := 0; i < 16; i++ {
+					checkHex(t, fmt.Sprintf("V[%d]", i), c.V[i], i+1)
+				}
+				checkHex(t, "I", c.I, 0x220)
+			},
+		},
+
+		// LoadStoreIncrementsI quirk
+		{
+			0xF265,
+			func(t *testing.T, c *CPU) {
+				c.Quirks.LoadStoreIncrementsI = true
+				c.I = 0x220
+			},
+			func(t *testing.T, c *CPU) {
+				checkHex(t, "I", c.I, 0x223)
+			},
+		},
 	},
 }
 
@@ -644,6 +1064,113 @@ func TestOpcodes(t *testing.T) {
 	}
 }
 
+// memoryOpcodeTests exercise the opcodes that move bytes between the
+// registers and memory at the edges of the address space and the registers.
+var memoryOpcodeTests = []struct {
+	op     uint16
+	quirks Quirks
+	I      uint16
+	V      []byte
+	memory []byte // memory at I before Fx65, or the bytes expected at I after a store.
+	err    error
+	wantI  uint16
+}{
+	// Fx33 - LD B, Vx
+	{op: 0xF033, I: 0x000, V: []byte{0}, memory: []byte{0, 0, 0}, wantI: 0x000},
+	{op: 0xF033, I: 0x300, V: []byte{9}, memory: []byte{0, 0, 9}, wantI: 0x300},
+	{op: 0xF033, I: 0x300, V: []byte{10}, memory: []byte{0, 1, 0}, wantI: 0x300},
+	{op: 0xF033, I: 0x300, V: []byte{100}, memory: []byte{1, 0, 0}, wantI: 0x300},
+	{op: 0xF033, I: 0x300, V: []byte{128}, memory: []byte{1, 2, 8}, wantI: 0x300},
+	{op: 0xFE33, I: 0xFFD, V: []byte{14: 255}, memory: []byte{2, 5, 5}, wantI: 0xFFD},
+	{op: 0xF033, I: 0xFFD, quirks: QuirksCOSMAC, V: []byte{42}, memory: []byte{0, 4, 2}, wantI: 0xFFD},
+	{op: 0xF033, I: 0xFFE, V: []byte{255}, err: ErrMemoryAccess, wantI: 0xFFE},
+	{op: 0xF033, I: 0xFFF, V: []byte{255}, err: ErrMemoryAccess, wantI: 0xFFF},
+
+	// Fx55 - LD [I], Vx
+	{op: 0xF055, I: 0x000, V: []byte{0xAB}, memory: []byte{0xAB}, wantI: 0x000},
+	{op: 0xF155, I: 0x300, V: []byte{0x12, 0x34}, memory: []byte{0x12, 0x34}, wantI: 0x300},
+	{op: 0xF155, I: 0x300, quirks: QuirksCOSMAC, V: []byte{0x12, 0x34}, memory: []byte{0x12, 0x34}, wantI: 0x302},
+	{op: 0xF155, I: 0x300, quirks: QuirksXOCHIP, V: []byte{0x12, 0x34}, memory: []byte{0x12, 0x34}, wantI: 0x302},
+	{op: 0xFF55, I: 0xFF0, V: sequence(16), memory: sequence(16), wantI: 0xFF0},
+	{op: 0xFF55, I: 0xFF0, quirks: QuirksCOSMAC, V: sequence(16), memory: sequence(16), wantI: 0x1000},
+	{op: 0xFF55, I: 0xFF1, V: sequence(16), err: ErrMemoryAccess, wantI: 0xFF1},
+	{op: 0xF055, I: 0xFFF, V: []byte{0xAB}, memory: []byte{0xAB}, wantI: 0xFFF},
+	{op: 0xF155, I: 0xFFF, quirks: QuirksCOSMAC, V: []byte{0xAB, 0xCD}, err: ErrMemoryAccess, wantI: 0xFFF},
+
+	// Fx65 - LD Vx, [I]
+	{op: 0xF065, I: 0x000, memory: []byte{0xF0}, V: []byte{0xF0, 0x00}, wantI: 0x000},
+	{op: 0xF165, I: 0x300, memory: []byte{0x12, 0x34, 0x56}, V: []byte{0x12, 0x34, 0x00}, wantI: 0x300},
+	{op: 0xF165, I: 0x300, quirks: QuirksCOSMAC, memory: []byte{0x12, 0x34, 0x56}, V: []byte{0x12, 0x34, 0x00}, wantI: 0x302},
+	{op: 0xFF65, I: 0xFF0, memory: sequence(16), V: sequence(16), wantI: 0xFF0},
+	{op: 0xFF65, I: 0xFF0, quirks: QuirksXOCHIP, memory: sequence(16), V: sequence(16), wantI: 0x1000},
+	{op: 0xFF65, I: 0xFF1, err: ErrMemoryAccess, wantI: 0xFF1},
+
+	// Annn - LD I, addr
+	{op: 0xA000, I: 0x123, wantI: 0x000},
+	{op: 0xA200, I: 0x123, wantI: 0x200},
+	{op: 0xAFFF, I: 0x123, wantI: 0xFFF},
+	{op: 0xAFFF, I: 0x123, quirks: QuirksCOSMAC, wantI: 0xFFF},
+}
+
+func TestMemoryOpcodes(t *testing.T) {
+	for i, tt := range memoryOpcodeTests {
+		t.Run(fmt.Sprintf("%d/%04X", i, tt.op), func(t *testing.T) {
+			c := newCPU(t)
+			c.Quirks = tt.quirks
+			c.I = tt.I
+
+			load := c.Memory[tt.I:]
+			if tt.op&0xF0FF == 0xF065 {
+				copy(load, tt.memory)
+			} else {
+				copy(c.V[:], tt.V)
+			}
+
+			// Everything outside of the bytes that are stored should
+			// be left alone.
+			want := append([]byte(nil), c.Memory...)
+			if tt.err == nil && tt.op&0xF0FF != 0xF065 {
+				copy(want[tt.I:], tt.memory)
+			}
+
+			err := c.Dispatch(tt.op)
+			if err != tt.err {
+				t.Fatalf("err => %v; want %v", err, tt.err)
+			}
+
+			checkHex(t, "I", c.I, tt.wantI)
+
+			// Nothing is stored when part of the range is outside of
+			// memory.
+			for addr := range want {
+				checkHex(t, fmt.Sprintf("Memory[0x%03X]", addr), c.Memory[addr], want[addr])
+			}
+
+			if tt.err != nil {
+				checkHex(t, "PC", c.PC, 0x200)
+				return
+			}
+
+			checkHex(t, "PC", c.PC, 0x202)
+
+			if tt.op&0xF0FF == 0xF065 {
+				for i, b := range tt.V {
+					checkHex(t, fmt.Sprintf("V[%d]", i), c.V[i], b)
+				}
+			}
+		})
+	}
+}
+
+// sequence returns the bytes 1 through n.
+func sequence(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i + 1)
+	}
+	return b
+}
+
 func TestCPU_Load(t *testing.T) {
 	c := newCPU(t)
 	p := []byte{0x01, 0x02}
@@ -661,56 +1188,1913 @@ func TestCPU_Load(t *testing.T) {
 	checkHex(t, "Memory[0x201]", c.Memory[0x201], 0x02)
 }
 
-func TestCPU_decodeOp(t *testing.T) {
+func TestCPU_Run_Quit(t *testing.T) {
 	c := newCPU(t)
-	c.Memory[0x200] = 0xA2
-	c.Memory[0x201] = 0xF0
+	c.Clock = nil
+	c.Keypad = new(ScriptedKeypad)
+	c.LoadBytes([]byte{
+		0xF0, 0x0A, // LD V0, K
+	})
+
+	if err := c.Run(); err != nil {
+		t.Fatalf("Run() => %v; want nil", err)
+	}
 
-	checkHex(t, "op", c.decodeOp(), 0xA2F0)
+	c.PC = 0x200
+	if err := c.RunSteps(1); err != nil {
+		t.Fatalf("RunSteps() => %v; want nil", err)
+	}
 }
 
-func newCPU(t testing.TB) *CPU {
-	c, err := NewCPU(nil)
+func TestCPU_Run_Error(t *testing.T) {
+	c := newCPU(t)
+	c.Clock = nil
+	c.LoadBytes([]byte{
+		0xFF, 0xFF,
+	})
+
+	if _, ok := c.Run().(*UnknownOpcode); !ok {
+		t.Fatal("Expected Run to return the UnknownOpcode error")
+	}
+}
+
+func TestNewCPU_EntryPoint(t *testing.T) {
+	c, err := NewCPU(&Options{
+		ClockSpeed:  DefaultClockSpeed,
+		Unthrottled: true,
+		EntryPoint:  0x600,
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
-	return c
+
+	c.Memory[0x600] = 0x61
+	c.Memory[0x601] = 0x23
+
+	op, err := c.Step()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkHex(t, "op", op, 0x6123)
+	checkHex(t, "PC", c.PC, 0x602)
+
+	if _, err := NewCPU(&Options{EntryPoint: 0xFFF, Unthrottled: true}); err == nil {
+		t.Fatal("Expected an error for an entry point at the end of memory")
+	}
 }
 
-func tryUint16(v interface{}) uint16 {
-	switch v := v.(type) {
-	case byte:
-		return uint16(v)
-	case uint16:
-		return v
-	case int:
-		return uint16(v)
-	case uint32:
-		return uint16(v)
+func TestCPU_Reset(t *testing.T) {
+	run := func(reset func(*CPU)) *CPU {
+		c := newHeadlessCPU(t, loadProgram(t, "pong.ch8"), new(MemoryDisplay))
+		if err := c.RunSteps(500); err != nil {
+			t.Fatal(err)
+		}
+		reset(c)
+		return c
 	}
 
-	return 0
+	soft := run((*CPU).SoftReset)
+	full := run((*CPU).Reset)
+
+	for _, c := range []*CPU{soft, full} {
+		checkHex(t, "PC", c.PC, 0x200)
+		checkHex(t, "I", c.I, 0x000)
+		checkHex(t, "SP", c.SP, 0x00)
+		checkHex(t, "DT", c.DT, 0x00)
+		checkHex(t, "ST", c.ST, 0x00)
+		if c.V != [16]byte{} {
+			t.Errorf("Expected the registers to be cleared, got %v", c.V)
+		}
+		for _, addr := range c.Stack {
+			if addr != 0 {
+				t.Errorf("Expected the stack to be cleared, got %v", c.Stack)
+				break
+			}
+		}
+	}
+
+	if string(soft.Memory) != string(full.Memory) {
+		t.Error("Expected memory to be the same after a soft and full reset")
+	}
+	if string(full.Memory[0x200:0x202]) != string(loadProgram(t, "pong.ch8")[:2]) {
+		t.Error("Expected the program to be kept")
+	}
+
+	if soft.Pixels == [HiResWidth * HiResHeight]byte{} {
+		t.Error("Expected SoftReset to keep the graphics array")
+	}
+	if full.Pixels != [HiResWidth * HiResHeight]byte{} {
+		t.Error("Expected Reset to clear the graphics array")
+	}
 }
 
-func checkHex(t *testing.T, subject string, got, want interface{}) {
-	t.Helper()
+func TestCPU_Reload(t *testing.T) {
+	c, err := NewCPU(&Options{
+		ClockSpeed:  DefaultClockSpeed,
+		Unthrottled: true,
+		Banks:       2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Graphics.Display = NullDisplay
+	c.CacheSprites = true
+	c.Precompile()
+
+	// The program stores a sprite and draws it, which caches it.
+	if _, err := c.LoadBytes([]byte{
+		0x60, 0xFF, // LD V0, 0xFF
+		0xA3, 0x00, // LD I, 0x300
+		0xF0, 0x55, // LD [I], V0
+		0xD1, 0x11, // DRW V1, V1, 1
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RunSteps(4); err != nil {
+		t.Fatal(err)
+	}
+	c.banks[1][0x300] = 0xBB
 
-	g := tryUint16(got)
-	w := tryUint16(want)
+	// The new program draws from the same address, which is cleared.
+	if _, err := c.Reload([]byte{
+		0xA3, 0x00, // LD I, 0x300
+		0xD1, 0x11, // DRW V1, V1, 1
+	}); err != nil {
+		t.Fatal(err)
+	}
 
-	if g != w {
-		t.Errorf("%s => 0x%04X; want 0x%04X", subject, g, w)
+	for bank, m := range c.banks {
+		checkHex(t, fmt.Sprintf("bank %d 0x300", bank), m[0x300], 0x00)
+		checkHex(t, fmt.Sprintf("bank %d 0x000", bank), m[0x000], FontSet[0])
+	}
+	checkHex(t, "0x206", c.Memory[0x206], 0x00)
+
+	if err := c.RunSteps(2); err != nil {
+		t.Fatal(err)
+	}
+	if c.Pixels != [HiResWidth * HiResHeight]byte{} {
+		t.Error("Expected the cleared sprite to be drawn, not the cached one")
 	}
 }
 
-func checkGraphics(t *testing.T, g *Graphics, hash string) {
-	t.Helper()
+func TestCPU_StackSize(t *testing.T) {
+	c, err := NewCPU(&Options{
+		ClockSpeed:  DefaultClockSpeed,
+		Unthrottled: true,
+		StackSize:   12,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	h := fmt.Sprintf("%x", sha256.Sum256(g.Pixels[:]))
+	// A subroutine that calls itself.
+	c.LoadBytes([]byte{
+		0x22, 0x00, // CALL 0x200
+	})
 
-	if h != hash {
-		t.Errorf("Expected graphics hash to be %s, got %s", hash, h)
-		t.Log("Graphics Array:")
-		t.Log(g.Pixels)
+	for i := 0; i < 12; i++ {
+		if _, err := c.Step(); err != nil {
+			t.Fatalf("CALL %d: %v", i+1, err)
+		}
+	}
+	checkHex(t, "SP", c.SP, 12)
+
+	if _, err := c.Step(); err != ErrStackOverflow {
+		t.Fatalf("err => %v; want %v", err, ErrStackOverflow)
+	}
+
+	c.SP = 0
+	if err := c.Dispatch(0x00EE); err != ErrStackUnderflow {
+		t.Fatalf("err => %v; want %v", err, ErrStackUnderflow)
+	}
+
+	if _, err := NewCPU(&Options{StackSize: 256, Unthrottled: true}); err == nil {
+		t.Fatal("Expected an error for a stack that SP can't address")
+	}
+}
+
+func TestCPU_Exit(t *testing.T) {
+	d := new(MemoryDisplay)
+	c := newHeadlessCPU(t, []byte{
+		0x00, 0xE0, // CLS
+		0x00, 0xFD, // EXIT
+		0x12, 0x00, // JP 0x200
+	}, d)
+
+	c.Step()
+	if _, err := c.Step(); err != ErrExit {
+		t.Fatalf("err => %v; want %v", err, ErrExit)
+	}
+	checkHex(t, "PC", c.PC, 0x202)
+
+	// Exiting is a clean exit for Run and RunSteps, not a failure.
+	c = newHeadlessCPU(t, []byte{
+		0x00, 0xFD, // EXIT
+	}, d)
+	if err := c.RunSteps(10); err != nil {
+		t.Fatalf("RunSteps() => %v; want nil", err)
+	}
+	if err := c.Run(); err != nil {
+		t.Fatalf("Run() => %v; want nil", err)
+	}
+	checkHex(t, "PC", c.PC, 0x200)
+
+	if issues := Validate([]byte{0x00, 0xFD}, Quirks{}); len(issues) != 0 {
+		t.Errorf("Validate() => %v; want no issues", issues)
+	}
+}
+
+func TestCPU_Strict(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(*CPU)
+		op     uint16
+		reason string
+	}{
+		{"Key", func(c *CPU) { c.V[1] = 0x10 }, 0xE19E, "key 0x10 isn't on the keypad"},
+		{"Key", func(c *CPU) { c.V[1] = 0xFF }, 0xE1A1, "key 0xFF isn't on the keypad"},
+		{"Font", func(c *CPU) { c.V[1] = 0x10 }, 0xF129, "there's no font sprite for 0x10"},
+		{"I", func(c *CPU) { c.I = 0xFFF; c.V[1] = 0x01 }, 0xF11E, "I overflowed to 0x1000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, strict := range []bool{false, true} {
+				c := newCPU(t)
+				c.Keypad = new(MemoryKeypad)
+				c.Strict = strict
+				tt.setup(c)
+
+				err := c.Dispatch(tt.op)
+
+				if !strict {
+					if err != nil {
+						t.Errorf("0x%04X: err => %v; want it to be tolerated", tt.op, err)
+					}
+					continue
+				}
+
+				v, ok := err.(*Violation)
+				if !ok {
+					t.Fatalf("0x%04X: err => %v; want a Violation", tt.op, err)
+				}
+				if v.Reason != tt.reason {
+					t.Errorf("0x%04X: Reason => %q; want %q", tt.op, v.Reason, tt.reason)
+				}
+				checkHex(t, "PC", c.PC, 0x200)
+			}
+		})
+	}
+
+	// Running off the end of memory is an error, regardless of the
+	// policy.
+	for _, policy := range []EndOfMemory{EndOfMemoryHalt, EndOfMemoryWrap} {
+		c, err := NewCPU(&Options{
+			ClockSpeed:  DefaultClockSpeed,
+			Unthrottled: true,
+			EndOfMemory: policy,
+			Strict:      true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.PC = 0xFFF
+
+		if _, err := c.Step(); err != ErrMemoryAccess {
+			t.Errorf("EndOfMemory(%d): err => %v; want %v", policy, err, ErrMemoryAccess)
+		}
+	}
+
+	// The errors that are always errors, like unknown opcodes and stack
+	// underflows, still are.
+	c := newCPU(t)
+	c.Strict = true
+	for _, op := range []uint16{0x5121, 0x8128, 0x812D} {
+		if _, ok := c.Dispatch(op).(*UnknownOpcode); !ok {
+			t.Errorf("Expected unknown opcode 0x%04X to fail", op)
+		}
+		checkHex(t, "PC", c.PC, 0x200)
+	}
+	if err := c.Dispatch(0x00EE); err != ErrStackUnderflow {
+		t.Errorf("err => %v; want %v", err, ErrStackUnderflow)
+	}
+
+	// Unknown opcodes aren't skipped in Strict mode.
+	c = newHeadlessCPU(t, []byte{0x81, 0x28}, NullDisplay)
+	c.Strict = true
+	c.SkipUnknownOpcodes = true
+	if _, err := c.Step(); err == nil {
+		t.Error("Expected the unknown opcode not to be skipped")
+	}
+	checkHex(t, "PC", c.PC, 0x200)
+
+	v := &Violation{Opcode: 0xF129, PC: 0x204, Reason: "there's no font sprite for 0x10"}
+	if got, want := v.Error(), "chip8: undefined behavior: 0xF129 at 0x204 (there's no font sprite for 0x10)"; got != want {
+		t.Errorf("Error() => %q; want %q", got, want)
+	}
+}
+
+func TestCPU_SkipDelayWaits(t *testing.T) {
+	p := []byte{
+		0x60, 0x3C, // LD V0, 0x3C
+		0xF0, 0x15, // LD DT, V0
+		0x60, 0x50, // LD V0, 0x50
+		0xF0, 0x18, // LD ST, V0
+		0xF1, 0x07, // LD V1, DT
+		0x31, 0x00, // SE V1, 0x00
+		0x12, 0x08, // JP 0x208
+		0x62, 0x01, // LD V2, 0x01
+		0x00, 0xFD, // EXIT
+	}
+
+	tests := []struct {
+		skip      bool
+		maxCycles uint64
+	}{
+		{false, 250},
+		{true, 20},
+	}
+
+	var st []byte
+	for _, tt := range tests {
+		c := newHeadlessCPU(t, p, nil)
+		c.SkipDelayWaits = tt.skip
+
+		if err := c.RunSteps(1000); err != nil {
+			t.Fatal(err)
+		}
+
+		checkHex(t, "V[2]", c.V[2], 0x01)
+		checkHex(t, "DT", c.DT, 0x00)
+
+		if c.Cycles > tt.maxCycles {
+			t.Errorf("SkipDelayWaits(%v): Cycles => %d; want at most %d", tt.skip, c.Cycles, tt.maxCycles)
+		}
+
+		st = append(st, c.ST)
+	}
+
+	// The sound timer counted down as much as it would have without
+	// skipping.
+	if st[0] != st[1] {
+		t.Errorf("ST => 0x%02X; want 0x%02X", st[1], st[0])
+	}
+}
+
+func TestCPU_EndOfMemory(t *testing.T) {
+	tests := []struct {
+		policy EndOfMemory
+		err    error
+		pc     uint16
+	}{
+		{EndOfMemoryHalt, ErrHalt, 0x1000},
+		{EndOfMemoryWrap, nil, 0x202},
+		{EndOfMemoryError, ErrMemoryAccess, 0x1000},
+	}
+
+	for _, tt := range tests {
+		c, err := NewCPU(&Options{
+			ClockSpeed:  DefaultClockSpeed,
+			Unthrottled: true,
+			EndOfMemory: tt.policy,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c.Memory[0x200] = 0x61 // LD V1, 0x02
+		c.Memory[0x201] = 0x02
+		c.Memory[0xFFE] = 0x60 // LD V0, 0x01
+		c.Memory[0xFFF] = 0x01
+		c.PC = 0xFFE
+
+		if _, err := c.Step(); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := c.Step(); err != tt.err {
+			t.Errorf("EndOfMemory(%d): err => %v; want %v", tt.policy, err, tt.err)
+		}
+
+		checkHex(t, "PC", c.PC, tt.pc)
+	}
+
+	// Running off the end is a clean exit for Run.
+	c := newCPU(t)
+	c.Clock = nil
+	c.PC = 0x1000
+	if err := c.Run(); err != nil {
+		t.Fatalf("Run() => %v; want nil", err)
+	}
+}
+
+func TestCPU_Jump(t *testing.T) {
+	c := newCPU(t)
+
+	if err := c.Jump(0x300); err != nil {
+		t.Fatal(err)
+	}
+	checkHex(t, "PC", c.PC, 0x300)
+
+	if err := c.Jump(0xFFF); err != ErrMemoryAccess {
+		t.Fatalf("err => %v; want %v", err, ErrMemoryAccess)
+	}
+	checkHex(t, "PC", c.PC, 0x300)
+}
+
+func TestCPU_IdleThreshold(t *testing.T) {
+	var sleeps int
+	sleep = func(d time.Duration) {
+		if d != DefaultIdleSleep {
+			t.Fatalf("slept for %v; want %v", d, DefaultIdleSleep)
+		}
+		sleeps++
+	}
+	defer func() { sleep = time.Sleep }()
+
+	k := new(MemoryKeypad)
+	c := newHeadlessCPU(t, []byte{
+		0xF0, 0x0A, // LD V0, K
+		0x12, 0x00, // JP 0x200
+	}, new(MemoryDisplay))
+	c.Keypad = k
+	c.IdleThreshold = 10
+
+	// The first instruction establishes the state.
+	if err := c.RunSteps(11); err != nil {
+		t.Fatal(err)
+	}
+	if sleeps != 1 {
+		t.Fatalf("%d sleeps after the threshold; want 1", sleeps)
+	}
+
+	if err := c.RunSteps(5); err != nil {
+		t.Fatal(err)
+	}
+	if sleeps != 6 {
+		t.Fatalf("%d sleeps while idle; want 6", sleeps)
+	}
+
+	// Pressing a key is a change, so the CPU stops sleeping.
+	k.Press(0x5)
+	if err := c.RunSteps(5); err != nil {
+		t.Fatal(err)
+	}
+	if sleeps != 6 {
+		t.Fatalf("%d sleeps after a key press; want 6", sleeps)
+	}
+	checkHex(t, "V[0]", c.V[0], 0x5)
+}
+
+func TestCPU_MemWriteTracer(t *testing.T) {
+	type write struct {
+		addr          uint16
+		before, after byte
+	}
+
+	var writes []write
+
+	c := newCPU(t)
+	c.MemWriteTracer = func(addr uint16, before, after byte) {
+		writes = append(writes, write{addr, before, after})
+	}
+
+	if _, err := c.LoadBytes([]byte{0xF2, 0x55}); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Memory[0x301] = 0xAA
+	c.V[0] = 0x01
+	c.V[1] = 0x02
+	c.V[2] = 0x03
+	c.I = 0x300
+
+	if _, err := c.Step(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []write{
+		// Load
+		{0x200, 0x00, 0xF2},
+		{0x201, 0x00, 0x55},
+
+		// Fx55
+		{0x300, 0x00, 0x01},
+		{0x301, 0xAA, 0x02},
+		{0x302, 0x00, 0x03},
+	}
+
+	if len(writes) != len(want) {
+		t.Fatalf("%d writes; want %d: %v", len(writes), len(want), writes)
+	}
+
+	for i := range want {
+		if writes[i] != want[i] {
+			t.Errorf("writes[%d] => %+v; want %+v", i, writes[i], want[i])
+		}
+	}
+}
+
+func TestCPU_LoadStore_Inclusive(t *testing.T) {
+	// Fx55 and Fx65 transfer V0 through Vx, including Vx, for every x.
+	// Memory and registers past Vx are left alone.
+	const fill = 0xEE
+
+	for x := 0; x <= 0xF; x++ {
+		t.Run(fmt.Sprintf("F%X55", x), func(t *testing.T) {
+			c := newCPU(t)
+			c.I = 0x300
+			for i := range c.V {
+				c.V[i] = byte(0x10 + i)
+			}
+			for i := 0; i <= 0x10; i++ {
+				c.Memory[0x300+i] = fill
+			}
+
+			if err := c.Dispatch(0xF055 | uint16(x)<<8); err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i <= 0x10; i++ {
+				want := byte(fill)
+				if i <= x {
+					want = byte(0x10 + i)
+				}
+				checkHex(t, fmt.Sprintf("Memory[0x%X]", 0x300+i), c.Memory[0x300+i], want)
+			}
+		})
+
+		t.Run(fmt.Sprintf("F%X65", x), func(t *testing.T) {
+			c := newCPU(t)
+			c.I = 0x300
+			for i := range c.V {
+				c.V[i] = fill
+				c.Memory[0x300+i] = byte(0x80 + i)
+			}
+
+			if err := c.Dispatch(0xF065 | uint16(x)<<8); err != nil {
+				t.Fatal(err)
+			}
+
+			for i := range c.V {
+				want := byte(fill)
+				if i <= x {
+					want = byte(0x80 + i)
+				}
+				checkHex(t, fmt.Sprintf("V[%X]", i), c.V[i], want)
+			}
+		})
+	}
+}
+
+func TestCPU_MemReadTracer(t *testing.T) {
+	type read struct {
+		addr  uint16
+		value byte
+	}
+
+	var reads []read
+
+	c := newHeadlessCPU(t, []byte{
+		0xA3, 0x00, // LD I, 0x300
+		0xF1, 0x65, // LD V1, [I]
+		0xA3, 0x10, // LD I, 0x310
+		0xD0, 0x02, // DRW V0, V0, 2
+	}, NullDisplay)
+	c.MemReadTracer = func(addr uint16, value byte) {
+		reads = append(reads, read{addr, value})
+	}
+
+	c.Memory[0x300] = 0x11
+	c.Memory[0x301] = 0x22
+	c.Memory[0x310] = 0xF0
+	c.Memory[0x311] = 0x90
+
+	if err := c.RunSteps(4); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []read{
+		{0x200, 0xA3}, {0x201, 0x00},
+
+		// Fx65
+		{0x202, 0xF1}, {0x203, 0x65},
+		{0x300, 0x11}, {0x301, 0x22},
+
+		{0x204, 0xA3}, {0x205, 0x10},
+
+		// Dxyn
+		{0x206, 0xD0}, {0x207, 0x02},
+		{0x310, 0xF0}, {0x311, 0x90},
+	}
+
+	if len(reads) != len(want) {
+		t.Fatalf("%d reads; want %d: %v", len(reads), len(want), reads)
+	}
+
+	for i := range want {
+		if reads[i] != want[i] {
+			t.Errorf("reads[%d] => %+v; want %+v", i, reads[i], want[i])
+		}
+	}
+}
+
+func TestCPU_CacheSprites(t *testing.T) {
+	c := newHeadlessCPU(t, []byte{
+		0xA3, 0x00, // LD I, 0x300
+		0xD0, 0x01, // DRW V0, V0, 1
+		0x60, 0x0F, // LD V0, 0x0F
+		0xF0, 0x55, // LD [I], V0
+		0x60, 0x00, // LD V0, 0x00
+		0x00, 0xE0, // CLS
+		0xD0, 0x01, // DRW V0, V0, 1
+	}, new(MemoryDisplay))
+	c.CacheSprites = true
+	c.Memory[0x300] = 0xF0
+
+	if err := c.RunSteps(2); err != nil {
+		t.Fatal(err)
+	}
+	checkHex(t, "Pixels[0]", c.Pixels[0], 0x01)
+	checkHex(t, "Pixels[4]", c.Pixels[4], 0x00)
+
+	// The program rewrites its sprite, so the cached rows are stale.
+	if err := c.RunSteps(5); err != nil {
+		t.Fatal(err)
+	}
+	checkHex(t, "Pixels[0]", c.Pixels[0], 0x00)
+	checkHex(t, "Pixels[4]", c.Pixels[4], 0x01)
+}
+
+func TestCPU_Stats(t *testing.T) {
+	c := newHeadlessCPU(t, []byte{
+		0x60, 0x05, // LD V0, 0x05
+		0xF0, 0x18, // LD ST, V0
+		0xF0, 0x18, // LD ST, V0
+		0xD0, 0x05, // DRW V0, V0, 5
+		0xD0, 0x05, // DRW V0, V0, 5
+	}, new(MemoryDisplay))
+
+	if err := c.RunSteps(5); err != nil {
+		t.Fatal(err)
+	}
+
+	want := Stats{Cycles: 5, Frames: 2, Collisions: 1, Beeps: 1, Beeping: true}
+	if got := c.Stats(); got != want {
+		t.Fatalf("Stats() => %+v; want %+v", got, want)
+	}
+}
+
+func TestCPU_Beeping(t *testing.T) {
+	c := newHeadlessCPU(t, []byte{
+		0x60, 0x02, // LD V0, 0x02
+		0xF0, 0x18, // LD ST, V0
+		0x12, 0x04, // JP 0x204
+	}, new(MemoryDisplay))
+
+	// Each step is followed by whether the buzzer should be on.
+	for i, want := range []bool{false, true, false, false} {
+		if _, err := c.Step(); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := c.Beeping(); got != want {
+			t.Fatalf("step %d: Beeping() => %v; want %v", i, got, want)
+		}
+	}
+}
+
+func TestCPU_RunUntil(t *testing.T) {
+	p := []byte{
+		0x60, 0x00, // LD V0, 0x00
+		0x70, 0x01, // ADD V0, 0x01
+		0x30, 0x05, // SE V0, 0x05
+		0x12, 0x02, // JP 0x202
+		0x12, 0x08, // JP 0x208
+	}
+
+	c := newHeadlessCPU(t, p, new(MemoryDisplay))
+
+	if err := c.RunUntil(func(c *CPU) bool { return c.PC == 0x208 }, 100); err != nil {
+		t.Fatal(err)
+	}
+	checkHex(t, "V[0]", c.V[0], 0x05)
+	checkHex(t, "Cycles", c.Cycles, 1+5*3-1)
+
+	// It's already there.
+	if err := c.RunUntil(func(c *CPU) bool { return c.PC == 0x208 }, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	c = newHeadlessCPU(t, p, new(MemoryDisplay))
+
+	if err := c.RunUntil(func(c *CPU) bool { return c.V[0xF] != 0 }, 10); err != ErrCycleBudget {
+		t.Fatalf("err => %v; want %v", err, ErrCycleBudget)
+	}
+	checkHex(t, "Cycles", c.Cycles, 10)
+}
+
+func TestCPU_StepOver(t *testing.T) {
+	c := newHeadlessCPU(t, []byte{
+		0x22, 0x06, // 0x200: CALL 0x206
+		0x60, 0x10, // 0x202: LD V0, 0x10
+		0x12, 0x04, // 0x204: JP 0x204
+		0x70, 0x01, // 0x206: ADD V0, 0x01
+		0x22, 0x0C, // 0x208: CALL 0x20C
+		0x00, 0xEE, // 0x20A: RET
+		0x71, 0x01, // 0x20C: ADD V1, 0x01
+		0x00, 0xEE, // 0x20E: RET
+	}, NullDisplay)
+
+	// The whole subroutine runs, including the one it calls.
+	if err := c.StepOver(); err != nil {
+		t.Fatal(err)
+	}
+	checkHex(t, "PC", c.PC, 0x202)
+	checkHex(t, "SP", c.SP, 0x00)
+	checkHex(t, "V0", c.V[0], 0x01)
+	checkHex(t, "V1", c.V[1], 0x01)
+	checkHex(t, "Cycles", c.Cycles, 6)
+
+	// Anything else is a single step.
+	if err := c.StepOver(); err != nil {
+		t.Fatal(err)
+	}
+	checkHex(t, "PC", c.PC, 0x204)
+	checkHex(t, "V0", c.V[0], 0x10)
+	checkHex(t, "Cycles", c.Cycles, 7)
+}
+
+func TestCPU_frame(t *testing.T) {
+	c, err := NewCPU(&Options{
+		ClockSpeed:  240,
+		Unthrottled: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// At 240 Hz, there are 4 instructions per frame.
+	c.DT = 2
+	for i, want := range []byte{2, 2, 2, 1, 1, 1, 1, 0} {
+		c.frame()
+		if c.DT != want {
+			t.Fatalf("instruction %d: DT => %d; want %d", i+1, c.DT, want)
+		}
+	}
+}
+
+func TestCPU_LatchKeys(t *testing.T) {
+	k := new(MemoryKeypad)
+
+	c, err := NewCPU(&Options{
+		ClockSpeed:  240,
+		Unthrottled: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Keypad = k
+	c.LatchKeys = true
+
+	check := func(want bool) {
+		t.Helper()
+
+		// Instructions run with the registers locked.
+		c.regMu.Lock()
+		pressed, err := c.keyPressed(0x1)
+		c.regMu.Unlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pressed != want {
+			t.Fatalf("keyPressed(0x1) => %v; want %v", pressed, want)
+		}
+	}
+
+	check(false)
+
+	// The key is pressed mid frame, but reads see the snapshot until the
+	// frame ends.
+	k.Press(0x1)
+	for i := 0; i < 3; i++ {
+		check(false)
+		c.frame()
+	}
+	check(false)
+	c.frame()
+	check(true)
+
+	// Without the latch, reads see the keys as they are.
+	c.LatchKeys = false
+	k.Release(0x1)
+	check(false)
+}
+
+func TestCPU_Load_TooLarge(t *testing.T) {
+	p := bytes.Repeat([]byte{0xAA}, DefaultMemorySize-ProgramStart+1)
+
+	size, err := ROMSize(bytes.NewReader(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 3585 {
+		t.Fatalf("ROMSize() => %d; want 3585", size)
+	}
+
+	c := newCPU(t)
+	n, err := c.Load(bytes.NewReader(p))
+	if err != ErrROMTooLarge {
+		t.Fatalf("err => %v; want %v", err, ErrROMTooLarge)
+	}
+	if n != 0 {
+		t.Fatalf("n => %d; want 0", n)
+	}
+
+	// Nothing is loaded.
+	checkHex(t, "Memory[0x200]", c.Memory[0x200], 0x00)
+
+	if n, err := c.Load(bytes.NewReader(p[1:])); err != nil || n != 3584 {
+		t.Fatalf("Load() => %d, %v; want 3584, nil", n, err)
+	}
+}
+
+func TestCPU_RunSteps(t *testing.T) {
+	d := new(MemoryDisplay)
+	c := newHeadlessCPU(t, loadProgram(t, "pong.ch8"), d)
+
+	if err := c.RunSteps(1000); err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Frames == 0 {
+		t.Fatal("Expected frames to be rendered")
+	}
+
+	if d.Pixels != c.Pixels {
+		t.Fatal("Expected the last frame to match the graphics array")
+	}
+}
+
+func TestCPU_RunN(t *testing.T) {
+	c := newHeadlessCPU(t, []byte{
+		0x60, 0x02, // LD V0, 0x02
+		0x30, 0x02, // SE V0, 0x02
+		0x61, 0xFF, // LD V1, 0xFF
+		0x22, 0x0E, // CALL 0x20E
+		0x40, 0x02, // SNE V0, 0x02
+		0x12, 0x04, // JP 0x204
+		0x00, 0xFD, // EXIT
+		0x70, 0x01, // ADD V0, 0x01
+		0x00, 0xEE, // RET
+	}, NullDisplay)
+
+	ops, err := c.RunN(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []uint16{
+		0x6002, // LD V0, 0x02
+		0x3002, // SE V0, 0x02, skipping LD V1, 0xFF
+		0x220E, // CALL 0x20E
+		0x7001, // ADD V0, 0x01
+		0x00EE, // RET
+		0x4002, // SNE V0, 0x02, skipping JP 0x204
+	}
+
+	if len(ops) != len(want) {
+		t.Fatalf("RunN(100) => %04X; want %04X", ops, want)
+	}
+	for i := range want {
+		checkHex(t, fmt.Sprintf("ops[%d]", i), ops[i], want[i])
+	}
+
+	// It stops early after n instructions.
+	c.Reset()
+	ops, err = c.RunN(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 2 {
+		t.Errorf("RunN(2) => %d opcodes; want 2", len(ops))
+	}
+
+	// Errors short circuit.
+	c = newHeadlessCPU(t, []byte{
+		0x60, 0x01, // LD V0, 0x01
+		0x00, 0x00, // SYS 0x000
+	}, NullDisplay)
+	ops, err = c.RunN(10)
+	if _, ok := err.(*UnknownOpcode); !ok {
+		t.Fatalf("err => %v; want an UnknownOpcode", err)
+	}
+	if len(ops) != 1 || ops[0] != 0x6001 {
+		t.Errorf("RunN(10) => %04X; want [6001]", ops)
+	}
+}
+
+func TestCPU_StepInfo(t *testing.T) {
+	d := new(MemoryDisplay)
+	c := newHeadlessCPU(t, []byte{
+		0x60, 0x01, // LD V0, 0x01
+		0x00, 0xE0, // CLS
+		0xD0, 0x05, // DRW V0, V0, 5
+		0x70, 0x01, // ADD V0, 0x01
+	}, d)
+	c.DeferDraw = true
+
+	tests := []struct {
+		op    uint16
+		drawn bool
+	}{
+		{0x6001, false},
+		{0x00E0, true},
+		{0xD005, true},
+		{0x7001, false},
+	}
+
+	for _, tt := range tests {
+		info, err := c.StepInfo()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkHex(t, "Opcode", info.Opcode, tt.op)
+		if info.Drawn != tt.drawn {
+			t.Errorf("0x%04X: Drawn => %v; want %v", tt.op, info.Drawn, tt.drawn)
+		}
+	}
+
+	if d.Frames != 0 {
+		t.Errorf("Expected no frames to be rendered with DeferDraw, got %d", d.Frames)
+	}
+}
+
+func TestCPU_WaitKey(t *testing.T) {
+	// Each step is preceded by the keys to press and release, and followed
+	// by the expected PC.
+	type step struct {
+		press, release []byte
+		pc             uint16
+	}
+
+	tests := []struct {
+		name   string
+		quirks Quirks
+		steps  []step
+	}{
+		{
+			"Press",
+			Quirks{},
+			[]step{
+				{nil, nil, 0x200},
+				{[]byte{0x5}, nil, 0x202},
+			},
+		},
+		{
+			"KeyReleaseWait",
+			Quirks{KeyReleaseWait: true},
+			[]step{
+				{nil, nil, 0x200},
+				{[]byte{0x5}, nil, 0x200},
+				{[]byte{0x7}, nil, 0x200},
+				{nil, []byte{0x7}, 0x200},
+				{nil, []byte{0x5}, 0x202},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := new(MemoryKeypad)
+			c := newCPU(t)
+			c.Quirks = tt.quirks
+			c.Keypad = k
+			c.LoadBytes([]byte{
+				0xF3, 0x0A, // LD V3, K
+			})
+
+			for i, s := range tt.steps {
+				for _, key := range s.press {
+					k.Press(key)
+				}
+				for _, key := range s.release {
+					k.Release(key)
+				}
+
+				if _, err := c.Step(); err != nil {
+					t.Fatal(err)
+				}
+
+				checkHex(t, fmt.Sprintf("PC after step %d", i), c.PC, s.pc)
+			}
+
+			checkHex(t, "V[3]", c.V[3], 0x5)
+		})
+	}
+}
+
+func TestCPU_TickTimers(t *testing.T) {
+	c := newCPU(t)
+	c.DT = 3
+	c.V[0] = 0x02
+
+	// LD ST, V0 starts a beep.
+	if err := c.Dispatch(0xF018); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each tick counts both timers down once, and they stop at zero.
+	for i, want := range []struct{ dt, st byte }{
+		{2, 1},
+		{1, 0},
+		{0, 0},
+		{0, 0},
+	} {
+		c.TickTimers()
+
+		checkHex(t, fmt.Sprintf("DT after tick %d", i), c.DT, want.dt)
+		checkHex(t, fmt.Sprintf("ST after tick %d", i), c.ST, want.st)
+	}
+
+	if c.Cycles != 0 {
+		t.Errorf("Cycles => %d; want ticking not to execute anything", c.Cycles)
+	}
+
+	// The first beep ended when ST ran out, so setting it again is
+	// another one, but extending it while it's sounding isn't.
+	if err := c.Dispatch(0xF018); err != nil {
+		t.Fatal(err)
+	}
+	c.TickTimers()
+	if err := c.Dispatch(0xF018); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Beeps != 2 {
+		t.Errorf("Beeps => %d; want 2", c.Beeps)
+	}
+}
+
+func TestCPU_WaitKey_Held(t *testing.T) {
+	k := new(MemoryKeypad)
+	c := newCPU(t)
+	c.Keypad = k
+	c.LoadBytes([]byte{
+		0xF3, 0x0A, // 0x200: LD V3, K
+		0xF4, 0x0A, // 0x202: LD V4, K
+		0xF5, 0x0A, // 0x204: LD V5, K
+	})
+
+	// Each step is preceded by the keys to press and release, and followed
+	// by the expected PC.
+	steps := []struct {
+		press, release []byte
+		pc             uint16
+	}{
+		{[]byte{0x5}, nil, 0x202},
+
+		// Holding the key down doesn't satisfy the next Fx0A.
+		{nil, nil, 0x202},
+		{nil, nil, 0x202},
+		{nil, []byte{0x5}, 0x202},
+
+		// Until it's released and pressed again.
+		{[]byte{0x5}, nil, 0x204},
+
+		// Another key pressed while it's held is a fresh press.
+		{[]byte{0x7}, nil, 0x206},
+	}
+
+	for i, s := range steps {
+		for _, key := range s.press {
+			k.Press(key)
+		}
+		for _, key := range s.release {
+			k.Release(key)
+		}
+
+		if _, err := c.Step(); err != nil {
+			t.Fatal(err)
+		}
+
+		checkHex(t, fmt.Sprintf("PC after step %d", i), c.PC, s.pc)
+	}
+
+	checkHex(t, "V[3]", c.V[3], 0x5)
+	checkHex(t, "V[4]", c.V[4], 0x5)
+	checkHex(t, "V[5]", c.V[5], 0x7)
+}
+
+func TestCPU_WaitKey_Frames(t *testing.T) {
+	k := &countingKeypad{MemoryKeypad: new(MemoryKeypad)}
+
+	c, err := NewCPU(&Options{
+		ClockSpeed:  240,
+		Unthrottled: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Keypad = k
+	c.LoadBytes([]byte{
+		0xF3, 0x0A, // LD V3, K
+	})
+	c.DT = 10
+	c.ST = 10
+
+	// At 240 Hz, 20 instructions are 5 frames.
+	if err := c.RunSteps(20); err != nil {
+		t.Fatal(err)
+	}
+
+	checkHex(t, "PC", c.PC, 0x200)
+	checkHex(t, "DT", c.DT, 5)
+	checkHex(t, "ST", c.ST, 5)
+	if c.Cycles != 20 {
+		t.Errorf("Cycles => %d; want 20", c.Cycles)
+	}
+	if k.polls != 5 {
+		t.Errorf("polls => %d; want 5", k.polls)
+	}
+
+	// The key is seen at the start of the next frame.
+	k.Press(0x9)
+	if err := c.RunSteps(1); err != nil {
+		t.Fatal(err)
+	}
+
+	checkHex(t, "PC", c.PC, 0x202)
+	checkHex(t, "V[3]", c.V[3], 0x9)
+	checkHex(t, "DT", c.DT, 5)
+}
+
+// countingKeypad is a MemoryKeypad that counts how many times it's polled.
+type countingKeypad struct {
+	*MemoryKeypad
+	polls int
+}
+
+func (k *countingKeypad) Pressed() (uint16, error) {
+	k.polls++
+	return k.MemoryKeypad.Pressed()
+}
+
+func TestCPU_Run_InitialFrame(t *testing.T) {
+	var frames []*Graphics
+
+	c := newHeadlessCPU(t, []byte{
+		0xA0, 0x00, // LD I, 0x000
+		0xD0, 0x05, // DRW V0, V0, 5
+		0x00, 0xFD, // EXIT
+	}, nil)
+	c.Graphics.Display = DisplayFunc(func(g *Graphics) error {
+		if c.Cycles == 0 && len(frames) != 0 {
+			t.Fatal("Expected a single frame before the first instruction")
+		}
+		frames = append(frames, g.Clone())
+		return nil
+	})
+
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(frames) != 2 {
+		t.Fatalf("len(frames) => %d; want 2", len(frames))
+	}
+
+	checkGraphics(t, frames[0], new(Graphics).Hash())
+	if c.Graphics.Frames != 1 {
+		t.Errorf("Frames => %d; want 1, since the blank frame isn't counted", c.Graphics.Frames)
+	}
+}
+
+func TestCPU_Run_Timers(t *testing.T) {
+	// However fast instructions execute, the timers count down 60 times
+	// in a second.
+	for _, hz := range []int{600, 60} {
+		t.Run(fmt.Sprintf("%dHz", hz), func(t *testing.T) {
+			ts := NewManualTimeSource(time.Unix(0, 0))
+
+			c, err := NewCPU(&Options{
+				ClockSpeed: time.Duration(hz),
+				TimeSource: ts,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.LoadBytes([]byte{
+				0x12, 0x00, // JP 0x200
+			})
+			c.DT = 0xFF
+			c.ST = 0xFF
+
+			done := make(chan error)
+			go func() {
+				done <- c.Run()
+			}()
+
+			// Wait for Run to start, then simulate a second.
+			ts.Advance(0)
+			for i := 0; i < 10; i++ {
+				ts.Advance(100 * time.Millisecond)
+			}
+
+			c.Stop()
+			if err := <-done; err != nil {
+				t.Fatal(err)
+			}
+
+			if c.Cycles != uint64(hz) {
+				t.Errorf("Cycles => %d; want %d", c.Cycles, hz)
+			}
+			checkHex(t, "DT", c.DT, 0xFF-60)
+			checkHex(t, "ST", c.ST, 0xFF-60)
+		})
+	}
+}
+
+func TestCPU_Run_CatchUp(t *testing.T) {
+	var mu sync.Mutex
+	clock := time.Unix(0, 0)
+	now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return clock
+	}
+	defer func() { now = time.Now }()
+
+	ticks := make(chan time.Time)
+
+	c := newCPU(t)
+	c.Clock = ticks
+	c.LoadBytes([]byte{
+		0x12, 0x00, // JP 0x200
+	})
+
+	done := make(chan error)
+	go func() {
+		done <- c.Run()
+	}()
+
+	// Advancing the clock between ticks executes every instruction that
+	// should have run in the meantime. Sending a tick without advancing
+	// the clock waits for the previous batch to finish.
+	tick := func(d time.Duration) {
+		mu.Lock()
+		clock = clock.Add(d)
+		mu.Unlock()
+
+		ticks <- time.Time{}
+		ticks <- time.Time{}
+	}
+
+	// Wait for Run to start.
+	tick(0)
+
+	// A few regular ticks at 60 Hz.
+	tick(50 * time.Millisecond)
+
+	// A 200ms pause that dropped ticks.
+	tick(200 * time.Millisecond)
+
+	// A pause so long that the CPU gives up catching up.
+	tick(10 * time.Second)
+
+	c.Stop()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	checkHex(t, "Cycles", c.Cycles, 3+12+15)
+}
+
+func TestCPU_LastFlagWrite(t *testing.T) {
+	c := newCPU(t)
+	c.TraceFlagWrites = true
+	c.LoadBytes([]byte{
+		0x61, 0xFF, // LD V1, 0xFF
+		0x62, 0x03, // LD V2, 0x03
+		0x81, 0x24, // ADD V1, V2
+		0x63, 0x01, // LD V3, 0x01
+	})
+
+	for i := 0; i < 4; i++ {
+		if _, err := c.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cycle, op, value := c.LastFlagWrite()
+	checkHex(t, "cycle", cycle, 0x02)
+	checkHex(t, "op", op, 0x8124)
+	checkHex(t, "value", value, 0x01)
+}
+
+func TestCPU_FirstCollision(t *testing.T) {
+	var b bytes.Buffer
+
+	c := newCPU(t)
+	c.Graphics.Display = NullDisplay
+	c.Logger = log.New(&b, "", 0)
+	c.TraceFirstCollision = true
+	c.LoadBytes([]byte{
+		0xA2, 0x10, // LD I, 0x210
+		0x60, 0x08, // LD V0, 0x08
+		0x61, 0x04, // LD V1, 0x04
+		0xD0, 0x11, // DRW V0, V1, 1
+		0x70, 0x04, // ADD V0, 0x04
+		0xD0, 0x11, // DRW V0, V1, 1
+		0xD0, 0x11, // DRW V0, V1, 1
+		0x00, 0xFD, // EXIT
+		0xFF, // Sprite
+	})
+
+	if _, _, _, ok := c.FirstCollision(); ok {
+		t.Fatal("Expected no collision before anything is drawn")
+	}
+
+	if err := c.RunSteps(10); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second sprite overlaps half of the first, and the third one
+	// collides again, but only the first collision is recorded.
+	cycle, x, y, ok := c.FirstCollision()
+	if !ok {
+		t.Fatal("Expected a collision")
+	}
+	if cycle != 5 {
+		t.Errorf("cycle => %d; want 5", cycle)
+	}
+	checkHex(t, "x", x, 0x0C)
+	checkHex(t, "y", y, 0x04)
+
+	if want := "First collision: cycle=5 sprite=0x210 x=12 y=4\n"; !strings.Contains(b.String(), want) {
+		t.Errorf("Expected %q to be logged, got:\n%s", want, b.String())
+	}
+
+	c.Reset()
+	if _, _, _, ok := c.FirstCollision(); ok {
+		t.Error("Expected Reset to clear the collision")
+	}
+}
+
+func TestCPU_BreakOnCollision(t *testing.T) {
+	c := newCPU(t)
+	c.Graphics.Display = NullDisplay
+	c.BreakOnCollision = true
+	c.LoadBytes([]byte{
+		0xA2, 0x10, // LD I, 0x210
+		0x60, 0x08, // LD V0, 0x08
+		0x61, 0x04, // LD V1, 0x04
+		0xD0, 0x11, // DRW V0, V1, 1
+		0x70, 0x04, // ADD V0, 0x04
+		0xD0, 0x11, // DRW V0, V1, 1
+		0xD0, 0x11, // DRW V0, V1, 1
+		0x00, 0xFD, // EXIT
+		0xFF, // Sprite
+	})
+
+	// The first DRW doesn't collide with anything, so it stops right
+	// after the second one.
+	if err := c.RunSteps(100); err != ErrBreakpoint {
+		t.Fatalf("err => %v; want %v", err, ErrBreakpoint)
+	}
+	if c.Cycles != 6 {
+		t.Errorf("Cycles => %d; want 6", c.Cycles)
+	}
+	checkHex(t, "PC", c.PC, 0x20C)
+	checkHex(t, "VF", c.V[0xF], 0x01)
+
+	if cycle, x, y, ok := c.FirstCollision(); !ok || cycle != 5 || x != 0x0C || y != 0x04 {
+		t.Errorf("FirstCollision() => %d, %d, %d, %v; want 5, 12, 4, true", cycle, x, y, ok)
+	}
+
+	// The third DRW collides too, but only the first collision stops the
+	// CPU, so resuming runs through to the EXIT.
+	if err := c.RunSteps(100); err != nil {
+		t.Fatal(err)
+	}
+	checkHex(t, "PC", c.PC, 0x20E)
+	checkHex(t, "VF", c.V[0xF], 0x01)
+
+	// After a reset, the next collision stops it again.
+	c.Reset()
+	if err := c.RunSteps(100); err != ErrBreakpoint {
+		t.Fatalf("err => %v; want %v", err, ErrBreakpoint)
+	}
+	checkHex(t, "PC", c.PC, 0x20C)
+}
+
+func TestScriptedKeypad(t *testing.T) {
+	k := &ScriptedKeypad{Keys: []byte{0x01, 0x02}}
+
+	for _, want := range []byte{0x01, 0x02, 0x01} {
+		b, err := k.ReadByte()
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkHex(t, "key", b, want)
+	}
+
+	if _, err := new(ScriptedKeypad).ReadByte(); err != ErrQuit {
+		t.Fatalf("err => %v; want %v", err, ErrQuit)
+	}
+
+	// With Once, the keys run out instead of starting over.
+	k = &ScriptedKeypad{Keys: []byte{0x01, 0x02}, Once: true}
+	for _, want := range []byte{0x01, 0x02} {
+		b, err := k.ReadByte()
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkHex(t, "key", b, want)
+	}
+	if _, err := k.ReadByte(); err != ErrQuit {
+		t.Fatalf("err => %v; want %v", err, ErrQuit)
+	}
+}
+
+func TestCPU_decodeOp(t *testing.T) {
+	c := newCPU(t)
+	c.Memory[0x200] = 0xA2
+	c.Memory[0x201] = 0xF0
+
+	d, err := c.decodeOp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkHex(t, "op", d.Opcode, 0xA2F0)
+	checkHex(t, "nnn", d.NNN, 0x2F0)
+}
+
+func TestCPU_Step_UnknownOpcode(t *testing.T) {
+	c := newCPU(t)
+	c.LoadBytes([]byte{
+		0x61, 0x02, // LD V1, 0x02
+		0x51, 0x21, // Invalid
+		0x12, 0x00, // JP 0x200
+	})
+
+	c.Step()
+	_, err := c.Step()
+
+	e, ok := err.(*UnknownOpcode)
+	if !ok {
+		t.Fatalf("err => %v; want an UnknownOpcode", err)
+	}
+
+	checkHex(t, "Opcode", e.Opcode, 0x5121)
+	checkHex(t, "PC", e.PC, 0x202)
+
+	want := `chip8: unknown opcode: 0x5121 at 0x202 (0x5xxx with a non-zero low nibble)
+  0x1FE  0000  SYS 0x000
+  0x200  6102  LD V1, 0x02
+> 0x202  5121  DW 0x5121
+  0x204  1200  JP 0x200
+  0x206  0000  SYS 0x000
+`
+	if got := err.Error(); got != want {
+		t.Errorf("Error() =>\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCPU_SkipUnknownOpcodes(t *testing.T) {
+	var b bytes.Buffer
+
+	c := newCPU(t)
+	c.Logger = log.New(&b, "", 0)
+	c.SkipUnknownOpcodes = true
+	c.LoadBytes([]byte{
+		0x61, 0x02, // LD V1, 0x02
+		0x51, 0x21, // Invalid
+		0x62, 0x03, // LD V2, 0x03
+		0x00, 0xFD, // EXIT
+	})
+
+	if err := c.RunSteps(10); err != nil {
+		t.Fatal(err)
+	}
+
+	// Execution carries on past the bad opcode.
+	checkHex(t, "V2", c.V[2], 0x03)
+	checkHex(t, "PC", c.PC, 0x206)
+	if c.SkippedOpcodes != 1 {
+		t.Errorf("SkippedOpcodes => %d; want 1", c.SkippedOpcodes)
+	}
+
+	want := "skipping chip8: unknown opcode: 0x5121 at 0x202 (0x5xxx with a non-zero low nibble)\n" +
+		"  0x1FE  0000  SYS 0x000\n" +
+		"  0x200  6102  LD V1, 0x02\n" +
+		"> 0x202  5121  DW 0x5121\n"
+	if !strings.Contains(b.String(), want) {
+		t.Errorf("Expected the skip to be logged, got:\n%s", b.String())
+	}
+}
+
+func TestUnknownOpcode_Family(t *testing.T) {
+	tests := []struct {
+		op     uint16
+		family string
+		why    string
+	}{
+		{0x5121, "0x5xxx", "0x5xxx with a non-zero low nibble"},
+		{0x9AB3, "0x9xxx", "0x9xxx with a non-zero low nibble"},
+		{0x8128, "0x8xxx", "0x8xxx with an undefined low nibble"},
+		{0xE1FF, "0xExxx", "0xExxx with an undefined low byte"},
+		{0x0123, "0x0xxx", "0nnn machine code routines aren't supported"},
+		{0x00FF, "0x0xxx", "SUPER-CHIP instruction, which isn't implemented"},
+		{0xF175, "0xFxxx", "SUPER-CHIP instruction, which isn't implemented"},
+		{0xF000, "0xFxxx", "XO-CHIP instruction, which isn't implemented"},
+		{0xF1FF, "0xFxxx", "0xFxxx with an undefined low byte"},
+	}
+
+	for _, tt := range tests {
+		family, why := (&UnknownOpcode{Opcode: tt.op}).Family()
+		if family != tt.family || why != tt.why {
+			t.Errorf("Family(0x%04X) => %q, %q; want %q, %q", tt.op, family, why, tt.family, tt.why)
+		}
+	}
+
+	c := newCPU(t)
+	if err := c.Dispatch(0x00FF); err == nil || !strings.Contains(err.Error(), "SUPER-CHIP") {
+		t.Errorf("Expected the error to explain the opcode, got %v", err)
+	}
+
+	// The errors Dispatch returns are explained the same way.
+	err, ok := c.Dispatch(0x8128).(*UnknownOpcode)
+	if !ok {
+		t.Fatalf("err => %v; want an *UnknownOpcode", err)
+	}
+	if family, why := err.Family(); family != "0x8xxx" || why != "0x8xxx with an undefined low nibble" {
+		t.Errorf("Family() => %q, %q; want %q, %q", family, why, "0x8xxx", "0x8xxx with an undefined low nibble")
+	}
+}
+
+func TestNewCPU_SkipFontLoad(t *testing.T) {
+	c, err := NewCPU(&Options{
+		ClockSpeed:   DefaultClockSpeed,
+		Unthrottled:  true,
+		SkipFontLoad: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, b := range c.Memory[0:80] {
+		if b != 0 {
+			t.Fatalf("Memory[0x%03X] => 0x%02X; want 0x00", i, b)
+		}
+	}
+
+	// The font is loaded by default.
+	c = newCPU(t)
+	checkHex(t, "Memory[0x000]", c.Memory[0], FontSet[0])
+}
+
+func TestNewCPU_MemoryFill(t *testing.T) {
+	c, err := NewCPU(&Options{
+		ClockSpeed:  DefaultClockSpeed,
+		Unthrottled: true,
+		MemoryFill:  0xCC,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := []byte{0x12, 0x00}
+	if _, err := c.LoadBytes(p); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, b := range c.Memory {
+		want := byte(0xCC)
+		switch {
+		case i < len(FontSet):
+			want = FontSet[i]
+		case i >= 0x200 && i < 0x200+len(p):
+			want = p[i-0x200]
+		}
+
+		if b != want {
+			t.Fatalf("Memory[0x%03X] => 0x%02X; want 0x%02X", i, b, want)
+		}
+	}
+
+	// Memory is zeroed by default.
+	c = newCPU(t)
+	checkHex(t, "Memory[0x300]", c.Memory[0x300], 0x00)
+}
+
+func TestNewCPU_ClockSpeed(t *testing.T) {
+	tests := []struct {
+		options Options
+		err     bool
+	}{
+		{Options{ClockSpeed: 600}, false},
+		{Options{ClockSpeed: 0, Unthrottled: true}, false},
+		{Options{ClockSpeed: 0}, true},
+		{Options{ClockSpeed: -60}, true},
+		{Options{ClockSpeed: -60, Unthrottled: true}, true},
+	}
+
+	for _, tt := range tests {
+		_, err := NewCPU(&tt.options)
+		if (err != nil) != tt.err {
+			t.Errorf("NewCPU(%+v) => error %v", tt.options, err)
+		}
+	}
+}
+
+func TestCPU_MemorySize(t *testing.T) {
+	c, err := NewCPU(&Options{
+		ClockSpeed:  DefaultClockSpeed,
+		Unthrottled: true,
+		MemorySize:  2048,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.Memory) != 2048 {
+		t.Fatalf("len(Memory) => %d; want 2048", len(c.Memory))
+	}
+
+	// A program that exactly fills memory loads.
+	if _, err := c.LoadBytes(make([]byte, 2048-0x200)); err != nil {
+		t.Fatal(err)
+	}
+
+	// One that doesn't fit is rejected.
+	n, err := c.LoadBytes(make([]byte, 2048-0x200+1))
+	if err != ErrROMTooLarge {
+		t.Fatalf("err => %v; want %v", err, ErrROMTooLarge)
+	}
+	if n != 0 {
+		t.Fatalf("n => %d; want 0", n)
+	}
+
+	// So do instructions that reach past the end of memory.
+	c.I = 2047
+	if err := c.Dispatch(0xF155); err != ErrMemoryAccess {
+		t.Fatalf("err => %v; want %v", err, ErrMemoryAccess)
+	}
+	if err := c.Dispatch(0xD002); err != ErrMemoryAccess {
+		t.Fatalf("err => %v; want %v", err, ErrMemoryAccess)
+	}
+
+	// Fx65 doesn't load any register when the end of the range is out of
+	// bounds.
+	c.I = 2046
+	c.Memory[2046], c.Memory[2047] = 0xAA, 0xBB
+	c.V[0], c.V[1], c.V[2] = 0x01, 0x02, 0x03
+	if err := c.Dispatch(0xF265); err != ErrMemoryAccess {
+		t.Fatalf("err => %v; want %v", err, ErrMemoryAccess)
+	}
+	for i, b := range []byte{0x01, 0x02, 0x03} {
+		checkHex(t, fmt.Sprintf("V[%d]", i), c.V[i], b)
+	}
+
+	c.PC = 2047
+	c.EndOfMemory = EndOfMemoryError
+	if _, err := c.Step(); err != ErrMemoryAccess {
+		t.Fatalf("err => %v; want %v", err, ErrMemoryAccess)
+	}
+}
+
+// benchmarkCycles is the number of instructions executed per iteration of the
+// program benchmarks.
+const benchmarkCycles = 10000
+
+func BenchmarkRunPong(b *testing.B) {
+	benchmarkProgram(b, loadProgram(b, "pong.ch8"), nil)
+}
+
+func BenchmarkRunInvaders(b *testing.B) {
+	b.Run("Full", func(b *testing.B) {
+		benchmarkProgram(b, loadProgram(b, "invaders.ch8"), nil)
+	})
+
+	// A tight loop of draws, which isolates Dxyn.
+	b.Run("Dxyn", func(b *testing.B) {
+		benchmarkProgram(b, dxynProgram, nil)
+	})
+}
+
+var dxynProgram = []byte{
+	0xA0, 0x00, // LD I, 0x000
+	0xD0, 0x15, // DRW V0, V1, 5
+	0x70, 0x03, // ADD V0, 0x03
+	0x71, 0x02, // ADD V1, 0x02
+	0x12, 0x02, // JP 0x202
+}
+
+func BenchmarkDxyn(b *testing.B) {
+	b.Run("Uncached", func(b *testing.B) {
+		benchmarkProgram(b, dxynProgram, nil)
+	})
+
+	b.Run("Cached", func(b *testing.B) {
+		benchmarkProgram(b, dxynProgram, func(c *CPU) {
+			c.CacheSprites = true
+		})
+	})
+}
+
+// dispatchOps is a mix of the opcodes that programs spend most of their time
+// in, which can be dispatched over and over without running out of stack or
+// memory.
+var dispatchOps = []uint16{
+	0xA300, // LD I, 0x300
+	0x6012, // LD V0, 0x12
+	0x7101, // ADD V1, 0x01
+	0x8014, // ADD V0, V1
+	0x8102, // AND V1, V0
+	0x3012, // SE V0, 0x12
+	0x4013, // SNE V0, 0x13
+	0xC0FF, // RND V0, 0xFF
+	0xD015, // DRW V0, V1, 5
+	0xF033, // LD B, V0
+	0xF265, // LD V2, [I]
+	0xF015, // LD DT, V0
+	0x1200, // JP 0x200
+}
+
+// BenchmarkDispatch measures the switch in Dispatch on its own, without
+// fetching or timing, so that changes to how opcodes are dispatched can be
+// compared with benchstat.
+func BenchmarkDispatch(b *testing.B) {
+	c := newHeadlessCPU(b, nil, NullDisplay)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := c.Dispatch(dispatchOps[i%len(dispatchOps)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkProgram benchmarks running p, with the CPU configured by setup if
+// it's not nil.
+func benchmarkProgram(b *testing.B, p []byte, setup func(*CPU)) {
+	b.ReportAllocs()
+
+	var elapsed time.Duration
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		c := newHeadlessCPU(b, p, new(MemoryDisplay))
+		if setup != nil {
+			setup(c)
+		}
+		b.StartTimer()
+
+		start := time.Now()
+		if err := c.RunSteps(benchmarkCycles); err != nil {
+			b.Fatal(err)
+		}
+		elapsed += time.Since(start)
+	}
+
+	b.ReportMetric(float64(b.N*benchmarkCycles)/elapsed.Seconds(), "instructions/s")
+}
+
+func newCPU(t testing.TB) *CPU {
+	c, err := NewCPU(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// newHeadlessCPU returns an unthrottled CPU with the program loaded, a
+// scripted keypad and a seeded random number generator.
+func newHeadlessCPU(t testing.TB, p []byte, d Display) *CPU {
+	c, err := NewCPU(&Options{
+		ClockSpeed:  DefaultClockSpeed,
+		Unthrottled: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Graphics.Display = d
+	c.Keypad = &ScriptedKeypad{Keys: []byte{0x01, 0x04, 0x05, 0x06}}
+	c.Rand = rand.New(rand.NewSource(1))
+
+	if _, err := c.LoadBytes(p); err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+// loadProgram reads one of the bundled programs.
+func loadProgram(t testing.TB, name string) []byte {
+	f, err := OpenProgram(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	p, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func tryUint16(v interface{}) uint16 {
+	switch v := v.(type) {
+	case byte:
+		return uint16(v)
+	case uint16:
+		return v
+	case int:
+		return uint16(v)
+	case uint32:
+		return uint16(v)
+	case uint64:
+		return uint16(v)
+	}
+
+	return 0
+}
+
+func checkHex(t *testing.T, subject string, got, want interface{}) {
+	t.Helper()
+
+	g := tryUint16(got)
+	w := tryUint16(want)
+
+	if g != w {
+		t.Errorf("%s => 0x%04X; want 0x%04X", subject, g, w)
+	}
+}
+
+func checkGraphics(t *testing.T, g *Graphics, hash string) {
+	t.Helper()
+
+	if h := g.Hash(); h != hash {
+		t.Errorf("Expected graphics hash to be %s, got %s", hash, h)
+		t.Log("Graphics Array:")
+		t.Log(g.Pixels)
+	}
+}
+
+func TestCPU_OnFrame(t *testing.T) {
+	ts := NewManualTimeSource(time.Unix(0, 0))
+
+	c, err := NewCPU(&Options{
+		ClockSpeed: 600,
+		TimeSource: ts,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Graphics.Display = NullDisplay
+	c.LoadBytes([]byte{
+		0x70, 0x01, // ADD V0, 0x01
+		0x12, 0x00, // JP 0x200
+	})
+
+	var cycles []uint64
+	c.OnFrame = func(cycle uint64, g *Graphics) {
+		if g != &c.Graphics {
+			t.Error("Expected OnFrame to get the graphics array")
+		}
+		cycles = append(cycles, cycle)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- c.Run()
+	}()
+
+	// Wait for Run to start, then run for a simulated second.
+	ts.Advance(0)
+	for i := 0; i < 4; i++ {
+		ts.Advance(250 * time.Millisecond)
+	}
+
+	c.Stop()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cycles) != 60 {
+		t.Fatalf("OnFrame called %d times; want 60", len(cycles))
+	}
+	for i, cycle := range cycles {
+		// A frame every 10 instructions.
+		if want := uint64(i+1) * 10; cycle != want {
+			t.Errorf("frame %d: cycle => %d; want %d", i, cycle, want)
+		}
 	}
 }
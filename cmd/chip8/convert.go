@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/ejholmes/chip8"
+	"github.com/urfave/cli"
+)
+
+var cmdConvert = cli.Command{
+	Name:      "convert",
+	Usage:     "Convert a chip8 program to source code or Intel HEX, or Intel HEX back to a program",
+	ArgsUsage: "[program]",
+	Action:    runConvert,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "to",
+			Usage: "Format to convert to: go, c, hex (Intel HEX), or rom to convert Intel HEX back to a program.",
+		},
+	},
+}
+
+func runConvert(c *cli.Context) error {
+	var (
+		r    io.Reader = os.Stdin
+		name           = "rom"
+	)
+	if c.Args().Present() {
+		f, err := os.Open(c.Args().First())
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+		name = identifier(c.Args().First())
+	}
+
+	in, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	w := c.App.Writer
+
+	switch to := c.String("to"); to {
+	case "go":
+		return writeGo(w, name, in)
+	case "c":
+		return writeC(w, name, in)
+	case "hex":
+		return writeHex(w, in)
+	case "rom":
+		p, err := readHex(bytes.NewReader(in))
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(p)
+		return err
+	default:
+		return fmt.Errorf("unknown format: %q", to)
+	}
+}
+
+// identifier turns the name of a program file into an identifier, e.g.
+// "programs/space-invaders.ch8" into "space_invaders".
+func identifier(path string) string {
+	base := filepath.Base(path)
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+
+	id := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, base)
+
+	if id == "" || unicode.IsDigit(rune(id[0])) {
+		id = "rom_" + id
+	}
+
+	return id
+}
+
+// bytesPerLine is how many bytes are written per line of source code.
+const bytesPerLine = 12
+
+// writeGo writes p as a Go byte slice.
+func writeGo(w io.Writer, name string, p []byte) error {
+	b := new(bytes.Buffer)
+
+	fmt.Fprintf(b, "var %s = []byte{\n", name)
+	writeByteLines(b, p)
+	fmt.Fprintf(b, "}\n")
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// writeC writes p as a C array, along with its length, like xxd -i.
+func writeC(w io.Writer, name string, p []byte) error {
+	b := new(bytes.Buffer)
+
+	fmt.Fprintf(b, "const unsigned char %s[] = {\n", name)
+	writeByteLines(b, p)
+	fmt.Fprintf(b, "};\n")
+	fmt.Fprintf(b, "const unsigned int %s_len = %d;\n", name, len(p))
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// writeByteLines writes p as indented lines of comma separated hex bytes.
+func writeByteLines(b *bytes.Buffer, p []byte) {
+	for i := 0; i < len(p); i += bytesPerLine {
+		end := i + bytesPerLine
+		if end > len(p) {
+			end = len(p)
+		}
+
+		b.WriteString("\t")
+		for j, v := range p[i:end] {
+			if j > 0 {
+				b.WriteString(" ")
+			}
+			fmt.Fprintf(b, "0x%02X,", v)
+		}
+		b.WriteString("\n")
+	}
+}
+
+// Intel HEX record types.
+const (
+	hexData = 0x00
+	hexEOF  = 0x01
+)
+
+// hexRecordSize is how many bytes are written per Intel HEX data record.
+const hexRecordSize = 16
+
+// writeHex writes p as Intel HEX, addressed where it's loaded in memory.
+func writeHex(w io.Writer, p []byte) error {
+	b := new(bytes.Buffer)
+
+	for i := 0; i < len(p); i += hexRecordSize {
+		end := i + hexRecordSize
+		if end > len(p) {
+			end = len(p)
+		}
+
+		writeHexRecord(b, uint16(chip8.ProgramStart+i), hexData, p[i:end])
+	}
+	writeHexRecord(b, 0, hexEOF, nil)
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// writeHexRecord writes a single Intel HEX record.
+func writeHexRecord(b *bytes.Buffer, addr uint16, typ byte, data []byte) {
+	record := append([]byte{byte(len(data)), byte(addr >> 8), byte(addr), typ}, data...)
+
+	var sum byte
+	for _, v := range record {
+		sum += v
+	}
+	record = append(record, -sum)
+
+	fmt.Fprintf(b, ":%s\n", strings.ToUpper(hex.EncodeToString(record)))
+}
+
+// readHex reads a program from Intel HEX written by writeHex. The program
+// starts at the lowest address in the data records, and any gaps are
+// zeroed.
+func readHex(r io.Reader) ([]byte, error) {
+	var (
+		mem  = make(map[int]byte)
+		low  = -1
+		high = -1
+	)
+
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" {
+			continue
+		}
+
+		if text[0] != ':' {
+			return nil, fmt.Errorf("line %d: missing start code", line)
+		}
+
+		record, err := hex.DecodeString(text[1:])
+		if err != nil || len(record) < 5 || len(record) != int(record[0])+5 {
+			return nil, fmt.Errorf("line %d: malformed record", line)
+		}
+
+		var sum byte
+		for _, v := range record {
+			sum += v
+		}
+		if sum != 0 {
+			return nil, fmt.Errorf("line %d: bad checksum", line)
+		}
+
+		addr := int(record[1])<<8 | int(record[2])
+		data := record[4 : len(record)-1]
+
+		switch record[3] {
+		case hexData:
+			for i, v := range data {
+				mem[addr+i] = v
+			}
+
+			if len(data) > 0 {
+				if low < 0 || addr < low {
+					low = addr
+				}
+				if end := addr + len(data) - 1; end > high {
+					high = end
+				}
+			}
+		case hexEOF:
+			return hexBytes(mem, low, high), nil
+		default:
+			return nil, fmt.Errorf("line %d: unsupported record type 0x%02X", line, record[3])
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, fmt.Errorf("missing end of file record")
+}
+
+// hexBytes returns the bytes from low to high.
+func hexBytes(mem map[int]byte, low, high int) []byte {
+	if low < 0 {
+		return nil
+	}
+
+	p := make([]byte, high-low+1)
+	for i := range p {
+		p[i] = mem[low+i]
+	}
+	return p
+}
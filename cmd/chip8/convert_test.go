@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chip8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rom := filepath.Join(dir, "tiny-prog.ch8")
+	p := []byte{
+		0x00, 0xE0, 0xA2, 0x2A, 0x60, 0x0C, 0x61, 0x08, 0xD0, 0x15, 0x12, 0x00,
+		0x6A, 0x02, 0x6B, 0x0C, 0x6C, 0x3F, 0x6D, 0x0C,
+	}
+	if err := ioutil.WriteFile(rom, p, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		to   string
+		want string
+	}{
+		{"go", `var tiny_prog = []byte{
+	0x00, 0xE0, 0xA2, 0x2A, 0x60, 0x0C, 0x61, 0x08, 0xD0, 0x15, 0x12, 0x00,
+	0x6A, 0x02, 0x6B, 0x0C, 0x6C, 0x3F, 0x6D, 0x0C,
+}
+`},
+		{"c", `const unsigned char tiny_prog[] = {
+	0x00, 0xE0, 0xA2, 0x2A, 0x60, 0x0C, 0x61, 0x08, 0xD0, 0x15, 0x12, 0x00,
+	0x6A, 0x02, 0x6B, 0x0C, 0x6C, 0x3F, 0x6D, 0x0C,
+};
+const unsigned int tiny_prog_len = 20;
+`},
+		{"hex", `:1002000000E0A22A600C6108D01512006A026B0C93
+:040210006C3F6D0CC6
+:00000001FF
+`},
+	}
+
+	for _, tt := range tests {
+		out, err := convert(t, "--to", tt.to, rom)
+		if err != nil {
+			t.Fatalf("convert --to %s => error %v", tt.to, err)
+		}
+
+		if got := string(out); got != tt.want {
+			t.Errorf("convert --to %s =>\n%s\nwant:\n%s", tt.to, got, tt.want)
+		}
+	}
+
+	// Intel HEX converts back to the program.
+	out, err := convert(t, "--to", "hex", rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hexFile := filepath.Join(dir, "tiny-prog.hex")
+	if err := ioutil.WriteFile(hexFile, out, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err = convert(t, "--to", "rom", hexFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(out, p) {
+		t.Errorf("round trip => %X; want %X", out, p)
+	}
+
+	if _, err := convert(t, "--to", "basic", rom); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestReadHex(t *testing.T) {
+	tests := []struct {
+		in string
+		ok bool
+	}{
+		{":0102000000FD\n:00000001FF\n", true},
+		{":0102000000FC\n:00000001FF\n", false}, // Bad checksum
+		{":0102000000FD\n", false},              // No EOF record
+		{"0102000000FD\n:00000001FF\n", false},  // No start code
+		{":01020000\n:00000001FF\n", false},     // Too short
+	}
+
+	for _, tt := range tests {
+		_, err := readHex(bytes.NewBufferString(tt.in))
+		if (err == nil) != tt.ok {
+			t.Errorf("readHex(%q) => error %v", tt.in, err)
+		}
+	}
+}
+
+// convert runs the convert command and returns what it wrote.
+func convert(t *testing.T, args ...string) ([]byte, error) {
+	var out bytes.Buffer
+
+	app := newApp()
+	app.Writer = &out
+
+	err := app.Run(append([]string{"chip8", "convert"}, args...))
+	return out.Bytes(), err
+}
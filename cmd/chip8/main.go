@@ -2,21 +2,38 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/urfave/cli"
 )
 
 func main() {
+	os.Exit(runMain(os.Args, os.Stderr))
+}
+
+// runMain runs the app and returns the exit status, printing any error to
+// stderr.
+func runMain(args []string, stderr io.Writer) int {
+	if err := newApp().Run(args); err != nil {
+		printErr(stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+func newApp() *cli.App {
 	app := cli.NewApp()
 	app.Name = "chip8"
 	app.Usage = "Run chip8 programs using a Go based emulator"
 	app.Commands = []cli.Command{
 		cmdRun,
+		cmdConvert,
 	}
-	app.Run(os.Args)
+	return app
 }
 
-func printErr(err error) {
-	fmt.Fprintf(os.Stderr, "error: %s\n", err)
+func printErr(w io.Writer, err error) {
+	fmt.Fprintf(w, "error: %s\n", err)
 }
@@ -1,14 +1,20 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/ejholmes/chip8"
-	"github.com/nsf/termbox-go"
+	"github.com/ejholmes/chip8/termbox"
 	"github.com/urfave/cli"
 )
 
@@ -23,34 +29,149 @@ var cmdRun = cli.Command{
 		},
 		cli.IntFlag{
 			Name:  "clock",
-			Usage: "Clock speed, in hz, to run at.",
+			Usage: "Clock speed, in hz, to run at. 0 runs as fast as possible.",
 			Value: int(chip8.DefaultClockSpeed),
 		},
+		cli.StringFlag{
+			Name:  "quirks",
+			Usage: "Interpreter quirks to emulate: cosmac, schip, xochip or auto.",
+			Value: "auto",
+		},
+		cli.BoolFlag{
+			Name:  "headless",
+			Usage: "Run without a terminal, as fast as possible, discarding the display and reading keys from --keys.",
+		},
+		cli.StringFlag{
+			Name:  "keys",
+			Usage: "When headless, the hex digits of the keys to press, in order, e.g. 1456. The program exits once they run out.",
+		},
+		cli.IntFlag{
+			Name:  "max-cycles",
+			Usage: "When headless, stop after executing this many instructions.",
+		},
+		cli.StringFlag{
+			Name:  "load-state",
+			Usage: "If provided, resumes from a save state written by --save-on-quit.",
+		},
+		cli.StringFlag{
+			Name:  "save-on-quit",
+			Usage: "If provided, writes a save state to this file when the program exits.",
+		},
+		cli.BoolFlag{
+			Name:  "skip-unknown-opcodes",
+			Usage: "Log and skip unknown opcodes instead of stopping, to get past corrupt instructions in bad ROM dumps.",
+		},
+		cli.StringFlag{
+			Name:  "demo",
+			Usage: "Run one of the bundled demo programs instead of a file: " + strings.Join(chip8.Programs(), ", ") + ".",
+		},
+		cli.StringFlag{
+			Name:  "theme",
+			Usage: "Color theme to display with: classic, green, amber or lcd. Defaults to the terminal's colors.",
+		},
+		cli.StringFlag{
+			Name:  "pixel",
+			Usage: "The character to draw pixels that are on with, e.g. * for terminals that don't render the default block well.",
+		},
 	},
 }
 
+// clockError is returned when the --clock flag is out of range.
+type clockError struct {
+	clock int
+}
+
+func (e *clockError) Error() string {
+	return fmt.Sprintf("invalid --clock %d: clock must be > 0, or 0 to run as fast as possible", e.clock)
+}
+
 func runRun(c *cli.Context) error {
+	headless := c.Bool("headless")
+	if !headless && c.Int("max-cycles") > 0 {
+		return errors.New("--max-cycles requires --headless")
+	}
+
+	clock := c.Int("clock")
+	if clock < 0 {
+		return &clockError{clock}
+	}
+
 	// Initialize peripherals.
-	d, err := chip8.NewTermboxDisplay(
-		termbox.ColorDefault, // Foreground
-		termbox.ColorDefault, // Background
+	var (
+		d chip8.Display
+		k chip8.Keypad
 	)
-	defer d.Close()
+	if headless {
+		keys, err := scriptedKeys(c.String("keys"))
+		if err != nil {
+			return err
+		}
+
+		d = chip8.NullDisplay
+		k = &chip8.ScriptedKeypad{Keys: keys, Once: true}
+	} else {
+		term, err := termbox.Open()
+		if err != nil {
+			return err
+		}
+		defer restoreTerminal(term)
+
+		td, err := terminalDisplay(term, c.String("theme"))
+		if err != nil {
+			return err
+		}
+
+		if pixel := c.String("pixel"); pixel != "" {
+			on, err := glyph(pixel)
+			if err != nil {
+				return err
+			}
+			td.SetGlyphs(on, 0)
+		}
+
+		d = td
+		k = chip8.NewTerminalKeypad(term)
+	}
+
+	var r io.Reader = os.Stdin
+	if name := c.String("demo"); name != "" {
+		f, err := chip8.OpenProgram(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	} else if c.Args().Present() {
+		// Read program.
+		f, err := os.Open(c.Args().First())
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	program, err := ioutil.ReadAll(r)
 	if err != nil {
 		return err
 	}
 
-	k := chip8.NewTermboxKeypad()
+	q, err := quirks(c.String("quirks"), program)
+	if err != nil {
+		return err
+	}
 
 	// Initialize CPU.
 	cpu, err := chip8.NewCPU(&chip8.Options{
-		ClockSpeed: time.Duration(c.Int("clock")),
+		ClockSpeed:  time.Duration(clock),
+		Unthrottled: headless || clock == 0,
+		Quirks:      q,
 	})
 	if err != nil {
 		return err
 	}
 	cpu.Graphics.Display = d
 	cpu.Keypad = k
+	cpu.SkipUnknownOpcodes = c.Bool("skip-unknown-opcodes")
 
 	// If a log file is specified, create a logger and add it to the CPU.
 	if fname := c.String("log"); fname != "" {
@@ -62,27 +183,138 @@ func runRun(c *cli.Context) error {
 		cpu.Logger = log.New(f, "", 0)
 	}
 
-	r := os.Stdin
-	if c.Args().Present() {
-		// Read program.
-		r, err = os.Open(c.Args().First())
-		if err != nil {
+	_, err = cpu.LoadBytes(program)
+	if err != nil {
+		return err
+	}
+
+	if fname := c.String("load-state"); fname != "" {
+		if err := loadState(cpu, fname); err != nil {
+			return err
+		}
+	}
+
+	if n := c.Int("max-cycles"); n > 0 {
+		err = cpu.RunSteps(n)
+	} else {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-sig
+			cpu.Stop()
+		}()
+
+		// Run it.
+		err = runProgram(cpu, program)
+	}
+	if err != nil {
+		return err
+	}
+
+	if fname := c.String("save-on-quit"); fname != "" {
+		return saveState(cpu, fname)
+	}
+
+	return nil
+}
+
+// restoreTerminal closes term, and is deferred right after it's opened. If
+// the command panics, the panic is recovered and raised again once the
+// terminal has been restored, so it's printed to a usable terminal rather
+// than one that's still in raw mode.
+func restoreTerminal(term interface {
+	Close()
+}) {
+	r := recover()
+	term.Close()
+	if r != nil {
+		panic(r)
+	}
+}
+
+// runProgram runs the CPU, starting the program over whenever the keypad
+// asks for a reset.
+func runProgram(cpu *chip8.CPU, program []byte) error {
+	for {
+		err := cpu.Run()
+		if err != chip8.ErrReset {
+			return err
+		}
+
+		if _, err := cpu.Reload(program); err != nil {
 			return err
 		}
 	}
-	_, err = cpu.Load(r)
+}
+
+// loadState restores the save state in the named file.
+func loadState(cpu *chip8.CPU, fname string) error {
+	data, err := ioutil.ReadFile(fname)
 	if err != nil {
 		return err
 	}
 
-	sig := make(chan os.Signal)
-	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-sig
-		cpu.Stop()
-	}()
+	return cpu.UnmarshalBinary(data)
+}
+
+// saveState writes a save state to the named file.
+func saveState(cpu *chip8.CPU, fname string) error {
+	data, err := cpu.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(fname, data, 0644)
+}
+
+// scriptedKeys parses a string of hex digits into keys for a
+// chip8.ScriptedKeypad.
+func scriptedKeys(s string) ([]byte, error) {
+	var keys []byte
+	for _, r := range s {
+		k, err := strconv.ParseUint(string(r), 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key: %q", r)
+		}
+		keys = append(keys, byte(k))
+	}
+	return keys, nil
+}
+
+// glyph returns the single character in s.
+func glyph(s string) (rune, error) {
+	r := []rune(s)
+	if len(r) != 1 {
+		return 0, fmt.Errorf("invalid --pixel %q: must be a single character", s)
+	}
+	return r[0], nil
+}
+
+// quirks returns the chip8.Quirks for the named profile. The "auto" profile
+// inspects the program to choose one.
+func quirks(name string, program []byte) (chip8.Quirks, error) {
+	if name == "auto" {
+		return chip8.DetectQuirks(program), nil
+	}
+
+	return chip8.QuirksProfile(name)
+}
+
+// terminalDisplay returns a TerminalDisplay that draws to term with the named
+// theme, or the terminal's default colors if name is empty.
+func terminalDisplay(term chip8.Terminal, name string) (*chip8.TerminalDisplay, error) {
+	if name == "" {
+		return chip8.NewTerminalDisplay(
+			term,
+			chip8.ColorDefault, // Foreground
+			chip8.ColorDefault, // Background
+		)
+	}
+
+	theme, ok := chip8.LookupTheme(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown theme: %q", name)
+	}
 
-	// Run it.
-	err = cpu.Run()
-	return err
+	return chip8.NewTerminalDisplayTheme(term, theme)
 }
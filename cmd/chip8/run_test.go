@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ejholmes/chip8"
+)
+
+func TestQuirks(t *testing.T) {
+	tests := []struct {
+		name    string
+		program []byte
+		quirks  chip8.Quirks
+		err     bool
+	}{
+		{"cosmac", nil, chip8.QuirksCOSMAC, false},
+		{"schip", nil, chip8.QuirksSCHIP, false},
+		{"xochip", nil, chip8.QuirksXOCHIP, false},
+		{"auto", []byte{0x00, 0xE0}, chip8.Quirks{}, false},
+		{"auto", []byte{0xF0, 0x00, 0x12, 0x34}, chip8.QuirksXOCHIP, false},
+		{"bogus", nil, chip8.Quirks{}, true},
+	}
+
+	for _, tt := range tests {
+		q, err := quirks(tt.name, tt.program)
+		if (err != nil) != tt.err {
+			t.Errorf("quirks(%q) => error %v", tt.name, err)
+		}
+
+		if q != tt.quirks {
+			t.Errorf("quirks(%q) => %+v; want %+v", tt.name, q, tt.quirks)
+		}
+	}
+}
+
+func TestRun_Headless(t *testing.T) {
+	tests := []struct {
+		args []string
+		err  bool
+	}{
+		{[]string{"--headless", "--max-cycles", "2000", "--keys", "14"}, false},
+
+		// The program exits once the keys run out.
+		{[]string{"--headless"}, false},
+		{[]string{"--headless", "--keys", "14"}, false},
+
+		{[]string{"--headless", "--keys", "1x"}, true},
+		{[]string{"--headless", "--demo", "invaders.ch8"}, false},
+		{[]string{"--headless", "--demo", "missing.ch8"}, true},
+		{[]string{"--max-cycles", "2000"}, true},
+	}
+
+	for _, tt := range tests {
+		args := append([]string{"chip8", "run"}, tt.args...)
+		args = append(args, "../../programs/pong.ch8")
+
+		err := newApp().Run(args)
+		if (err != nil) != tt.err {
+			t.Errorf("chip8 run %v => error %v", tt.args, err)
+		}
+	}
+}
+
+func TestRun_Clock(t *testing.T) {
+	tests := []struct {
+		clock  string
+		status int
+		stderr string
+	}{
+		// 0 runs unthrottled.
+		{"0", 0, ""},
+		{"600", 0, ""},
+
+		{"-1", 1, "error: invalid --clock -1: clock must be > 0, or 0 to run as fast as possible\n"},
+		{"-60", 1, "error: invalid --clock -60: clock must be > 0, or 0 to run as fast as possible\n"},
+	}
+
+	for _, tt := range tests {
+		var stderr bytes.Buffer
+		status := runMain([]string{"chip8", "run", "--headless", "--max-cycles", "100", "--clock", tt.clock, "../../programs/pong.ch8"}, &stderr)
+
+		if status != tt.status {
+			t.Errorf("--clock %s: status => %d; want %d", tt.clock, status, tt.status)
+		}
+		if stderr.String() != tt.stderr {
+			t.Errorf("--clock %s: stderr => %q; want %q", tt.clock, stderr.String(), tt.stderr)
+		}
+	}
+}
+
+func TestRun_SaveState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chip8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	first := filepath.Join(dir, "first.state")
+	second := filepath.Join(dir, "second.state")
+
+	run := func(args ...string) {
+		t.Helper()
+
+		// Enough keys that they don't run out before --max-cycles.
+		keys := strings.Repeat("14", 20)
+
+		args = append([]string{"chip8", "run", "--headless", "--keys", keys}, args...)
+		args = append(args, "../../programs/pong.ch8")
+		if err := newApp().Run(args); err != nil {
+			t.Fatalf("chip8 run %v => error %v", args, err)
+		}
+	}
+
+	restore := func(fname string) *chip8.CPU {
+		t.Helper()
+
+		cpu, err := chip8.NewCPU(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := loadState(cpu, fname); err != nil {
+			t.Fatal(err)
+		}
+		return cpu
+	}
+
+	run("--max-cycles", "500", "--save-on-quit", first)
+
+	cpu := restore(first)
+	if cpu.Cycles != 500 {
+		t.Errorf("Cycles => %d; want 500", cpu.Cycles)
+	}
+	program, err := ioutil.ReadFile("../../programs/pong.ch8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(cpu.Memory[0x200:0x200+len(program)], program) {
+		t.Error("Expected the program to be in the restored memory")
+	}
+
+	// Resuming carries on from where the first run left off.
+	run("--max-cycles", "100", "--load-state", first, "--save-on-quit", second)
+
+	resumed := restore(second)
+	if resumed.Cycles != 600 {
+		t.Errorf("Cycles => %d; want 600", resumed.Cycles)
+	}
+
+	// Save states are validated.
+	if err := ioutil.WriteFile(first, []byte("garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = newApp().Run([]string{"chip8", "run", "--headless", "--load-state", first, "../../programs/pong.ch8"})
+	if err != chip8.ErrInvalidState {
+		t.Errorf("err => %v; want %v", err, chip8.ErrInvalidState)
+	}
+}
+
+func TestRunProgram_Reset(t *testing.T) {
+	program := []byte{
+		0x70, 0x01, // 0x200: ADD V0, 0x01
+		0xA2, 0x0A, // 0x202: LD I, 0x20A
+		0xF0, 0x55, // 0x204: LD [I], V0
+		0xF1, 0x0A, // 0x206: LD V1, K
+		0x12, 0x06, // 0x208: JP 0x206
+		0x00, 0x00, // 0x20A: Data
+	}
+
+	cpu, err := chip8.NewCPU(&chip8.Options{
+		ClockSpeed:  chip8.DefaultClockSpeed,
+		Unthrottled: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cpu.Graphics.Display = chip8.NullDisplay
+	if _, err := cpu.LoadBytes(program); err != nil {
+		t.Fatal(err)
+	}
+
+	// The reset key is pressed the first time the program waits for a
+	// key, and the program is quit the second time.
+	var reads int
+	cpu.Keypad = chip8.KeypadFunc(func() (byte, error) {
+		reads++
+		if reads == 1 {
+			return 0x00, chip8.ErrReset
+		}
+
+		// Execution started over from 0x200, with the program's data
+		// reloaded, so it's only been incremented once.
+		if cpu.V[0] != 0x01 || cpu.Memory[0x20A] != 0x01 {
+			t.Errorf("V0, 0x20A => 0x%02X, 0x%02X; want 0x01, 0x01", cpu.V[0], cpu.Memory[0x20A])
+		}
+		return 0x00, chip8.ErrQuit
+	})
+
+	if err := runProgram(cpu, program); err != nil {
+		t.Fatal(err)
+	}
+	if reads != 2 {
+		t.Errorf("reads => %d; want 2", reads)
+	}
+}
+
+func TestRestoreTerminal_Panic(t *testing.T) {
+	cpu, err := chip8.NewCPU(&chip8.Options{
+		ClockSpeed:  chip8.DefaultClockSpeed,
+		Unthrottled: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The run loop panics the first time it draws.
+	cpu.Graphics.Display = chip8.DisplayFunc(func(*chip8.Graphics) error {
+		panic("boom")
+	})
+
+	term := new(fakeTerminal)
+
+	r := func() (r interface{}) {
+		defer func() {
+			r = recover()
+		}()
+
+		defer restoreTerminal(term)
+		runProgram(cpu, nil)
+		return nil
+	}()
+
+	if r != "boom" {
+		t.Errorf("recover() => %v; want the panic to be raised again", r)
+	}
+	if term.closed != 1 {
+		t.Errorf("closed => %d; want the terminal to be closed once", term.closed)
+	}
+}
+
+// fakeTerminal records how many times it's closed.
+type fakeTerminal struct {
+	closed int
+}
+
+func (t *fakeTerminal) Close() {
+	t.closed++
+}
@@ -0,0 +1,87 @@
+package chip8
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// TestFontSet draws each digit of the font with Fx29 and Dxyn, and compares
+// it to the bitmaps in testdata/font.txt.
+func TestFontSet(t *testing.T) {
+	glyphs := loadGlyphs(t)
+
+	if len(glyphs) != 16 {
+		t.Fatalf("%d glyphs in testdata/font.txt; want 16", len(glyphs))
+	}
+
+	for digit, glyph := range glyphs {
+		if len(glyph) != 5 {
+			t.Fatalf("digit %X: %d rows in testdata/font.txt; want 5", digit, len(glyph))
+		}
+
+		c := newCPU(t)
+		c.V[0] = byte(digit)
+
+		if err := c.Dispatch(0xF029); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Dispatch(0xD115); err != nil {
+			t.Fatal(err)
+		}
+
+		for y, row := range glyph {
+			// The glyphs are 4 pixels wide, and the rest of the
+			// byte is blank.
+			for x := 0; x < 8; x++ {
+				want := byte(0x00)
+				if x < len(row) && row[x] == '#' {
+					want = 0x01
+				}
+
+				if got := c.Pixels[y*GraphicsWidth+x]; got != want {
+					t.Errorf("digit %X: pixel (%d, %d) => 0x%02X; want 0x%02X", digit, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+// loadGlyphs reads the golden font bitmaps, which are a line with the digit
+// followed by 5 rows of '#' and '.' for each glyph.
+func loadGlyphs(t *testing.T) map[int][]string {
+	f, err := os.Open(filepath.Join("testdata", "font.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	glyphs := make(map[int][]string)
+
+	digit := -1
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+
+		switch {
+		case line == "":
+			digit = -1
+		case digit == -1:
+			d, err := strconv.ParseInt(line, 16, 8)
+			if err != nil {
+				t.Fatal(err)
+			}
+			digit = int(d)
+		default:
+			glyphs[digit] = append(glyphs[digit], line)
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return glyphs
+}
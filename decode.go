@@ -0,0 +1,110 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+// DecodedOp is an opcode split into the fields that instructions use.
+type DecodedOp struct {
+	// The address of the opcode.
+	Addr uint16
+
+	// The opcode.
+	Opcode uint16
+
+	// The lower 4 bits of the high byte, and the upper 4 bits of the low
+	// byte.
+	X, Y byte
+
+	// The lowest 4 bits.
+	N byte
+
+	// The lowest 8 bits.
+	KK byte
+
+	// The lowest 12 bits.
+	NNN uint16
+}
+
+// decode splits the opcode at addr into its fields.
+func decode(addr, op uint16) DecodedOp {
+	return DecodedOp{
+		Addr:   addr,
+		Opcode: op,
+		X:      byte((op & 0x0F00) >> 8),
+		Y:      byte((op & 0x00F0) >> 4),
+		N:      byte(op & 0x000F),
+		KK:     byte(op),
+		NNN:    op & 0x0FFF,
+	}
+}
+
+// Precompile decodes every opcode at an even address from ProgramStart to
+// the end of memory, and returns them. From then on, Step executes opcodes
+// from the decoded copy, using its fields as they are, instead of fetching
+// them from memory and masking the fields out every time. Opcodes are
+// decoded again when memory they were decoded from is written to by opcodes
+// or Load, so self-modifying programs work, but the program shouldn't be
+// changed through Memory directly.
+func (c *CPU) Precompile() []DecodedOp {
+	n := (len(c.Memory) - ProgramStart) / 2
+
+	c.ops = opCache{
+		ops:   make([]DecodedOp, n),
+		valid: make([]bool, n),
+	}
+
+	for i := range c.ops.ops {
+		addr := ProgramStart + i*2
+		c.ops.put(decode(uint16(addr), uint16(c.Memory[addr])<<8|uint16(c.Memory[addr+1])))
+	}
+
+	return append([]DecodedOp(nil), c.ops.ops...)
+}
+
+// opCache holds the opcodes decoded by Precompile, indexed by their offset
+// from ProgramStart. Like the spriteCache, it relies on all writes going
+// through writeByte.
+type opCache struct {
+	ops []DecodedOp
+
+	// whether each opcode matches memory.
+	valid []bool
+}
+
+// index returns the index of the opcode at addr, or -1 if it's not covered.
+func (o *opCache) index(addr int) int {
+	i := addr - ProgramStart
+	if i < 0 || i%2 != 0 || i/2 >= len(o.ops) {
+		return -1
+	}
+	return i / 2
+}
+
+// get returns the decoded opcode at pc, if it's valid.
+func (o *opCache) get(pc uint16) (DecodedOp, bool) {
+	i := o.index(int(pc))
+	if i < 0 || !o.valid[i] {
+		return DecodedOp{}, false
+	}
+	return o.ops[i], true
+}
+
+// put caches a decoded opcode, if its address is covered.
+func (o *opCache) put(d DecodedOp) {
+	if i := o.index(int(d.Addr)); i >= 0 {
+		o.ops[i] = d
+		o.valid[i] = true
+	}
+}
+
+// invalidate drops the opcode that addr is part of.
+func (o *opCache) invalidate(addr int) {
+	if o.ops == nil {
+		return
+	}
+
+	if i := o.index(addr &^ 1); i >= 0 {
+		o.valid[i] = false
+	}
+}
@@ -0,0 +1,72 @@
+package chip8
+
+import "testing"
+
+func TestCPU_Precompile(t *testing.T) {
+	c := newCPU(t)
+	c.LoadBytes([]byte{
+		0xA2, 0x0A, // LD I, 0x20A
+		0x60, 0x62, // LD V0, 0x62
+		0x61, 0x07, // LD V1, 0x07
+		0xF1, 0x55, // LD [I], V1
+		0x63, 0x00, // LD V3, 0x00
+		0x62, 0x01, // LD V2, 0x01, which is overwritten with LD V2, 0x07
+		0x00, 0xFD, // EXIT
+	})
+
+	ops := c.Precompile()
+
+	if len(ops) != (len(c.Memory)-ProgramStart)/2 {
+		t.Fatalf("len(ops) => %d", len(ops))
+	}
+
+	want := DecodedOp{Addr: 0x20A, Opcode: 0x6201, X: 0x2, Y: 0x0, N: 0x1, KK: 0x01, NNN: 0x201}
+	if ops[5] != want {
+		t.Errorf("ops[5] => %+v; want %+v", ops[5], want)
+	}
+
+	if err := c.RunSteps(10); err != nil {
+		t.Fatal(err)
+	}
+
+	// The overwritten opcode was decoded again before it was executed.
+	checkHex(t, "V[2]", c.V[2], 0x07)
+	checkHex(t, "PC", c.PC, 0x20C)
+
+	if d, ok := c.ops.get(0x20A); !ok || d.Opcode != 0x6207 {
+		t.Errorf("ops.get(0x20A) => %+v, %v; want 0x6207", d, ok)
+	}
+}
+
+func BenchmarkPrecompile(b *testing.B) {
+	b.Run("Fetch", func(b *testing.B) {
+		benchmarkProgram(b, loadProgram(b, "invaders.ch8"), nil)
+	})
+
+	b.Run("Precompiled", func(b *testing.B) {
+		benchmarkProgram(b, loadProgram(b, "invaders.ch8"), func(c *CPU) {
+			c.Precompile()
+		})
+	})
+}
+
+func TestCPU_Precompile_Fields(t *testing.T) {
+	c := newCPU(t)
+	c.LoadBytes([]byte{
+		0x60, 0x11, // LD V0, 0x11
+	})
+	c.Precompile()
+
+	// Step executes the decoded fields, not the opcode they came from,
+	// which this doctored entry tells apart.
+	d, _ := c.ops.get(0x200)
+	d.X, d.KK = 0x2, 0x22
+	c.ops.put(d)
+
+	if _, err := c.Step(); err != nil {
+		t.Fatal(err)
+	}
+
+	checkHex(t, "V[0]", c.V[0], 0x00)
+	checkHex(t, "V[2]", c.V[2], 0x22)
+}
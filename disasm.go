@@ -0,0 +1,281 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+import "fmt"
+
+// Disassemble returns the assembly mnemonic for a single opcode, using the
+// mnemonics from Cowgod's Chip-8 Technical Reference (e.g. "LD V1, 0x02").
+// Opcodes that don't decode to an instruction are returned as a "DW"
+// directive.
+func Disassemble(op uint16) string {
+	x := (op & 0x0F00) >> 8
+	y := (op & 0x00F0) >> 4
+	n := op & 0x000F
+	kk := byte(op)
+	nnn := op & 0x0FFF
+
+	switch op & 0xF000 {
+	case 0x0000:
+		switch op {
+		case 0x00E0:
+			return "CLS"
+		case 0x00EE:
+			return "RET"
+		case 0x00FB:
+			return "SCR"
+		case 0x00FC:
+			return "SCL"
+		case 0x00FD:
+			return "EXIT"
+		default:
+			if op&0xFFF0 == 0x00C0 {
+				return fmt.Sprintf("SCD 0x%X", n)
+			}
+			return fmt.Sprintf("SYS 0x%03X", nnn)
+		}
+	case 0x1000:
+		return fmt.Sprintf("JP 0x%03X", nnn)
+	case 0x2000:
+		return fmt.Sprintf("CALL 0x%03X", nnn)
+	case 0x3000:
+		return fmt.Sprintf("SE V%X, 0x%02X", x, kk)
+	case 0x4000:
+		return fmt.Sprintf("SNE V%X, 0x%02X", x, kk)
+	case 0x5000:
+		if n == 0x0 {
+			return fmt.Sprintf("SE V%X, V%X", x, y)
+		}
+	case 0x6000:
+		return fmt.Sprintf("LD V%X, 0x%02X", x, kk)
+	case 0x7000:
+		return fmt.Sprintf("ADD V%X, 0x%02X", x, kk)
+	case 0x8000:
+		if m, ok := aluMnemonics[n]; ok {
+			return fmt.Sprintf("%s V%X, V%X", m, x, y)
+		}
+	case 0x9000:
+		if n == 0x0 {
+			return fmt.Sprintf("SNE V%X, V%X", x, y)
+		}
+	case 0xA000:
+		return fmt.Sprintf("LD I, 0x%03X", nnn)
+	case 0xB000:
+		return fmt.Sprintf("JP V0, 0x%03X", nnn)
+	case 0xC000:
+		return fmt.Sprintf("RND V%X, 0x%02X", x, kk)
+	case 0xD000:
+		return fmt.Sprintf("DRW V%X, V%X, 0x%X", x, y, n)
+	case 0xE000:
+		switch kk {
+		case 0x9E:
+			return fmt.Sprintf("SKP V%X", x)
+		case 0xA1:
+			return fmt.Sprintf("SKNP V%X", x)
+		}
+	case 0xF000:
+		switch kk {
+		case 0x07:
+			return fmt.Sprintf("LD V%X, DT", x)
+		case 0x0A:
+			return fmt.Sprintf("LD V%X, K", x)
+		case 0x15:
+			return fmt.Sprintf("LD DT, V%X", x)
+		case 0x18:
+			return fmt.Sprintf("LD ST, V%X", x)
+		case 0x1E:
+			return fmt.Sprintf("ADD I, V%X", x)
+		case 0x29:
+			return fmt.Sprintf("LD F, V%X", x)
+		case 0x33:
+			return fmt.Sprintf("LD B, V%X", x)
+		case 0x55:
+			return fmt.Sprintf("LD [I], V%X", x)
+		case 0x65:
+			return fmt.Sprintf("LD V%X, [I]", x)
+		}
+	}
+
+	return fmt.Sprintf("DW 0x%04X", op)
+}
+
+// aluMnemonics maps the low nibble of the 8xyn opcodes to their mnemonic.
+var aluMnemonics = map[uint16]string{
+	0x0: "LD",
+	0x1: "OR",
+	0x2: "AND",
+	0x3: "XOR",
+	0x4: "ADD",
+	0x5: "SUB",
+	0x6: "SHR",
+	0x7: "SUBN",
+	0xE: "SHL",
+}
+
+// disassembleAround returns a short listing of the instructions surrounding
+// addr in memory, with the instruction at addr marked.
+func disassembleAround(memory []byte, addr int) string {
+	var s string
+
+	for a := addr - 4; a <= addr+4; a += 2 {
+		if a < 0 || a+1 >= len(memory) {
+			continue
+		}
+
+		op := uint16(memory[a])<<8 | uint16(memory[a+1])
+
+		mark := " "
+		if a == addr {
+			mark = ">"
+		}
+
+		s += fmt.Sprintf("%s 0x%03X  %04X  %s\n", mark, a, op, Disassemble(op))
+	}
+
+	return s
+}
+
+// ListingLine is a line of a Listing: either an instruction or a byte of
+// data.
+type ListingLine struct {
+	// The address of the instruction or data.
+	Addr uint16
+
+	// True if the line is an instruction, and false if it's data.
+	Code bool
+
+	// The opcode for an instruction, or the byte of data.
+	Value uint16
+
+	// The disassembled instruction, or a DB directive for data.
+	Text string
+}
+
+// String returns the line in the same format as the listings in
+// UnknownOpcode errors.
+func (l ListingLine) String() string {
+	if l.Code {
+		return fmt.Sprintf("0x%03X  %04X  %s", l.Addr, l.Value, l.Text)
+	}
+
+	return fmt.Sprintf("0x%03X  %02X    %s", l.Addr, l.Value, l.Text)
+}
+
+// Listing is the disassembly of a program.
+type Listing []ListingLine
+
+// String returns the listing, one line per instruction or byte of data.
+func (l Listing) String() string {
+	var s string
+	for _, line := range l {
+		s += line.String() + "\n"
+	}
+	return s
+}
+
+// DisassembleReachable disassembles a program loaded at base, which is
+// usually ProgramStart, following jumps, calls and skips from the entry point
+// to find the instructions. Any bytes that can't be reached are treated as
+// data, which is usually sprites, so they're rendered as DB directives with
+// the bits drawn in a comment.
+//
+// Jumps with JP V0, addr are assumed to be taken with V0 = 0, so code that's
+// only reached through a jump table may be listed as data.
+func DisassembleReachable(p []byte, base, entry uint16) Listing {
+	// Whether an instruction starts at each byte of the program.
+	code := make([]bool, len(p))
+
+	work := []int{int(entry)}
+	for len(work) > 0 {
+		addr := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		for {
+			i := addr - int(base)
+			if i < 0 || i+1 >= len(p) || code[i] {
+				break
+			}
+			code[i] = true
+
+			op := uint16(p[i])<<8 | uint16(p[i+1])
+			next := addr + 2
+
+			var stop bool
+			switch {
+			// JP addr, JP V0, addr
+			case op&0xF000 == 0x1000, op&0xF000 == 0xB000:
+				work = append(work, int(op&0x0FFF))
+				stop = true
+
+			// CALL addr
+			case op&0xF000 == 0x2000:
+				work = append(work, int(op&0x0FFF))
+
+			// RET, EXIT
+			case op == 0x00EE, op == 0x00FD:
+				stop = true
+
+			// SE, SNE, SKP and SKNP can skip the next instruction.
+			case op&0xF000 == 0x3000,
+				op&0xF000 == 0x4000,
+				op&0xF00F == 0x5000,
+				op&0xF00F == 0x9000,
+				op&0xF0FF == 0xE09E,
+				op&0xF0FF == 0xE0A1:
+				work = append(work, addr+4)
+
+			// Anything else that doesn't disassemble to an
+			// instruction the CPU can execute ends the program.
+			case op != 0x00E0 && unsupported(op, Quirks{}) != "":
+				stop = true
+			}
+
+			if stop {
+				break
+			}
+
+			addr = next
+		}
+	}
+
+	var l Listing
+	for i := 0; i < len(p); {
+		addr := base + uint16(i)
+
+		if code[i] {
+			op := uint16(p[i])<<8 | uint16(p[i+1])
+			l = append(l, ListingLine{
+				Addr:  addr,
+				Code:  true,
+				Value: op,
+				Text:  Disassemble(op),
+			})
+			i += 2
+			continue
+		}
+
+		l = append(l, ListingLine{
+			Addr:  addr,
+			Value: uint16(p[i]),
+			Text:  fmt.Sprintf("DB 0x%02X  ; %s", p[i], spriteArt(p[i])),
+		})
+		i++
+	}
+
+	return l
+}
+
+// spriteArt draws a byte of sprite data, with '#' for the bits that are set
+// and '.' for the bits that aren't.
+func spriteArt(b byte) string {
+	var s [8]byte
+	for i := range s {
+		s[i] = '.'
+		if b&(0x80>>uint(i)) != 0 {
+			s[i] = '#'
+		}
+	}
+	return string(s[:])
+}
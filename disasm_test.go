@@ -0,0 +1,132 @@
+package chip8
+
+import "testing"
+
+func TestDisassemble(t *testing.T) {
+	tests := []struct {
+		op   uint16
+		text string
+	}{
+		{0x00E0, "CLS"},
+		{0x00EE, "RET"},
+		{0x00C3, "SCD 0x3"},
+		{0x00FB, "SCR"},
+		{0x00FC, "SCL"},
+		{0x00FD, "EXIT"},
+		{0x0123, "SYS 0x123"},
+		{0x1200, "JP 0x200"},
+		{0x2345, "CALL 0x345"},
+		{0x31FF, "SE V1, 0xFF"},
+		{0x4A02, "SNE VA, 0x02"},
+		{0x5120, "SE V1, V2"},
+		{0x5121, "DW 0x5121"},
+		{0x6102, "LD V1, 0x02"},
+		{0x7102, "ADD V1, 0x02"},
+		{0x8120, "LD V1, V2"},
+		{0x8121, "OR V1, V2"},
+		{0x8122, "AND V1, V2"},
+		{0x8123, "XOR V1, V2"},
+		{0x8124, "ADD V1, V2"},
+		{0x8125, "SUB V1, V2"},
+		{0x8126, "SHR V1, V2"},
+		{0x8127, "SUBN V1, V2"},
+		{0x812E, "SHL V1, V2"},
+		{0x8128, "DW 0x8128"},
+		{0x9120, "SNE V1, V2"},
+		{0xA123, "LD I, 0x123"},
+		{0xB123, "JP V0, 0x123"},
+		{0xC1F0, "RND V1, 0xF0"},
+		{0xD125, "DRW V1, V2, 0x5"},
+		{0xE19E, "SKP V1"},
+		{0xE1A1, "SKNP V1"},
+		{0xE1FF, "DW 0xE1FF"},
+		{0xF107, "LD V1, DT"},
+		{0xF10A, "LD V1, K"},
+		{0xF115, "LD DT, V1"},
+		{0xF118, "LD ST, V1"},
+		{0xF11E, "ADD I, V1"},
+		{0xF129, "LD F, V1"},
+		{0xF133, "LD B, V1"},
+		{0xF155, "LD [I], V1"},
+		{0xF165, "LD V1, [I]"},
+		{0xF1FF, "DW 0xF1FF"},
+	}
+
+	for _, tt := range tests {
+		if got := Disassemble(tt.op); got != tt.text {
+			t.Errorf("Disassemble(0x%04X) => %q; want %q", tt.op, got, tt.text)
+		}
+	}
+}
+
+func TestDisassembleReachable(t *testing.T) {
+	p := []byte{
+		0x12, 0x04, // JP 0x204
+		0xF0, 0x90, // Sprite
+		0xA2, 0x02, // LD I, 0x202
+		0x30, 0x00, // SE V0, 0x00
+		0x22, 0x0E, // CALL 0x20E
+		0x12, 0x0A, // JP 0x20A
+		0xFF,       //       Padding
+		0x00,       //       Padding
+		0xD0, 0x12, // DRW V0, V1, 2
+		0x00, 0xEE, // RET
+	}
+
+	want := Listing{
+		{0x200, true, 0x1204, "JP 0x204"},
+		{0x202, false, 0xF0, "DB 0xF0  ; ####...."},
+		{0x203, false, 0x90, "DB 0x90  ; #..#...."},
+		{0x204, true, 0xA202, "LD I, 0x202"},
+		{0x206, true, 0x3000, "SE V0, 0x00"},
+		{0x208, true, 0x220E, "CALL 0x20E"},
+		{0x20A, true, 0x120A, "JP 0x20A"},
+		{0x20C, false, 0xFF, "DB 0xFF  ; ########"},
+		{0x20D, false, 0x00, "DB 0x00  ; ........"},
+		{0x20E, true, 0xD012, "DRW V0, V1, 0x2"},
+		{0x210, true, 0x00EE, "RET"},
+	}
+
+	l := DisassembleReachable(p, 0x200, 0x200)
+
+	if len(l) != len(want) {
+		t.Fatalf("DisassembleReachable() =>\n%s\nwant\n%s", l, want)
+	}
+
+	for i := range want {
+		if l[i] != want[i] {
+			t.Errorf("line %d => %q; want %q", i, l[i], want[i])
+		}
+	}
+}
+
+func TestDisassembleReachable_Base(t *testing.T) {
+	// Loaded at 0x600, like ETI 660 programs. The bytes after EXIT would
+	// decode as LD V0, 0x01 if EXIT didn't end the program.
+	p := []byte{
+		0x26, 0x06, // CALL 0x606
+		0x00, 0xFD, // EXIT
+		0x60, 0x01, // Data
+		0x00, 0xEE, // RET
+	}
+
+	want := Listing{
+		{0x600, true, 0x2606, "CALL 0x606"},
+		{0x602, true, 0x00FD, "EXIT"},
+		{0x604, false, 0x60, "DB 0x60  ; .##....."},
+		{0x605, false, 0x01, "DB 0x01  ; .......#"},
+		{0x606, true, 0x00EE, "RET"},
+	}
+
+	l := DisassembleReachable(p, 0x600, 0x600)
+
+	if len(l) != len(want) {
+		t.Fatalf("DisassembleReachable() =>\n%s\nwant\n%s", l, want)
+	}
+
+	for i := range want {
+		if l[i] != want[i] {
+			t.Errorf("line %d => %q; want %q", i, l[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+// SetDisplay swaps the Display that the graphics array is rendered to, and
+// renders the whole graphics array to d straight away, so the new Display
+// doesn't stay blank until the program draws again. It's safe to call while
+// the CPU is running, including from the Display, the Keypad and OnFrame:
+// the swap happens between instructions, and the redraw is from a copy of
+// the graphics array, on the calling goroutine. It returns the error from
+// the redraw.
+func (c *CPU) SetDisplay(d Display) error {
+	c.regMu.Lock()
+	c.displayMu.Lock()
+	c.Graphics.Display = d
+	g := c.Graphics.Clone()
+	c.displayMu.Unlock()
+	c.regMu.Unlock()
+
+	return g.render()
+}
+
+// display returns the Display to render to, which SetDisplay can change
+// from other goroutines.
+func (c *CPU) display() Display {
+	c.displayMu.Lock()
+	defer c.displayMu.Unlock()
+	return c.Graphics.display()
+}
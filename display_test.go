@@ -0,0 +1,112 @@
+package chip8
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCPU_SetDisplay(t *testing.T) {
+	c := newHeadlessCPU(t, []byte{
+		0xD0, 0x01, // DRW V0, V0, 1
+		0x12, 0x00, // JP 0x200
+	}, NullDisplay)
+
+	var a, b int64
+	c.Graphics.Display = countingDisplay(&a)
+
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- c.run(stop)
+	}()
+
+	// Swapping back and forth while the CPU is running is safe, which
+	// the race detector checks.
+	for i := 0; i < 100; i++ {
+		if err := c.SetDisplay(NullDisplay); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.SetDisplay(countingDisplay(&a)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.SetDisplay(countingDisplay(&b)); err != nil {
+		t.Fatal(err)
+	}
+	before := atomic.LoadInt64(&a)
+
+	// The new display gets a full redraw straight away, and the frames
+	// drawn after it.
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt64(&b) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d frames rendered to the new display; want at least 3", atomic.LoadInt64(&b))
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if after := atomic.LoadInt64(&a); after != before {
+		t.Errorf("%d frames rendered to the old display after it was swapped out", after-before)
+	}
+}
+
+func TestCPU_SetDisplay_Callbacks(t *testing.T) {
+	c := newHeadlessCPU(t, []byte{
+		0xD0, 0x01, // 0x200: DRW V0, V0, 1
+		0xF1, 0x0A, // 0x202: LD V1, K
+		0x00, 0xFD, // 0x204: EXIT
+	}, NullDisplay)
+
+	// Displays can be swapped from the Display itself, from OnFrame and
+	// from the Keypad while Fx0A waits on it, without deadlocking.
+	var first, second, third int64
+	c.Graphics.Display = DisplayFunc(func(*Graphics) error {
+		return c.SetDisplay(countingDisplay(&first))
+	})
+	c.OnFrame = func(uint64, *Graphics) {
+		if c.Cycles == 1 {
+			c.SetDisplay(countingDisplay(&second))
+		}
+	}
+	c.Keypad = KeypadFunc(func() (byte, error) {
+		return 0x7, c.SetDisplay(countingDisplay(&third))
+	})
+
+	if err := c.RunSteps(3); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each one got the redraw when it was swapped in.
+	for _, n := range []int64{first, second, third} {
+		if n != 1 {
+			t.Errorf("frames => %d, %d, %d; want 1 each", first, second, third)
+			break
+		}
+	}
+
+	// Errors from the redraw are returned.
+	errRender := errors.New("render failed")
+	err := c.SetDisplay(DisplayFunc(func(*Graphics) error {
+		return errRender
+	}))
+	if err != errRender {
+		t.Errorf("err => %v; want %v", err, errRender)
+	}
+}
+
+// countingDisplay returns a Display that atomically counts the frames it
+// renders in n.
+func countingDisplay(n *int64) Display {
+	return DisplayFunc(func(*Graphics) error {
+		atomic.AddInt64(n, 1)
+		return nil
+	})
+}
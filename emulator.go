@@ -0,0 +1,249 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNoROM is returned by an Emulator that's run before a ROM is loaded.
+var ErrNoROM = errors.New("chip8: no ROM loaded")
+
+// Buzzer represents the CHIP-8 buzzer, which sounds while the sound timer is
+// active.
+type Buzzer interface {
+	// Buzz turns the buzzer on or off.
+	Buzz(on bool)
+}
+
+// BuzzerFunc can be used to wrap a function as a Buzzer.
+type BuzzerFunc func(on bool)
+
+func (f BuzzerFunc) Buzz(on bool) {
+	f(on)
+}
+
+// Emulator wires a CPU up to its peripherals, and manages running, pausing
+// and resetting it. The peripherals should be set before a ROM is loaded.
+type Emulator struct {
+	// The display to render to. The zero value is the DefaultDisplay.
+	Display Display
+
+	// The keypad to read from. The zero value is the DefaultKeypad.
+	Keypad Keypad
+
+	// The buzzer, which is turned on and off as the sound timer starts
+	// and stops. The zero value is silent.
+	Buzzer Buzzer
+
+	options *Options
+
+	mu sync.Mutex
+
+	// the CPU running the ROM.
+	cpu *CPU
+
+	// the ROM, for resets.
+	rom []byte
+
+	paused bool
+
+	// whether the Buzzer is on. Only used by Run.
+	buzzing bool
+
+	// closed to interrupt Run when the CPU is paused, resumed or
+	// replaced.
+	interrupt chan struct{}
+}
+
+// NewEmulator returns a new Emulator that creates its CPU with the given
+// Options. Nil options are the DefaultOptions.
+func NewEmulator(options *Options) *Emulator {
+	if options == nil {
+		options = DefaultOptions
+	}
+
+	return &Emulator{
+		options:   options,
+		interrupt: make(chan struct{}),
+	}
+}
+
+// LoadROM creates a new CPU with the ROM loaded, replacing the current one.
+// If the Emulator is running, it carries on with the new ROM.
+func (e *Emulator) LoadROM(p []byte) error {
+	cpu, err := e.newCPU(p)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.rom = p
+	e.replace(cpu)
+
+	return nil
+}
+
+// Reset starts the ROM over on a new CPU, so any changes the program made to
+// memory are undone too.
+func (e *Emulator) Reset() error {
+	e.mu.Lock()
+	rom := e.rom
+	e.mu.Unlock()
+
+	if rom == nil {
+		return ErrNoROM
+	}
+
+	return e.LoadROM(rom)
+}
+
+// Pause stops the CPU from executing, without stopping Run.
+func (e *Emulator) Pause() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.paused = true
+	e.signal()
+}
+
+// Resume continues executing after a Pause.
+func (e *Emulator) Resume() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.paused = false
+	e.signal()
+}
+
+// Paused returns true while the Emulator is paused.
+func (e *Emulator) Paused() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.paused
+}
+
+// CPU returns the CPU running the ROM, or nil if no ROM has been loaded. It
+// isn't safe to change the CPU while it's running.
+func (e *Emulator) CPU() *CPU {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.cpu
+}
+
+// Run runs the ROM until ctx is done, the Keypad signals a shutdown with
+// ErrQuit, the program halts, or an instruction fails. Like CPU.Run, only a
+// failure returns an error.
+func (e *Emulator) Run(ctx context.Context) error {
+	defer e.buzz(false)
+
+	for {
+		e.mu.Lock()
+		cpu, paused, interrupt := e.cpu, e.paused, e.interrupt
+		e.mu.Unlock()
+
+		if cpu == nil {
+			return ErrNoROM
+		}
+
+		if paused {
+			e.buzz(false)
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-interrupt:
+				continue
+			}
+		}
+
+		stop := make(chan struct{})
+		done := make(chan error, 1)
+		go func() {
+			done <- cpu.run(stop)
+		}()
+
+		stopped, err := e.wait(ctx, cpu, done, interrupt)
+		if !stopped {
+			return err
+		}
+
+		close(stop)
+		if err := <-done; err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+	}
+}
+
+// wait waits for the CPU to finish running, turning the Buzzer on and off
+// along the way. If ctx is done or Run is interrupted first, it returns
+// with stopped true, and the CPU needs to be stopped.
+func (e *Emulator) wait(ctx context.Context, cpu *CPU, done <-chan error, interrupt <-chan struct{}) (stopped bool, err error) {
+	ticker := time.NewTicker(time.Second / 60)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-done:
+			return false, err
+		case <-ctx.Done():
+			return true, nil
+		case <-interrupt:
+			return true, nil
+		case <-ticker.C:
+			e.buzz(cpu.Stats().Beeping)
+		}
+	}
+}
+
+// newCPU returns a new CPU with the peripherals attached and the ROM loaded.
+func (e *Emulator) newCPU(p []byte) (*CPU, error) {
+	cpu, err := NewCPU(e.options)
+	if err != nil {
+		return nil, err
+	}
+
+	cpu.Graphics.Display = e.Display
+	cpu.Keypad = e.Keypad
+
+	if _, err := cpu.LoadBytes(p); err != nil {
+		return nil, err
+	}
+
+	return cpu, nil
+}
+
+// replace swaps in a new CPU. e.mu must be held.
+func (e *Emulator) replace(cpu *CPU) {
+	e.cpu = cpu
+	e.signal()
+}
+
+// signal interrupts Run, so it picks up changes. e.mu must be held.
+func (e *Emulator) signal() {
+	close(e.interrupt)
+	e.interrupt = make(chan struct{})
+}
+
+// buzz turns the Buzzer on or off, if it isn't already.
+func (e *Emulator) buzz(on bool) {
+	if e.Buzzer == nil || e.buzzing == on {
+		return
+	}
+
+	e.buzzing = on
+	e.Buzzer.Buzz(on)
+}
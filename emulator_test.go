@@ -0,0 +1,115 @@
+package chip8
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// emulatorOptions runs as fast as possible.
+var emulatorOptions = &Options{
+	ClockSpeed:  DefaultClockSpeed,
+	Unthrottled: true,
+}
+
+func TestEmulator(t *testing.T) {
+	d := new(MemoryDisplay)
+
+	e := NewEmulator(emulatorOptions)
+	e.Display = d
+	e.Keypad = &ScriptedKeypad{}
+
+	if err := e.Run(context.Background()); err != ErrNoROM {
+		t.Fatalf("Run() => %v; want %v", err, ErrNoROM)
+	}
+
+	if err := e.LoadROM([]byte{
+		0x00, 0xE0, // CLS
+		0xA0, 0x00, // LD I, 0x000
+		0xD0, 0x05, // DRW V0, V0, 5
+		0xF0, 0x0A, // LD V0, K
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The keypad has no keys, so it quits at LD V0, K.
+	if err := e.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	c := e.CPU()
+	checkHex(t, "PC", c.PC, 0x206)
+	// A blank frame when Run starts, and one for DRW.
+	if d.Frames != 2 {
+		t.Errorf("Frames => %d; want 2", d.Frames)
+	}
+
+	if err := e.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	if e.CPU() == c {
+		t.Fatal("Expected Reset to replace the CPU")
+	}
+	checkHex(t, "PC", e.CPU().PC, 0x200)
+}
+
+func TestEmulator_Lifecycle(t *testing.T) {
+	e := NewEmulator(emulatorOptions)
+	if err := e.LoadROM([]byte{
+		0x12, 0x00, // JP 0x200
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- e.Run(ctx)
+	}()
+
+	// Wait for the CPU to get going.
+	running := func() bool {
+		return e.CPU().Stats().Cycles > 0
+	}
+	waitFor(t, running)
+
+	e.Pause()
+	if !e.Paused() {
+		t.Fatal("Expected the emulator to be paused")
+	}
+
+	// Loading a ROM while paused replaces the CPU, which doesn't run
+	// until it's resumed.
+	if err := e.LoadROM([]byte{
+		0x12, 0x00, // JP 0x200
+	}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if running() {
+		t.Fatal("Expected the new CPU not to run while paused")
+	}
+
+	e.Resume()
+	waitFor(t, running)
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitFor waits up to a second for cond to be true.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	for i := 0; i < 1000; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Fatal("Timed out")
+}
@@ -1,7 +1,10 @@
 module github.com/ejholmes/chip8
 
+go 1.16
+
 require (
 	github.com/mattn/go-runewidth v0.0.3 // indirect
 	github.com/nsf/termbox-go v0.0.0-20180819125858-b66b20ab708e
+	github.com/prometheus/client_golang v0.9.2
 	github.com/urfave/cli v1.20.0
 )
@@ -0,0 +1,59 @@
+package chip8
+
+import "testing"
+
+// goldenTests play the bundled programs for a fixed number of cycles with
+// recorded input and a seeded random number generator, and compare the
+// final frame to a known good hash. When a change to the CPU changes a
+// frame on purpose, the new hash is printed in the failure.
+var goldenTests = []struct {
+	name    string
+	program string
+	cycles  int
+
+	// The keys returned by the keypad, in order.
+	keys []byte
+
+	hash string
+}{
+	{
+		name:    "pong title screen",
+		program: "pong.ch8",
+		cycles:  24,
+		keys:    nil, // It's drawn before the keypad is read.
+		hash:    "7e0a5845e3a2ef3ba03c6d2e2b118d6d262a25f65540c68213509c2290463569",
+	},
+	{
+		name:    "pong rally",
+		program: "pong.ch8",
+		cycles:  5000,
+		keys:    []byte{0x01, 0x01, 0x04, 0x00, 0x04, 0x04, 0x01},
+		hash:    "45fed76140caf440125da5fed04a367fa334e5cf6d439e9b93c24e7958f0ae90",
+	},
+	{
+		name:    "invaders",
+		program: "invaders.ch8",
+		cycles:  5000,
+		keys:    []byte{0x04, 0x05, 0x06, 0x06, 0x05, 0x00},
+		hash:    "31edb5e2c6191fdefbd13a9a7c19231394cc498bb6e3b7c1a7c2a496042de078",
+	},
+}
+
+func TestGolden(t *testing.T) {
+	for _, tt := range goldenTests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newHeadlessCPU(t, loadProgram(t, tt.program), new(MemoryDisplay))
+			c.Keypad = &ScriptedKeypad{Keys: tt.keys}
+
+			if err := c.RunSteps(tt.cycles); err != nil {
+				t.Fatal(err)
+			}
+
+			if c.Cycles != uint64(tt.cycles) {
+				t.Fatalf("Ran %d cycles; want %d", c.Cycles, tt.cycles)
+			}
+
+			checkGraphics(t, &c.Graphics, tt.hash)
+		})
+	}
+}
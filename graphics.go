@@ -5,12 +5,21 @@
 package chip8
 
 import (
-	termbox "github.com/nsf/termbox-go"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"sync"
 )
 
 const (
 	GraphicsWidth  = 64 // Pixels
 	GraphicsHeight = 32 // Pixels
+
+	// The size of the graphics array in the SCHIP's high resolution
+	// mode.
+	HiResWidth  = 128 // Pixels
+	HiResHeight = 64  // Pixels
 )
 
 // Display represents the output display for the CHIP-8 graphics array.
@@ -30,53 +39,295 @@ var NullDisplay = DisplayFunc(func(*Graphics) error {
 	return nil
 })
 
+// MemoryDisplay is an implementation of the Display interface that keeps the
+// most recently rendered frame in memory. It's useful for running programs
+// headless.
+type MemoryDisplay struct {
+	// The pixels of the last rendered frame, laid out like
+	// Graphics.Pixels.
+	Pixels [HiResWidth * HiResHeight]byte
+
+	// The number of frames rendered.
+	Frames int
+}
+
+// Render copies the graphics array into memory.
+func (d *MemoryDisplay) Render(g *Graphics) error {
+	d.Pixels = g.Pixels
+	d.Frames++
+	return nil
+}
+
+// BlendMode determines how the pixels of a sprite are combined with the
+// pixels already in the graphics array.
+type BlendMode int
+
+const (
+	// BlendXOR flips the pixels under the set bits of the sprite. This is
+	// how the CHIP-8 draws, and the only mode that reports collisions.
+	BlendXOR BlendMode = iota
+
+	// BlendOR turns on the pixels under the set bits of the sprite.
+	BlendOR
+
+	// BlendAND turns off the pixels under the unset bits of the sprite.
+	BlendAND
+
+	// BlendReplace overwrites the pixels with the bits of the sprite.
+	BlendReplace
+)
+
 // Graphics represents the graphics array for the CHIP-8.
 type Graphics struct {
-	// The raw pixels of the graphics array.
-	Pixels [GraphicsWidth * GraphicsHeight]byte
+	// The raw pixels of the graphics array, row by row. Only the first
+	// Width() * Height() pixels are used, so in low resolution mode, rows
+	// are GraphicsWidth pixels apart.
+	Pixels [HiResWidth * HiResHeight]byte
+
+	// Whether the graphics array is in the SCHIP's high resolution mode,
+	// which is HiResWidth by HiResHeight pixels. Use SetHiRes to change
+	// it.
+	HiRes bool
 
 	// The display to render to. The nil value is the DefaultDisplay.
 	Display
+
+	// When true, Draw hands the Display a copy of the graphics array
+	// rather than the graphics array itself, so the Display can keep it
+	// or render it on another goroutine while the CPU keeps running.
+	CloneFrames bool
+
+	// How sprites are combined with the graphics array. The zero value
+	// is BlendXOR.
+	BlendMode BlendMode
+
+	// ClipX and ClipY make sprites that go past the right and bottom
+	// edges get cut off, rather than wrapping around to the other side.
+	// The axes are independent, since some interpreters wrap on one and
+	// clip on the other. Sprites always start at a position that's
+	// wrapped onto the screen. Dxyn also clips when Quirks.ClipX or
+	// Quirks.ClipY is set.
+	ClipX, ClipY bool
+
+	// The number of frames that have been drawn.
+	Frames uint64
+
+	// The number of sprites written that collided with lit pixels.
+	Collisions uint64
+}
+
+// Width returns the width of the graphics array in pixels, at the current
+// resolution.
+func (g *Graphics) Width() int {
+	if g.HiRes {
+		return HiResWidth
+	}
+	return GraphicsWidth
 }
 
-// DrawSprite draws a sprite to the graphics array starting at coording x, y.
-// If there is a collision, WriteSprite returns true.
+// Height returns the height of the graphics array in pixels, at the current
+// resolution.
+func (g *Graphics) Height() int {
+	if g.HiRes {
+		return HiResHeight
+	}
+	return GraphicsHeight
+}
+
+// SetHiRes switches between the low and high resolution modes. Switching
+// clears the graphics array, since the pixels are laid out differently in
+// each mode.
+func (g *Graphics) SetHiRes(on bool) {
+	if g.HiRes == on {
+		return
+	}
+
+	g.HiRes = on
+	g.Pixels = [HiResWidth * HiResHeight]byte{}
+}
+
+// Clone returns a deep copy of the graphics array.
+func (g *Graphics) Clone() *Graphics {
+	c := *g
+	return &c
+}
+
+// WriteSprite draws a sprite to the graphics array starting at coordinate x,
+// y. If there is a collision, WriteSprite returns true.
 func (g *Graphics) WriteSprite(sprite []byte, x, y byte) (collision bool) {
-	n := len(sprite)
+	collision, _ = g.writeSpriteBytes(sprite, x, y, g.edges())
+	return
+}
+
+// WriteSpriteCount is like WriteSprite, but also returns the number of lit
+// pixels that the sprite turned off.
+func (g *Graphics) WriteSpriteCount(sprite []byte, x, y byte) (collision bool, count int) {
+	return g.writeSpriteBytes(sprite, x, y, g.edges())
+}
 
-	for yl := 0; yl < n; yl++ {
-		// A row of sprite data.
-		r := sprite[yl]
+// PreviewSprite reports what WriteSprite would do, without changing the
+// graphics array. It returns the x, y coordinates of the pixels that would
+// change, in row major order, and whether there would be a collision.
+func (g *Graphics) PreviewSprite(sprite []byte, x, y byte) (changed [][2]int, collision bool) {
+	c := g.Clone()
+	collision = c.WriteSprite(sprite, x, y)
 
+	g.EachPixel(func(x, y uint16, addr int) {
+		if c.Pixels[addr] != g.Pixels[addr] {
+			changed = append(changed, [2]int{int(x), int(y)})
+		}
+	})
+
+	return
+}
+
+// WriteSpriteRect is like WriteSprite, but also returns the bounding
+// rectangle of the pixels that changed, so a Display can redraw just that
+// part of the graphics array. The rectangle is empty if nothing changed.
+// Sprites that wrap around an edge change pixels on both sides of the
+// graphics array, so the rectangle spans its whole width or height.
+func (g *Graphics) WriteSpriteRect(sprite []byte, x, y byte) (collision bool, dirty image.Rectangle) {
+	w, h := g.Width(), g.Height()
+
+	// The pixels under the sprite, before it's drawn.
+	type pixel struct {
+		x, y int
+		v    byte
+	}
+	under := make([]pixel, 0, 8*len(sprite))
+	for yl := range sprite {
+		yp := (int(y)%h + yl) % h
 		for xl := 0; xl < 8; xl++ {
-			// This represents a mask for the bit that we
-			// care about for this coordinate.
-			i := 0x80 >> byte(xl)
+			xp := (int(x)%w + xl) % w
+			under = append(under, pixel{xp, yp, g.Pixels[yp*w+xp]})
+		}
+	}
 
-			// Whether the bit is set or not.
-			on := (r & byte(i)) == byte(i)
+	collision = g.WriteSprite(sprite, x, y)
 
-			// The X position for this pixel
-			xp := uint16(x) + uint16(xl)
-			for xp >= GraphicsWidth {
-				xp = xp - GraphicsWidth
-			}
+	for _, p := range under {
+		if g.Pixels[p.y*w+p.x] != p.v {
+			dirty = dirty.Union(image.Rect(p.x, p.y, p.x+1, p.y+1))
+		}
+	}
 
-			// The Y position for this pixel
-			yp := uint16(y) + uint16(yl)
-			for yp >= GraphicsHeight {
-				yp = yp - GraphicsHeight
-			}
+	return
+}
 
-			if g.Set(xp, yp, on) {
-				collision = true
-			}
+// spriteEdges is how a sprite is drawn where it goes past the edges of the
+// graphics array.
+type spriteEdges struct {
+	// Whether the sprite is cut off at the right and bottom edges,
+	// instead of wrapping around.
+	clipX, clipY bool
+
+	// Whether pixels that wrap around an edge report collisions.
+	wrapCollisions bool
+}
+
+// edges returns the spriteEdges for ClipX and ClipY.
+func (g *Graphics) edges() spriteEdges {
+	return spriteEdges{clipX: g.ClipX, clipY: g.ClipY, wrapCollisions: true}
+}
+
+// writeSpriteBytes is like WriteSpriteCount, but the edges are handled as
+// described by e.
+func (g *Graphics) writeSpriteBytes(sprite []byte, x, y byte, e spriteEdges) (collision bool, count int) {
+	for yl, r := range sprite {
+		c, n := g.writeSpriteRow(decodeSpriteRow(r), x, y, yl, e)
+		collision = collision || c
+		count += n
+	}
+
+	if collision {
+		g.Collisions++
+	}
+
+	return
+}
+
+// writeSprite is like writeSpriteBytes, but with sprite data that's already
+// been decoded.
+func (g *Graphics) writeSprite(rows []spriteRow, x, y byte, e spriteEdges) (collision bool, count int) {
+	for yl, row := range rows {
+		c, n := g.writeSpriteRow(row, x, y, yl, e)
+		collision = collision || c
+		count += n
+	}
+
+	if collision {
+		g.Collisions++
+	}
+
+	return
+}
+
+// writeSpriteRow draws row yl of a sprite whose top left corner is at
+// coordinate x, y, wrapping around the edges of the graphics array, or
+// clipping at them, as described by e. It also returns the number of lit
+// pixels that were turned off.
+func (g *Graphics) writeSpriteRow(row spriteRow, x, y byte, yl int, e spriteEdges) (collision bool, count int) {
+	w, h := uint16(g.Width()), uint16(g.Height())
+
+	// The Y position for this row, which wrapped if it went past the
+	// bottom edge.
+	yp := uint16(y)%h + uint16(yl)
+	wrappedY := yp >= h
+	if wrappedY && e.clipY {
+		return
+	}
+	yp = yp % h
+
+	for xl, on := range row {
+		// The X position for this pixel, which wrapped if it went past
+		// the right edge.
+		xp := uint16(x)%w + uint16(xl)
+		wrappedX := xp >= w
+		if wrappedX && e.clipX {
+			break
+		}
+		wrapped := wrappedY || wrappedX
+		xp = xp % w
+
+		if g.Set(xp, yp, on) && (e.wrapCollisions || !wrapped) {
+			collision = true
+			count++
 		}
 	}
 
 	return
 }
 
+// String implements the fmt.Stringer interface. It returns the graphics
+// array as lines of text, with a # for each pixel that's on and a space for
+// each pixel that's off.
+func (g *Graphics) String() string {
+	return g.Text('#', ' ')
+}
+
+// Text is like String, but draws pixels that are on and off with the given
+// characters.
+func (g *Graphics) Text(on, off rune) string {
+	var b bytes.Buffer
+	for y := 0; y < g.Height(); y++ {
+		for x := 0; x < g.Width(); x++ {
+			if g.Pixels[y*g.Width()+x] != 0x00 {
+				b.WriteRune(on)
+			} else {
+				b.WriteRune(off)
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Hash returns a hex encoded SHA-256 hash of the pixels in use at the
+// current resolution, which is a compact way to compare frames.
+func (g *Graphics) Hash() string {
+	return fmt.Sprintf("%x", sha256.Sum256(g.Pixels[:g.Width()*g.Height()]))
+}
+
 // Clear clears the display.
 func (g *Graphics) Clear() {
 	g.EachPixel(func(_, _ uint16, addr int) {
@@ -86,34 +337,70 @@ func (g *Graphics) Clear() {
 
 // Draw draws the graphics array to the Display.
 func (g *Graphics) Draw() error {
-	return g.display().Render(g)
+	g.Frames++
+	return g.render()
+}
+
+// render draws the graphics array to the Display, without counting it as a
+// frame.
+func (g *Graphics) render() error {
+	return g.renderTo(g.display())
+}
+
+// renderTo draws the graphics array to d, without counting it as a frame.
+func (g *Graphics) renderTo(d Display) error {
+	if g.CloneFrames {
+		return d.Render(g.Clone())
+	}
+
+	return d.Render(g)
 }
 
-// EachPixel yields each pixel in the graphics array to fn.
+// EachPixel yields each pixel in the graphics array to fn, at the current
+// resolution.
 func (g *Graphics) EachPixel(fn func(x, y uint16, addr int)) {
-	for y := 0; y < GraphicsHeight-1; y++ {
-		for x := 0; x < GraphicsWidth-1; x++ {
-			a := y*GraphicsWidth + x
+	w, h := g.Width(), g.Height()
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			a := y*w + x
 			fn(uint16(x), uint16(y), a)
 		}
 	}
 }
 
-// Set turns the pixel at the given coordinates on or off. If there's a
-// collision, it returns true.
+// Set combines a bit of a sprite with the pixel at the given coordinates,
+// using the BlendMode. If there's a collision, it returns true. Collisions
+// are only reported in BlendXOR mode. Coordinates outside of the graphics
+// array, at the current resolution, are ignored.
 func (g *Graphics) Set(x, y uint16, on bool) (collision bool) {
-	a := x + y*GraphicsWidth
-
-	if g.Pixels[a] == 0x01 {
-		collision = true
+	w, h := uint16(g.Width()), uint16(g.Height())
+	if x >= w || y >= h {
+		return false
 	}
 
+	a := x + y*w
+
 	var v byte
 	if on {
 		v = 0x01
 	}
 
-	g.Pixels[a] = g.Pixels[a] ^ v
+	switch g.BlendMode {
+	case BlendOR:
+		g.Pixels[a] = g.Pixels[a] | v
+		break
+	case BlendAND:
+		g.Pixels[a] = g.Pixels[a] & v
+		break
+	case BlendReplace:
+		g.Pixels[a] = v
+		break
+	default:
+		// Only a bit that's set can turn a lit pixel off.
+		collision = on && g.Pixels[a] == 0x01
+		g.Pixels[a] = g.Pixels[a] ^ v
+	}
 
 	return
 }
@@ -126,46 +413,85 @@ func (g *Graphics) display() Display {
 	return g.Display
 }
 
-// termboxInit initializes termbox with appropriate settings. This should be
-// called before using the TermboxDisplay and TermboxKeypad.
-func termboxInit(bg termbox.Attribute) error {
-	if err := termbox.Init(); err != nil {
-		return err
-	}
+// The characters that a TerminalDisplay draws pixels with, unless
+// NewTerminalDisplayGlyphs or SetGlyphs chooses others.
+const (
+	DefaultOnGlyph  = '█'
+	DefaultOffGlyph = ' '
+)
+
+// TerminalDisplay is an implementation of the Display interface that renders
+// the graphics array to a Terminal, with a cell for each pixel.
+type TerminalDisplay struct {
+	terminal Terminal
+	fg, bg   Attribute
+
+	// the characters drawn for pixels that are on and off, which can be
+	// changed while the CPU is rendering.
+	glyphMu sync.Mutex
+	on, off rune
+}
 
-	termbox.HideCursor()
+// NewTerminalDisplay returns a new TerminalDisplay that draws to t with the
+// given colors, and clears it.
+func NewTerminalDisplay(t Terminal, fg, bg Attribute) (*TerminalDisplay, error) {
+	return NewTerminalDisplayGlyphs(t, fg, bg, DefaultOnGlyph, DefaultOffGlyph)
+}
+
+// NewTerminalDisplayGlyphs is like NewTerminalDisplay, but draws pixels that
+// are on and off with the given characters, like SetGlyphs.
+func NewTerminalDisplayGlyphs(t Terminal, fg, bg Attribute, on, off rune) (*TerminalDisplay, error) {
+	d := &TerminalDisplay{
+		terminal: t,
+		fg:       fg,
+		bg:       bg,
+	}
+	d.SetGlyphs(on, off)
 
-	if err := termbox.Clear(bg, bg); err != nil {
-		return err
+	if err := t.Clear(bg, bg); err != nil {
+		return d, err
 	}
 
-	return termbox.Flush()
+	return d, t.Flush()
 }
 
-// TermboxDisplay is an implementation of the Display interface that renders
-// the graphics array to the terminal.
-type TermboxDisplay struct {
-	fg, bg termbox.Attribute
+// SetGlyphs changes the characters that pixels that are on and off are
+// drawn with, from the next frame on, so '*' can be used where '█' doesn't
+// render well. A zero rune restores the default. It's safe to call while
+// the CPU is running.
+func (d *TerminalDisplay) SetGlyphs(on, off rune) {
+	if on == 0 {
+		on = DefaultOnGlyph
+	}
+	if off == 0 {
+		off = DefaultOffGlyph
+	}
+
+	d.glyphMu.Lock()
+	defer d.glyphMu.Unlock()
+	d.on, d.off = on, off
 }
 
-// NewTermboxDisplay returns a new TermboxDisplay instance.
-func NewTermboxDisplay(fg, bg termbox.Attribute) (*TermboxDisplay, error) {
-	return &TermboxDisplay{
-		fg: fg,
-		bg: bg,
-	}, termboxInit(bg)
+// Glyphs returns the characters that pixels that are on and off are drawn
+// with.
+func (d *TerminalDisplay) Glyphs() (on, off rune) {
+	d.glyphMu.Lock()
+	defer d.glyphMu.Unlock()
+	return d.on, d.off
 }
 
-// Render renders the graphics array to the terminal using Termbox.
-func (d *TermboxDisplay) Render(g *Graphics) error {
+// Render renders the graphics array to the Terminal.
+func (d *TerminalDisplay) Render(g *Graphics) error {
+	on, off := d.Glyphs()
+
 	g.EachPixel(func(x, y uint16, addr int) {
-		v := ' '
+		v := off
 
-		if g.Pixels[addr] == 0x01 {
-			v = '█'
+		if g.ColorAt(int(x), int(y)) != 0 {
+			v = on
 		}
 
-		termbox.SetCell(
+		d.terminal.SetCell(
 			int(x),
 			int(y),
 			v,
@@ -174,9 +500,5 @@ func (d *TermboxDisplay) Render(g *Graphics) error {
 		)
 	})
 
-	return termbox.Flush()
-}
-
-func (d *TermboxDisplay) Close() {
-	termbox.Close()
+	return d.terminal.Flush()
 }
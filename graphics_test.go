@@ -0,0 +1,474 @@
+package chip8
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestGraphics_Clone(t *testing.T) {
+	g := new(Graphics)
+	g.WriteSprite([]byte{0xFF}, 0, 0)
+
+	c := g.Clone()
+	g.Clear()
+
+	checkHex(t, "Pixels[0]", c.Pixels[0], 0x01)
+	checkHex(t, "Pixels[0]", g.Pixels[0], 0x00)
+}
+
+func TestGraphics_Draw_CloneFrames(t *testing.T) {
+	frames := make(chan *Graphics, 16)
+
+	g := new(Graphics)
+	g.CloneFrames = true
+	g.Display = DisplayFunc(func(f *Graphics) error {
+		frames <- f
+		return nil
+	})
+
+	// Render frames on another goroutine while the graphics array keeps
+	// changing. Run with -race.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for f := range frames {
+			var on int
+			for _, p := range f.Pixels {
+				on += int(p)
+			}
+
+			if on != 8 && on != 0 {
+				t.Errorf("Expected a whole sprite in the frame, got %d pixels", on)
+			}
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		g.WriteSprite([]byte{0xFF}, byte(i%24), byte(i%24))
+		g.Draw()
+		g.Clear()
+		g.Draw()
+	}
+
+	close(frames)
+	wg.Wait()
+}
+
+func TestGraphics_BlendMode(t *testing.T) {
+	tests := []struct {
+		mode      BlendMode
+		pixels    []byte
+		collision bool
+	}{
+		{BlendXOR, []byte{0, 1, 1, 0}, true},
+		{BlendOR, []byte{1, 1, 1, 0}, false},
+		{BlendAND, []byte{1, 0, 0, 0}, false},
+		{BlendReplace, []byte{1, 0, 1, 0}, false},
+	}
+
+	for _, tt := range tests {
+		g := new(Graphics)
+		g.BlendMode = tt.mode
+
+		// The pixels start out as 1100, and the sprite is 1010.
+		g.Pixels[0] = 0x01
+		g.Pixels[1] = 0x01
+
+		collision := g.WriteSprite([]byte{0xA0}, 0, 0)
+		if collision != tt.collision {
+			t.Errorf("BlendMode(%d): collision => %v; want %v", tt.mode, collision, tt.collision)
+		}
+
+		for i, want := range tt.pixels {
+			if got := g.Pixels[i]; got != want {
+				t.Errorf("BlendMode(%d): Pixels[%d] => 0x%02X; want 0x%02X", tt.mode, i, got, want)
+			}
+		}
+	}
+}
+
+func TestGraphics_Image(t *testing.T) {
+	fg := color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+	bg := color.RGBA{0x00, 0x00, 0x00, 0xFF}
+
+	g := new(Graphics)
+	g.WriteSprite([]byte{0x80}, 63, 31)
+	g.WriteSprite([]byte{0x80}, 1, 0)
+
+	img := g.Image(4, fg, bg)
+
+	if got, want := img.Bounds(), image.Rect(0, 0, 256, 128); got != want {
+		t.Fatalf("Bounds() => %v; want %v", got, want)
+	}
+
+	tests := []struct {
+		x, y int
+		want color.Color
+	}{
+		{0, 0, bg},
+		{3, 3, bg},
+		{4, 0, fg},
+		{7, 3, fg},
+		{8, 0, bg},
+		{252, 124, fg},
+		{255, 127, fg},
+		{251, 127, bg},
+	}
+
+	for _, tt := range tests {
+		if got := img.At(tt.x, tt.y); got != tt.want {
+			t.Errorf("At(%d, %d) => %v; want %v", tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestGraphics_PaletteImage(t *testing.T) {
+	p := &Palette{
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0xFF, 0x00, 0x00, 0xFF},
+		color.RGBA{0x00, 0xFF, 0x00, 0xFF},
+		color.RGBA{0x00, 0x00, 0xFF, 0xFF},
+	}
+
+	// The second plane is bit 1 of each pixel.
+	g := new(Graphics)
+	g.Set(1, 0, true)
+	g.Pixels[2] |= 0x02
+	g.Set(3, 0, true)
+	g.Pixels[3] |= 0x02
+
+	tests := []struct {
+		x     int
+		index byte
+	}{
+		{0, 0},
+		{1, 1},
+		{2, 2},
+		{3, 3},
+	}
+
+	for _, tt := range tests {
+		if got := g.ColorAt(tt.x, 0); got != tt.index {
+			t.Errorf("ColorAt(%d, 0) => %d; want %d", tt.x, got, tt.index)
+		}
+
+		img := g.PaletteImage(2, p)
+		if got, want := img.At(tt.x*2+1, 1), p[tt.index]; got != want {
+			t.Errorf("At(%d, 1) => %v; want %v", tt.x*2+1, got, want)
+		}
+
+		img = g.PaletteImage(1, nil)
+		if got, want := img.At(tt.x, 0), OctoPalette[tt.index]; got != want {
+			t.Errorf("At(%d, 0) with the default palette => %v; want %v", tt.x, got, want)
+		}
+	}
+
+	// A program that only draws to the first plane is two colors.
+	g = new(Graphics)
+	g.WriteSprite([]byte{0xAA}, 0, 0)
+
+	img := g.PaletteImage(1, p)
+	for x := 0; x < 8; x++ {
+		want := p[(x+1)%2]
+		if got := img.At(x, 0); got != want {
+			t.Errorf("At(%d, 0) => %v; want %v", x, got, want)
+		}
+	}
+}
+
+func TestGraphics_PreviewSprite(t *testing.T) {
+	g := new(Graphics)
+	g.WriteSprite([]byte{0xF0, 0x90}, 60, 0)
+	before := g.Pixels
+
+	tests := []struct {
+		sprite    []byte
+		x, y      byte
+		changed   [][2]int
+		collision bool
+	}{
+		{[]byte{0x80}, 0, 10, [][2]int{{0, 10}}, false},
+
+		// Wraps around the right edge, and turns off lit pixels.
+		{[]byte{0x0C, 0x0C}, 58, 0, [][2]int{{62, 0}, {63, 0}, {62, 1}, {63, 1}}, true},
+
+		// Wraps around the bottom edge.
+		{[]byte{0x80, 0x80}, 5, 31, [][2]int{{5, 0}, {5, 31}}, false},
+	}
+
+	for _, tt := range tests {
+		changed, collision := g.PreviewSprite(tt.sprite, tt.x, tt.y)
+
+		if g.Pixels != before {
+			t.Fatal("Expected PreviewSprite to leave the graphics array unchanged")
+		}
+
+		if fmt.Sprint(changed) != fmt.Sprint(tt.changed) {
+			t.Errorf("PreviewSprite(%X, %d, %d) changed => %v; want %v", tt.sprite, tt.x, tt.y, changed, tt.changed)
+		}
+		if collision != tt.collision {
+			t.Errorf("PreviewSprite(%X, %d, %d) collision => %v; want %v", tt.sprite, tt.x, tt.y, collision, tt.collision)
+		}
+
+		// The preview matches actually drawing the sprite.
+		c := g.Clone()
+		if c.WriteSprite(tt.sprite, tt.x, tt.y) != collision {
+			t.Errorf("WriteSprite(%X, %d, %d) collision doesn't match the preview", tt.sprite, tt.x, tt.y)
+		}
+		var n int
+		for a := range c.Pixels {
+			if c.Pixels[a] != before[a] {
+				n++
+			}
+		}
+		if n != len(changed) {
+			t.Errorf("WriteSprite(%X, %d, %d) changed %d pixels; preview changed %d", tt.sprite, tt.x, tt.y, n, len(changed))
+		}
+	}
+}
+
+func TestGraphics_WriteSpriteRect(t *testing.T) {
+	tests := []struct {
+		sprite []byte
+		x, y   byte
+		dirty  image.Rectangle
+	}{
+		// A 0 in the middle of the screen.
+		{FontSet[0:5], 30, 14, image.Rect(30, 14, 34, 19)},
+
+		// Wrapping around the right edge spans the whole width.
+		{[]byte{0xFF}, 60, 0, image.Rect(0, 0, GraphicsWidth, 1)},
+
+		// Wrapping around the bottom edge spans the whole height.
+		{[]byte{0x80, 0x80}, 5, 31, image.Rect(5, 0, 6, GraphicsHeight)},
+
+		// Nothing to draw.
+		{[]byte{0x00}, 10, 10, image.Rectangle{}},
+	}
+
+	for _, tt := range tests {
+		g := new(Graphics)
+
+		_, dirty := g.WriteSpriteRect(tt.sprite, tt.x, tt.y)
+		if dirty != tt.dirty {
+			t.Errorf("WriteSpriteRect(%X, %d, %d) => %v; want %v", tt.sprite, tt.x, tt.y, dirty, tt.dirty)
+		}
+	}
+
+	// Pixels that the sprite covers but doesn't change aren't dirty.
+	g := new(Graphics)
+	g.BlendMode = BlendOR
+	g.WriteSprite([]byte{0xF0}, 0, 0)
+	if _, dirty := g.WriteSpriteRect([]byte{0xFF}, 0, 0); dirty != image.Rect(4, 0, 8, 1) {
+		t.Errorf("WriteSpriteRect(FF, 0, 0) => %v; want %v", dirty, image.Rect(4, 0, 8, 1))
+	}
+
+	// The collision is the same as WriteSprite's.
+	g = new(Graphics)
+	g.WriteSprite([]byte{0x80}, 0, 0)
+	if collision, _ := g.WriteSpriteRect([]byte{0x80}, 0, 0); !collision {
+		t.Error("Expected a collision")
+	}
+}
+
+func TestGraphics_WriteSpriteCount(t *testing.T) {
+	tests := []struct {
+		sprite []byte
+		x, y   byte
+		want   int
+	}{
+		// Nothing lit underneath.
+		{[]byte{0xFF}, 0, 10, 0},
+
+		// One pixel overlaps.
+		{[]byte{0x80}, 0, 0, 1},
+
+		// Three pixels over two rows overlap.
+		{[]byte{0xC0, 0x40}, 0, 0, 3},
+
+		// Wrapped pixels count too.
+		{[]byte{0x3C}, 62, 0, 2},
+
+		// Bits that aren't set don't collide with lit pixels under
+		// them.
+		{[]byte{0x08}, 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		g := new(Graphics)
+		g.WriteSprite([]byte{0xC3, 0xC3}, 0, 0)
+
+		// The collision is the same as WriteSprite's.
+		want := g.Clone().WriteSprite(tt.sprite, tt.x, tt.y)
+
+		collision, count := g.WriteSpriteCount(tt.sprite, tt.x, tt.y)
+		if count != tt.want {
+			t.Errorf("WriteSpriteCount(%X, %d, %d) count => %d; want %d", tt.sprite, tt.x, tt.y, count, tt.want)
+		}
+		if collision != want {
+			t.Errorf("WriteSpriteCount(%X, %d, %d) collision => %v; want %v", tt.sprite, tt.x, tt.y, collision, want)
+		}
+		if collision != (count > 0) {
+			t.Errorf("WriteSpriteCount(%X, %d, %d) => %v, %d; want the collision and count to agree", tt.sprite, tt.x, tt.y, collision, count)
+		}
+	}
+}
+
+func TestGraphics_String(t *testing.T) {
+	g := new(Graphics)
+	g.WriteSprite(FontSet[5:10], 1, 1) // 1
+	g.WriteSprite([]byte{0x80}, 63, 31)
+
+	lines := strings.Split(g.String(), "\n")
+	if len(lines) != GraphicsHeight+1 || lines[GraphicsHeight] != "" {
+		t.Fatalf("Expected %d lines, got %d", GraphicsHeight, len(lines)-1)
+	}
+
+	blank := strings.Repeat(" ", GraphicsWidth)
+	want := []string{
+		blank,
+		"   #" + blank[4:],
+		"  ##" + blank[4:],
+		"   #" + blank[4:],
+		"   #" + blank[4:],
+		"  ###" + blank[5:],
+	}
+	for y, line := range want {
+		if lines[y] != line {
+			t.Errorf("line %d => %q; want %q", y, lines[y], line)
+		}
+	}
+
+	if got, want := lines[31], blank[1:]+"#"; got != want {
+		t.Errorf("line 31 => %q; want %q", got, want)
+	}
+}
+
+func TestGraphics_Text(t *testing.T) {
+	g := new(Graphics)
+	g.WriteSprite([]byte{0xA0}, 0, 0)
+
+	line := strings.SplitN(g.Text('█', '.'), "\n", 2)[0]
+	if want := "█.█" + strings.Repeat(".", GraphicsWidth-3); line != want {
+		t.Errorf("line 0 => %q; want %q", line, want)
+	}
+}
+
+func TestGraphics_Set_HiRes(t *testing.T) {
+	tests := []struct {
+		hiRes bool
+		x, y  uint16
+		addr  int
+	}{
+		{false, 63, 31, 31*GraphicsWidth + 63},
+		{true, 63, 31, 31*HiResWidth + 63},
+		{true, 100, 50, 50*HiResWidth + 100},
+		{true, 127, 63, len(Graphics{}.Pixels) - 1},
+
+		// Outside of the graphics array at the current resolution.
+		{false, 64, 0, -1},
+		{false, 0, 32, -1},
+		{false, 100, 50, -1},
+		{true, 128, 0, -1},
+		{true, 0, 64, -1},
+	}
+
+	for _, tt := range tests {
+		g := new(Graphics)
+		g.SetHiRes(tt.hiRes)
+
+		if g.Set(tt.x, tt.y, true) {
+			t.Errorf("Set(%d, %d) => unexpected collision", tt.x, tt.y)
+		}
+
+		for a, v := range g.Pixels {
+			if want := a == tt.addr; (v == 0x01) != want {
+				t.Errorf("Set(%d, %d) hiRes=%v => Pixels[%d] = %d", tt.x, tt.y, tt.hiRes, a, v)
+			}
+		}
+	}
+}
+
+func TestGraphics_HiRes(t *testing.T) {
+	g := new(Graphics)
+	g.SetHiRes(true)
+
+	if g.Width() != HiResWidth || g.Height() != HiResHeight {
+		t.Fatalf("size => %dx%d; want %dx%d", g.Width(), g.Height(), HiResWidth, HiResHeight)
+	}
+
+	// Sprites wrap around the edges of the larger array.
+	g.WriteSprite([]byte{0xFF, 0xFF}, 124, 63)
+	for _, p := range [][2]int{{124, 63}, {127, 63}, {0, 63}, {3, 63}, {124, 0}, {3, 0}} {
+		if g.Pixels[p[1]*HiResWidth+p[0]] != 0x01 {
+			t.Errorf("Expected pixel %v to be on", p)
+		}
+	}
+
+	// EachPixel covers every pixel, including the last row and column.
+	var n int
+	g.EachPixel(func(x, y uint16, addr int) {
+		n++
+	})
+	if n != HiResWidth*HiResHeight {
+		t.Errorf("EachPixel yielded %d pixels; want %d", n, HiResWidth*HiResHeight)
+	}
+
+	g.Clear()
+	if g.Pixels != [HiResWidth * HiResHeight]byte{} {
+		t.Error("Expected Clear to clear every pixel")
+	}
+
+	// Switching resolution clears the array too.
+	g.Set(0, 0, true)
+	g.SetHiRes(false)
+	checkHex(t, "Pixels[0]", g.Pixels[0], 0x00)
+}
+
+func TestGraphics_Clip(t *testing.T) {
+	// A 2x2 sprite.
+	sprite := []byte{0xC0, 0xC0}
+
+	tests := []struct {
+		clipX, clipY bool
+		x, y         byte
+		changed      [][2]int
+	}{
+		// Off the right edge.
+		{false, false, 63, 10, [][2]int{{0, 10}, {63, 10}, {0, 11}, {63, 11}}},
+		{true, false, 63, 10, [][2]int{{63, 10}, {63, 11}}},
+		{false, true, 63, 10, [][2]int{{0, 10}, {63, 10}, {0, 11}, {63, 11}}},
+		{true, true, 63, 10, [][2]int{{63, 10}, {63, 11}}},
+
+		// Off the bottom edge.
+		{false, false, 10, 31, [][2]int{{10, 0}, {11, 0}, {10, 31}, {11, 31}}},
+		{true, false, 10, 31, [][2]int{{10, 0}, {11, 0}, {10, 31}, {11, 31}}},
+		{false, true, 10, 31, [][2]int{{10, 31}, {11, 31}}},
+		{true, true, 10, 31, [][2]int{{10, 31}, {11, 31}}},
+
+		// Off the bottom right corner.
+		{false, false, 63, 31, [][2]int{{0, 0}, {63, 0}, {0, 31}, {63, 31}}},
+		{true, false, 63, 31, [][2]int{{63, 0}, {63, 31}}},
+		{false, true, 63, 31, [][2]int{{0, 31}, {63, 31}}},
+		{true, true, 63, 31, [][2]int{{63, 31}}},
+
+		// Starting positions past the edges still wrap onto the
+		// screen.
+		{true, true, 64 + 10, 32 + 10, [][2]int{{10, 10}, {11, 10}, {10, 11}, {11, 11}}},
+	}
+
+	for _, tt := range tests {
+		g := &Graphics{ClipX: tt.clipX, ClipY: tt.clipY}
+
+		changed, _ := g.PreviewSprite(sprite, tt.x, tt.y)
+		if fmt.Sprint(changed) != fmt.Sprint(tt.changed) {
+			t.Errorf("ClipX=%v ClipY=%v: PreviewSprite(%X, %d, %d) changed => %v; want %v", tt.clipX, tt.clipY, sprite, tt.x, tt.y, changed, tt.changed)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+import "time"
+
+// DefaultIdleSleep is how long an idle CPU sleeps between instructions.
+var DefaultIdleSleep = time.Millisecond
+
+// sleep is used to sleep while the CPU is idle. It can be mocked in tests.
+var sleep = time.Sleep
+
+// idle tracks how long the program has gone without doing anything visible
+// while it waits on input, for the IdleThreshold.
+type idle struct {
+	// the state as of the last change.
+	state idleState
+
+	// the number of instructions executed since the last change.
+	cycles uint64
+
+	// whether the keypad has been polled since the last change.
+	polled bool
+
+	// the keys that were pressed the last time the keypad was polled.
+	pressed uint16
+
+	// the number of bytes written to memory.
+	writes uint64
+}
+
+// idleState is the state that a program waiting on input doesn't change.
+type idleState struct {
+	V       [16]byte
+	I       uint16
+	frames  uint64
+	pressed uint16
+	writes  uint64
+}
+
+// poll records that the keypad was polled, and the keys that were pressed.
+// Keys that are seen released can satisfy Fx0A again.
+func (c *CPU) poll(pressed uint16) {
+	c.idle.polled = true
+	c.idle.pressed = pressed
+	c.keyWait.held &= pressed
+}
+
+// checkIdle sleeps if the program has polled the keypad without changing
+// any state for IdleThreshold instructions. Key presses count as a change,
+// so the program resumes at full speed as soon as one is seen.
+func (c *CPU) checkIdle() {
+	s := idleState{
+		V:       c.V,
+		I:       c.I,
+		frames:  c.Graphics.Frames,
+		pressed: c.idle.pressed,
+		writes:  c.idle.writes,
+	}
+
+	if s != c.idle.state {
+		c.idle.state = s
+		c.idle.cycles = 0
+		c.idle.polled = false
+		return
+	}
+
+	c.idle.cycles++
+
+	if c.idle.polled && c.idle.cycles >= c.IdleThreshold {
+		sleep(c.idleSleep())
+	}
+}
+
+func (c *CPU) idleSleep() time.Duration {
+	if c.IdleSleep == 0 {
+		return DefaultIdleSleep
+	}
+
+	return c.IdleSleep
+}
+
+// maxDelayWaitLoop is the most instructions a loop can have for
+// SkipDelayWaits to consider it a delay wait.
+const maxDelayWaitLoop = 4
+
+// delayWait is the state when Fx07 last read the delay timer.
+type delayWait struct {
+	pc     uint16
+	cycles uint64
+	state  idleState
+}
+
+// skipDelayWait counts the timers down to zero if the program is in a tight
+// loop that reads the delay timer, and has done nothing else since it last
+// read it. It's called by Fx07 before the delay timer is read into Vx.
+func (c *CPU) skipDelayWait(x uint16) {
+	s := idleState{
+		V:      c.V,
+		I:      c.I,
+		frames: c.Graphics.Frames,
+		writes: c.idle.writes,
+	}
+
+	// The register the delay timer is read into is expected to change.
+	s.V[x] = 0
+
+	w := c.delayWait
+	if c.DT > 0 && w.pc == c.PC && w.state == s && c.Cycles-w.cycles <= maxDelayWaitLoop {
+		if c.ST > c.DT {
+			c.ST -= c.DT
+		} else {
+			c.ST = 0
+		}
+		c.DT = 0
+	}
+
+	c.delayWait = delayWait{pc: c.PC, cycles: c.Cycles, state: s}
+}
@@ -0,0 +1,80 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Palette maps the color index of a pixel, from ColorAt, to the color it's
+// drawn in: index 0 is off, 1 is on in the first plane, 2 is on in XO-CHIP's
+// second plane and 3 is on in both. Programs that only draw to the first
+// plane only ever use the first two colors.
+type Palette [4]color.Color
+
+// OctoPalette is the default palette of the Octo XO-CHIP IDE.
+var OctoPalette = Palette{
+	color.RGBA{0x99, 0x66, 0x00, 0xFF},
+	color.RGBA{0xFF, 0xCC, 0x00, 0xFF},
+	color.RGBA{0xFF, 0x66, 0x00, 0xFF},
+	color.RGBA{0x66, 0x22, 0x00, 0xFF},
+}
+
+// ColorAt returns the color index of the pixel at x, y, with bit 0 set when
+// it's on in the first plane and bit 1 set when it's on in the second.
+// Coordinates outside of the graphics array, at the current resolution, are
+// off.
+func (g *Graphics) ColorAt(x, y int) byte {
+	w, h := g.Width(), g.Height()
+	if x < 0 || y < 0 || x >= w || y >= h {
+		return 0
+	}
+
+	return g.Pixels[y*w+x] & 0x03
+}
+
+// Image returns the graphics array as an image, with each pixel scaled to a
+// scale by scale square. Pixels that are on are drawn in fg, and pixels that
+// are off in bg. A scale less than 1 is treated as 1.
+func (g *Graphics) Image(scale int, fg, bg color.Color) image.Image {
+	return g.PaletteImage(scale, &Palette{bg, fg, fg, fg})
+}
+
+// PaletteImage is like Image, but each pixel is drawn in the color from p for
+// its color index. A nil palette is the OctoPalette.
+func (g *Graphics) PaletteImage(scale int, p *Palette) image.Image {
+	if scale < 1 {
+		scale = 1
+	}
+	if p == nil {
+		p = &OctoPalette
+	}
+
+	w, h := g.Width(), g.Height()
+
+	img := image.NewRGBA(image.Rect(0, 0, w*scale, h*scale))
+	draw.Draw(img, img.Bounds(), image.NewUniform(p[0]), image.ZP, draw.Src)
+
+	var colors [4]image.Image
+	for i, c := range p {
+		colors[i] = image.NewUniform(c)
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := g.ColorAt(x, y)
+			if i == 0 {
+				continue
+			}
+
+			r := image.Rect(x*scale, y*scale, (x+1)*scale, (y+1)*scale)
+			draw.Draw(img, r, colors[i], image.ZP, draw.Src)
+		}
+	}
+
+	return img
+}
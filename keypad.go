@@ -5,10 +5,12 @@
 package chip8
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
-
-	"github.com/nsf/termbox-go"
+	"io"
+	"sync"
+	"time"
 )
 
 // Keypad represents a CHIP-8 Keypad.
@@ -18,6 +20,26 @@ type Keypad interface {
 	ReadByte() (byte, error)
 }
 
+// KeyState can be implemented by a Keypad that knows which keys are held
+// down. The CPU polls these Keypads instead of waiting on ReadByte, so
+// programs keep running while no keys are pressed.
+type KeyState interface {
+	// Pressed returns a bitmask of the keys that are held down, where bit
+	// n is set when key n is pressed. Like ReadByte, it returns ErrQuit
+	// to indicate a shutdown.
+	Pressed() (uint16, error)
+}
+
+// lowestKey returns the lowest numbered key in a non-zero bitmask of keys.
+func lowestKey(pressed uint16) byte {
+	var k byte
+	for pressed&1 == 0 {
+		pressed >>= 1
+		k++
+	}
+	return k
+}
+
 // Keypad func can be used to wrap a function that returns a byte as a Keypad.
 type KeypadFunc func() (byte, error)
 
@@ -30,35 +52,542 @@ var NullKeypad = KeypadFunc(func() (byte, error) {
 	return 0x00, errors.New("null keypad not usable")
 })
 
-// TermboxKeypad is a Keypad implementation that maps keys from a standard
-// keyboard to the CHIP-8 keyboard and uses termbox to poll for events.
-type TermboxKeypad struct{}
+// ScriptedKeypad is a Keypad that returns a fixed sequence of keys, starting
+// over once the sequence is exhausted. It's useful for driving programs in
+// tests and benchmarks.
+type ScriptedKeypad struct {
+	Keys []byte
+
+	// When true, ReadByte returns ErrQuit once the sequence is
+	// exhausted, rather than starting over.
+	Once bool
+
+	// index of the next key to return.
+	i int
+}
+
+// ReadByte returns the next key in the sequence.
+func (k *ScriptedKeypad) ReadByte() (byte, error) {
+	if len(k.Keys) == 0 || (k.Once && k.i >= len(k.Keys)) {
+		return 0x00, ErrQuit
+	}
+
+	b := k.Keys[k.i%len(k.Keys)]
+	k.i++
+	return b, nil
+}
+
+// MemoryKeypad is a Keypad whose keys are pressed and released
+// programmatically. It implements KeyState. It's safe to press and release
+// keys while the CPU is running.
+type MemoryKeypad struct {
+	mu      sync.Mutex
+	pressed uint16
+}
+
+// Press holds down the given key.
+func (k *MemoryKeypad) Press(key byte) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.pressed |= 1 << (key & 0xF)
+}
+
+// Release lets go of the given key.
+func (k *MemoryKeypad) Release(key byte) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.pressed &^= 1 << (key & 0xF)
+}
+
+// Pressed implements the KeyState interface.
+func (k *MemoryKeypad) Pressed() (uint16, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.pressed, nil
+}
+
+// ReadByte returns the lowest key that's held down, or an error if no keys
+// are held down.
+func (k *MemoryKeypad) ReadByte() (byte, error) {
+	pressed, _ := k.Pressed()
+	if pressed == 0 {
+		return 0x00, errors.New("no key pressed")
+	}
+	return lowestKey(pressed), nil
+}
+
+// CombineKeypads returns a Keypad that takes input from two keypads, so
+// local and remote players can share control, or a test can override a
+// live keypad. ReadByte returns whichever key, or error, either keypad
+// produces first. If either keypad implements KeyState, so does the
+// combined keypad, and a key is pressed when it's pressed on either. Keys
+// read from a keypad that doesn't implement KeyState count as held down for
+// the DefaultKeyReleaseTimeout, like a StdinKeypad's, and errors from it,
+// like ErrQuit, are returned by Pressed.
+//
+// Reading from both keypads starts on the first call to ReadByte, and
+// continues in the background until one of them returns ErrQuit.
+func CombineKeypads(primary, secondary Keypad) Keypad {
+	_, ok1 := primary.(KeyState)
+	_, ok2 := secondary.(KeyState)
+
+	switch {
+	case ok1 && !ok2:
+		secondary = newHeldKeypad(secondary)
+	case ok2 && !ok1:
+		primary = newHeldKeypad(primary)
+	}
+
+	k := &combinedKeypad{
+		keypads: [2]Keypad{primary, secondary},
+		keys:    make(chan keyResult),
+	}
+
+	if ok1 || ok2 {
+		return &combinedKeyState{k}
+	}
+
+	return k
+}
+
+// combinedKeypad is the Keypad returned by CombineKeypads.
+type combinedKeypad struct {
+	keypads [2]Keypad
+	once    sync.Once
+
+	// results read from either keypad, for ReadByte.
+	keys chan keyResult
+
+	// closed when a keypad returns ErrQuit.
+	quit     chan struct{}
+	quitOnce sync.Once
+}
+
+// keyResult is the result of reading from one of the combined keypads.
+type keyResult struct {
+	key byte
+	err error
+}
+
+// start starts reading from both keypads, if it hasn't been started
+// already.
+func (k *combinedKeypad) start() {
+	k.once.Do(func() {
+		k.quit = make(chan struct{})
+		for _, kp := range k.keypads {
+			go k.read(kp)
+		}
+	})
+}
+
+// read hands the keys read from kp to ReadByte, one at a time, until either
+// keypad quits.
+func (k *combinedKeypad) read(kp Keypad) {
+	for {
+		b, err := kp.ReadByte()
+
+		select {
+		case k.keys <- keyResult{b, err}:
+		case <-k.quit:
+			return
+		}
+
+		if err == ErrQuit {
+			k.quitOnce.Do(func() { close(k.quit) })
+			return
+		}
+	}
+}
+
+// ReadByte implements the Keypad interface.
+func (k *combinedKeypad) ReadByte() (byte, error) {
+	k.start()
+
+	select {
+	case r := <-k.keys:
+		return r.key, r.err
+	case <-k.quit:
+		return 0x00, ErrQuit
+	}
+}
+
+// combinedKeyState is the Keypad returned by CombineKeypads when either
+// keypad implements KeyState.
+type combinedKeyState struct {
+	*combinedKeypad
+}
+
+// Pressed implements the KeyState interface.
+func (k *combinedKeyState) Pressed() (uint16, error) {
+	var pressed uint16
+
+	for _, kp := range k.keypads {
+		ks, ok := kp.(KeyState)
+		if !ok {
+			continue
+		}
+
+		p, err := ks.Pressed()
+		if err != nil {
+			return 0, err
+		}
+		pressed |= p
+	}
+
+	return pressed, nil
+}
+
+// heldKeypad adapts a Keypad that doesn't implement KeyState, so it can be
+// combined with one that does. Keys are read from it in the background, and
+// count as held down for the DefaultKeyReleaseTimeout after they're read.
+type heldKeypad struct {
+	keypad Keypad
+	held   *heldKeys
+}
+
+// newHeldKeypad returns a heldKeypad that reads from k.
+func newHeldKeypad(k Keypad) *heldKeypad {
+	h := &heldKeypad{keypad: k}
+	h.held = newHeldKeys(h.next, func() time.Duration {
+		return DefaultKeyReleaseTimeout
+	})
+	return h
+}
+
+// next reads the next key from the keypad. Unknown keys are skipped.
+func (k *heldKeypad) next() (byte, bool, error) {
+	key, err := k.keypad.ReadByte()
+	if _, ok := err.(*UnknownKey); ok {
+		return 0x00, false, nil
+	}
+	return key, err == nil, err
+}
+
+// Pressed implements the KeyState interface.
+func (k *heldKeypad) Pressed() (uint16, error) {
+	return k.held.pressed()
+}
+
+// ReadByte waits for the next key.
+func (k *heldKeypad) ReadByte() (byte, error) {
+	return k.held.readByte()
+}
+
+// heldKeys reads keys in the background, for keyboards that don't report
+// when a key is released, and considers each key held down until a timeout
+// passes without it being read again.
+type heldKeys struct {
+	// reads the next key. It returns false for input that isn't a key,
+	// which is skipped.
+	next func() (byte, bool, error)
+
+	// how long a key is held down after it's read.
+	timeout func() time.Duration
+
+	once sync.Once
+
+	// keys that have been read, in order, for readByte.
+	keys chan byte
+
+	mu sync.Mutex
+
+	// the last time each key was pressed.
+	pressedAt [16]time.Time
+
+	// the error that stopped reading, if any.
+	err error
+}
+
+// newHeldKeys returns a heldKeys that reads keys with next. Reading starts
+// on the first call to pressed or readByte.
+func newHeldKeys(next func() (byte, bool, error), timeout func() time.Duration) *heldKeys {
+	return &heldKeys{
+		next:    next,
+		timeout: timeout,
+		keys:    make(chan byte, 16),
+	}
+}
+
+// start starts reading keys, if it hasn't been started already.
+func (h *heldKeys) start() {
+	h.once.Do(func() {
+		go h.read()
+	})
+}
+
+// read reads keys until next returns an error. Keys above 0xF, which a
+// KeyMap can map to, are held down as their low nibble, like the keypad
+// instructions use them.
+func (h *heldKeys) read() {
+	defer close(h.keys)
+
+	for {
+		key, ok, err := h.next()
+		if err != nil {
+			h.mu.Lock()
+			h.err = err
+			h.mu.Unlock()
+			return
+		}
+
+		if !ok {
+			continue
+		}
+
+		h.mu.Lock()
+		h.pressedAt[key&0xF] = now()
+		h.mu.Unlock()
+
+		// Drop the key if nobody is reading them; it's still pressed.
+		select {
+		case h.keys <- key:
+		default:
+		}
+	}
+}
+
+// pressed returns the keys that are held down, like KeyState.Pressed.
+func (h *heldKeys) pressed() (uint16, error) {
+	h.start()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.err != nil {
+		return 0, h.err
+	}
+
+	var pressed uint16
+	t := now()
+	timeout := h.timeout()
+	for key, at := range h.pressedAt {
+		if !at.IsZero() && t.Sub(at) < timeout {
+			pressed |= 1 << uint(key)
+		}
+	}
+
+	return pressed, nil
+}
+
+// readByte waits for the next key, like Keypad.ReadByte.
+func (h *heldKeys) readByte() (byte, error) {
+	h.start()
 
-func NewTermboxKeypad() *TermboxKeypad {
-	return &TermboxKeypad{}
+	key, ok := <-h.keys
+	if !ok {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return 0x00, h.err
+	}
+	return key, nil
 }
 
-var keyMap = map[rune]byte{
+// KeyMap maps keys on a standard keyboard to keys on the CHIP-8 keypad.
+type KeyMap map[rune]byte
+
+// DefaultKeyMap maps the left hand side of a QWERTY keyboard to the CHIP-8
+// keypad:
+//
+//	1 2 3 4        1 2 3 C
+//	q w e r        4 5 6 D
+//	a s d f   =>   7 8 9 E
+//	z x c v        A 0 B F
+var DefaultKeyMap = KeyMap{
 	'1': 0x01, '2': 0x02, '3': 0x03, '4': 0x0C,
 	'q': 0x04, 'w': 0x05, 'e': 0x06, 'r': 0x0D,
 	'a': 0x07, 's': 0x08, 'd': 0x09, 'f': 0x0E,
 	'z': 0x0A, 'x': 0x00, 'c': 0x0B, 'v': 0x0F,
 }
 
+// Bindings returns the reverse of the KeyMap, mapping each CHIP-8 key to the
+// keyboard key that presses it, for showing the controls. If several keys
+// press the same CHIP-8 key, the lowest is used.
+func (m KeyMap) Bindings() map[byte]rune {
+	b := make(map[byte]rune)
+	for r, key := range m {
+		if prev, ok := b[key]; !ok || r < prev {
+			b[key] = r
+		}
+	}
+	return b
+}
+
+// CombineKeyMaps merges KeyMaps for players sharing a keyboard into a single
+// KeyMap, for games where each player uses their own keys, like the paddles
+// in a two player Pong:
+//
+//	left := KeyMap{'w': 0x1, 's': 0x4}
+//	right := KeyMap{'i': 0xC, 'k': 0xD}
+//	m, err := CombineKeyMaps(left, right)
+//
+// It returns an error if the maps share a keyboard key, or press the same
+// CHIP-8 key, since the players would get in each other's way. Players on
+// separate keyboards can be combined with CombineKeypads instead.
+func CombineKeyMaps(maps ...KeyMap) (KeyMap, error) {
+	combined := make(KeyMap)
+
+	// which map each CHIP-8 key came from.
+	owner := make(map[byte]int)
+
+	for i, m := range maps {
+		for r, key := range m {
+			if _, ok := combined[r]; ok {
+				return nil, fmt.Errorf("chip8: key %q is mapped by more than one key map", r)
+			}
+
+			if o, ok := owner[key]; ok && o != i {
+				return nil, fmt.Errorf("chip8: CHIP-8 key 0x%X is mapped by more than one key map", key)
+			}
+
+			combined[r] = key
+			owner[key] = i
+		}
+	}
+
+	return combined, nil
+}
+
+// escapeKey is the key that quits the program.
 var escapeKey = '0'
 
+// ResetKey is the key that makes the TerminalKeypad return ErrReset. It's a
+// function key, so it doesn't clash with any KeyMap.
+const ResetKey = KeyF5
+
+// DefaultKeyReleaseTimeout is how long a StdinKeypad considers a key held
+// down after it's pressed.
+var DefaultKeyReleaseTimeout = 200 * time.Millisecond
+
+// StdinKeypad is a Keypad that reads raw key presses from a reader, like the
+// standard input of a terminal in raw mode. Terminals don't report when a
+// key is released, so a key is considered held down until ReleaseTimeout
+// passes without it being pressed again. It implements KeyState.
+//
+// StdinKeypads are created with NewStdinKeypad. Reading starts on the first
+// call to ReadByte or Pressed, so KeyMap and ReleaseTimeout should be set
+// before then.
+type StdinKeypad struct {
+	// The mapping of keys read to CHIP-8 keys. The zero value is the
+	// DefaultKeyMap.
+	KeyMap KeyMap
+
+	// How long a key is held down after it's pressed. The zero value is
+	// the DefaultKeyReleaseTimeout.
+	ReleaseTimeout time.Duration
+
+	r    io.RuneReader
+	held *heldKeys
+}
+
+// NewStdinKeypad returns a new StdinKeypad that reads key presses from r in
+// the background. The zero value of StdinKeypad has nothing to read from, so
+// it can't be used.
+func NewStdinKeypad(r io.Reader) *StdinKeypad {
+	k := &StdinKeypad{r: bufio.NewReader(r)}
+	k.held = newHeldKeys(k.next, k.releaseTimeout)
+	return k
+}
+
+// next reads the next key press. Keys that aren't in the KeyMap are skipped,
+// and the escape key quits.
+func (k *StdinKeypad) next() (byte, bool, error) {
+	ch, _, err := k.r.ReadRune()
+	if err == io.EOF || (err == nil && ch == escapeKey) {
+		return 0x00, false, ErrQuit
+	}
+	if err != nil {
+		return 0x00, false, err
+	}
+
+	key, ok := k.keyMap()[ch]
+	return key, ok, nil
+}
+
+// Pressed implements the KeyState interface.
+func (k *StdinKeypad) Pressed() (uint16, error) {
+	return k.held.pressed()
+}
+
+// ReadByte waits for the next key press.
+func (k *StdinKeypad) ReadByte() (byte, error) {
+	return k.held.readByte()
+}
+
+func (k *StdinKeypad) keyMap() KeyMap {
+	if k.KeyMap == nil {
+		return DefaultKeyMap
+	}
+
+	return k.KeyMap
+}
+
+func (k *StdinKeypad) releaseTimeout() time.Duration {
+	if k.ReleaseTimeout == 0 {
+		return DefaultKeyReleaseTimeout
+	}
+
+	return k.ReleaseTimeout
+}
+
+// TerminalKeypad is a Keypad implementation that maps keys from a standard
+// keyboard to the CHIP-8 keyboard and polls a Terminal for key presses.
+type TerminalKeypad struct {
+	// The mapping of keyboard keys to CHIP-8 keys. The zero value is the
+	// DefaultKeyMap.
+	KeyMap KeyMap
+
+	terminal Terminal
+}
+
+// NewTerminalKeypad returns a new TerminalKeypad that reads key presses from
+// t.
+func NewTerminalKeypad(t Terminal) *TerminalKeypad {
+	return &TerminalKeypad{terminal: t}
+}
+
+// UnknownKey is returned by a Keypad when a key that isn't in its KeyMap is
+// pressed, so front-ends can tell it apart from real failures and ignore it.
+type UnknownKey struct {
+	// The key that was pressed.
+	Ch rune
+}
+
+func (e *UnknownKey) Error() string {
+	return fmt.Sprintf("chip8: unknown key: %q", e.Ch)
+}
+
 // Get waits for a keypress.
-func (k *TermboxKeypad) ReadByte() (byte, error) {
-	event := termbox.PollEvent()
+func (k *TerminalKeypad) ReadByte() (byte, error) {
+	return k.key(k.terminal.PollEvent())
+}
 
+// key returns the CHIP-8 key for a Terminal event.
+func (k *TerminalKeypad) key(event Event) (byte, error) {
 	// When the escape key is pressed, exit.
 	if event.Ch == escapeKey {
 		return 0x00, ErrQuit
 	}
 
-	key, ok := keyMap[event.Ch]
+	if event.Key == ResetKey {
+		return 0x00, ErrReset
+	}
+
+	key, ok := k.keyMap()[event.Ch]
 	if !ok {
-		return 0x00, fmt.Errorf("unknown key: %v", event.Ch)
+		return 0x00, &UnknownKey{Ch: event.Ch}
 	}
 	return key, nil
 }
+
+// Bindings returns the keyboard key for each CHIP-8 key. See KeyMap.Bindings.
+func (k *TerminalKeypad) Bindings() map[byte]rune {
+	return k.keyMap().Bindings()
+}
+
+func (k *TerminalKeypad) keyMap() KeyMap {
+	if k.KeyMap == nil {
+		return DefaultKeyMap
+	}
+
+	return k.KeyMap
+}
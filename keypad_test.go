@@ -0,0 +1,351 @@
+package chip8
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStdinKeypad(t *testing.T) {
+	var mu sync.Mutex
+	at := time.Unix(0, 0)
+	now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return at
+	}
+	defer func() { now = time.Now }()
+
+	advance := func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		at = at.Add(d)
+	}
+
+	r, w := io.Pipe()
+	k := NewStdinKeypad(r)
+
+	// Unmapped keys are ignored.
+	go w.Write([]byte("w?1"))
+
+	for _, want := range []byte{0x05, 0x01} {
+		key, err := k.ReadByte()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := key; got != want {
+			t.Fatalf("ReadByte() => 0x%02X; want 0x%02X", got, want)
+		}
+	}
+
+	checkPressed := func(want uint16) {
+		pressed, err := k.Pressed()
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkHex(t, "Pressed()", pressed, want)
+	}
+
+	checkPressed(1<<0x05 | 1<<0x01)
+
+	advance(DefaultKeyReleaseTimeout / 2)
+	go w.Write([]byte("w"))
+	if _, err := k.ReadByte(); err != nil {
+		t.Fatal(err)
+	}
+
+	advance(DefaultKeyReleaseTimeout / 2)
+	checkPressed(1 << 0x05)
+
+	advance(DefaultKeyReleaseTimeout)
+	checkPressed(0)
+
+	w.Close()
+	if _, err := k.ReadByte(); err != ErrQuit {
+		t.Fatalf("ReadByte() => %v; want %v", err, ErrQuit)
+	}
+	if _, err := k.Pressed(); err != ErrQuit {
+		t.Fatalf("Pressed() => %v; want %v", err, ErrQuit)
+	}
+}
+
+func TestStdinKeypad_KeyMap(t *testing.T) {
+	k := NewStdinKeypad(strings.NewReader("jk0"))
+	k.KeyMap = KeyMap{'j': 0x0A, 'k': 0x0B}
+
+	for _, want := range []byte{0x0A, 0x0B} {
+		key, err := k.ReadByte()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := key; got != want {
+			t.Fatalf("ReadByte() => 0x%02X; want 0x%02X", got, want)
+		}
+	}
+
+	if _, err := k.ReadByte(); err != ErrQuit {
+		t.Fatalf("ReadByte() => %v; want %v", err, ErrQuit)
+	}
+}
+
+func TestStdinKeypad_KeyMap_OutOfRange(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+
+	k := NewStdinKeypad(r)
+	k.KeyMap = KeyMap{'j': 0x15}
+	k.ReleaseTimeout = time.Hour
+
+	go w.Write([]byte("j"))
+
+	// The key is read as it's mapped, but held down as its low nibble.
+	key, err := k.ReadByte()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHex(t, "ReadByte()", key, 0x15)
+
+	pressed, err := k.Pressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHex(t, "Pressed()", pressed, uint16(1<<0x5))
+}
+
+func TestCombineKeyMaps(t *testing.T) {
+	left := KeyMap{'w': 0x1, 'a': 0x2, 's': 0x4, 'd': 0x5}
+	right := KeyMap{'i': 0xC, 'j': 0x3, 'k': 0xD, 'l': 0x6}
+
+	m, err := CombineKeyMaps(left, right)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both players' keys are read from the same keyboard.
+	k := NewStdinKeypad(strings.NewReader("wikdjsla0"))
+	k.KeyMap = m
+
+	for _, want := range []byte{0x1, 0xC, 0xD, 0x5, 0x3, 0x4, 0x6, 0x2} {
+		key, err := k.ReadByte()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key != want {
+			t.Fatalf("ReadByte() => 0x%X; want 0x%X", key, want)
+		}
+	}
+
+	tests := []struct {
+		maps []KeyMap
+		err  string
+	}{
+		{[]KeyMap{left, {'w': 0xC}}, `chip8: key 'w' is mapped by more than one key map`},
+		{[]KeyMap{left, {'i': 0x1}}, `chip8: CHIP-8 key 0x1 is mapped by more than one key map`},
+	}
+
+	for _, tt := range tests {
+		if _, err := CombineKeyMaps(tt.maps...); err == nil || err.Error() != tt.err {
+			t.Errorf("CombineKeyMaps() => error %v; want %s", err, tt.err)
+		}
+	}
+
+	// A map can press the same CHIP-8 key with several keys.
+	if _, err := CombineKeyMaps(KeyMap{'w': 0x1, 'W': 0x1}, right); err != nil {
+		t.Errorf("CombineKeyMaps() => error %v", err)
+	}
+}
+
+func TestCombineKeypads(t *testing.T) {
+	a, b := new(MemoryKeypad), new(MemoryKeypad)
+	k := CombineKeypads(a, b)
+
+	ks, ok := k.(KeyState)
+	if !ok {
+		t.Fatal("Expected the combined keypad to implement KeyState")
+	}
+
+	a.Press(0x1)
+	b.Press(0x2)
+	b.Press(0x1)
+
+	pressed, err := ks.Pressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHex(t, "Pressed", pressed, uint16(0x0006))
+
+	a.Release(0x1)
+	pressed, _ = ks.Pressed()
+	checkHex(t, "Pressed", pressed, uint16(0x0006))
+
+	b.Release(0x1)
+	b.Release(0x2)
+	pressed, _ = ks.Pressed()
+	checkHex(t, "Pressed", pressed, uint16(0x0000))
+}
+
+func TestCombineKeypads_Mixed(t *testing.T) {
+	// A keyboard that reports which keys are held, and a network player
+	// whose keys only arrive through ReadByte.
+	keyboard := new(MemoryKeypad)
+	keys := make(chan byte)
+	network := KeypadFunc(func() (byte, error) {
+		key, ok := <-keys
+		if !ok {
+			return 0x00, ErrQuit
+		}
+		return key, nil
+	})
+
+	k := CombineKeypads(keyboard, network)
+	ks, ok := k.(KeyState)
+	if !ok {
+		t.Fatal("Expected the combined keypad to implement KeyState")
+	}
+
+	keyboard.Press(0x2)
+	if _, err := ks.Pressed(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The network key is pressed once it's been read.
+	keys <- 0x7
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		pressed, err := ks.Pressed()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pressed == 0x0084 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Pressed => 0x%04X; want 0x0084", pressed)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// And quitting is passed on.
+	close(keys)
+	for {
+		_, err := ks.Pressed()
+		if err == ErrQuit {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected Pressed to return ErrQuit")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCombineKeypads_ReadByte(t *testing.T) {
+	// The primary keypad never produces a key, so the secondary wins.
+	block := make(chan struct{})
+	defer close(block)
+	primary := KeypadFunc(func() (byte, error) {
+		<-block
+		return 0x00, ErrQuit
+	})
+	secondary := &ScriptedKeypad{Keys: []byte{0x7, 0x8}}
+
+	k := CombineKeypads(primary, secondary)
+	if _, ok := k.(KeyState); ok {
+		t.Fatal("Expected the combined keypad not to implement KeyState")
+	}
+
+	for _, want := range []byte{0x7, 0x8} {
+		key, err := k.ReadByte()
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkHex(t, "key", key, want)
+	}
+
+	// Once either keypad quits, so does the combined keypad.
+	k = CombineKeypads(primary, &ScriptedKeypad{})
+	for i := 0; i < 2; i++ {
+		if _, err := k.ReadByte(); err != ErrQuit {
+			t.Fatalf("ReadByte() => %v; want %v", err, ErrQuit)
+		}
+	}
+}
+
+func TestTerminalKeypad_Bindings(t *testing.T) {
+	k := NewTerminalKeypad(nil)
+
+	b := k.Bindings()
+	if len(b) != 16 {
+		t.Fatalf("len(Bindings()) => %d; want 16", len(b))
+	}
+	for key, want := range map[byte]rune{0x1: '1', 0x5: 'w', 0xC: '4', 0x0: 'x', 0xF: 'v'} {
+		if b[key] != want {
+			t.Errorf("Bindings()[0x%X] => %q; want %q", key, b[key], want)
+		}
+	}
+
+	k.KeyMap = KeyMap{'k': 0x5, 'j': 0x5, 'h': 0x4}
+
+	b = k.Bindings()
+	if len(b) != 2 {
+		t.Fatalf("len(Bindings()) => %d; want 2", len(b))
+	}
+	if b[0x5] != 'j' {
+		t.Errorf("Bindings()[0x5] => %q; want %q", b[0x5], 'j')
+	}
+	if b[0x4] != 'h' {
+		t.Errorf("Bindings()[0x4] => %q; want %q", b[0x4], 'h')
+	}
+}
+
+func TestTerminalKeypad_key(t *testing.T) {
+	k := NewTerminalKeypad(nil)
+
+	key, err := k.key(Event{Ch: 'w'})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHex(t, "key", key, 0x5)
+
+	_, err = k.key(Event{Ch: 'p'})
+	e, ok := err.(*UnknownKey)
+	if !ok {
+		t.Fatalf("err => %v; want an UnknownKey", err)
+	}
+	if e.Ch != 'p' {
+		t.Errorf("Ch => %q; want %q", e.Ch, 'p')
+	}
+	if got, want := err.Error(), `chip8: unknown key: 'p'`; got != want {
+		t.Errorf("Error() => %q; want %q", got, want)
+	}
+
+	if _, err := k.key(Event{Key: ResetKey}); err != ErrReset {
+		t.Errorf("err => %v; want %v", err, ErrReset)
+	}
+	if _, err := k.key(Event{Ch: escapeKey}); err != ErrQuit {
+		t.Errorf("err => %v; want %v", err, ErrQuit)
+	}
+}
+
+func TestCPU_getKey_UnknownKey(t *testing.T) {
+	c := newCPU(t)
+	c.Keypad = KeypadFunc(func() (byte, error) {
+		return 0x00, &UnknownKey{Ch: 'p'}
+	})
+
+	// Unknown keys aren't wrapped, so they can still be detected.
+	c.regMu.Lock()
+	_, err := c.getKey()
+	c.regMu.Unlock()
+	if err == nil {
+		t.Fatal("Expected an error")
+	} else if _, ok := err.(*UnknownKey); !ok {
+		t.Errorf("err => %v; want an UnknownKey", err)
+	}
+}
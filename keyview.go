@@ -0,0 +1,94 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+import "fmt"
+
+// KeypadLayout is how the keys are arranged on the COSMAC VIP's hex keypad,
+// row by row.
+var KeypadLayout = [4][4]byte{
+	{0x1, 0x2, 0x3, 0xC},
+	{0x4, 0x5, 0x6, 0xD},
+	{0x7, 0x8, 0x9, 0xE},
+	{0xA, 0x0, 0xB, 0xF},
+}
+
+// KeyCell is a key in a KeypadView.
+type KeyCell struct {
+	Key  byte
+	Held bool
+}
+
+// KeypadView returns the keys in the KeypadLayout, with the keys that are
+// set in the pressed mask marked as held, for drawing a picture of the
+// keypad.
+func KeypadView(pressed uint16) (cells [4][4]KeyCell) {
+	for r, row := range KeypadLayout {
+		for c, key := range row {
+			cells[r][c] = KeyCell{
+				Key:  key,
+				Held: pressed&(1<<key) != 0,
+			}
+		}
+	}
+
+	return
+}
+
+// LatchedKeys returns the keys in the snapshot taken for LatchKeys in the
+// current frame. It returns false if a snapshot hasn't been taken yet this
+// frame, which is always the case if LatchKeys isn't enabled or the Keypad
+// doesn't implement KeyState.
+func (c *CPU) LatchedKeys() (pressed uint16, ok bool) {
+	return c.keyLatch.pressed, c.keyLatch.valid
+}
+
+// KeypadPanel is a Display for debugging input handling. It renders the
+// graphics array with a TerminalDisplay, along with a picture of the keypad
+// to the right of it, with the keys in the CPU's LatchedKeys highlighted.
+// The CPU needs LatchKeys enabled, and a Keypad that implements KeyState.
+type KeypadPanel struct {
+	display *TerminalDisplay
+	cpu     *CPU
+
+	// the last keys latched, which are shown until the next frame's
+	// snapshot is taken.
+	pressed uint16
+}
+
+// NewKeypadPanel returns a new KeypadPanel that draws with d and shows the
+// keys latched by c.
+func NewKeypadPanel(d *TerminalDisplay, c *CPU) *KeypadPanel {
+	return &KeypadPanel{display: d, cpu: c}
+}
+
+// Render renders the graphics array and the keypad. The CPU releases its
+// lock while it renders, but the latch is only written by the goroutine
+// that's executing instructions, which is the one Render is called on, so
+// reading it here doesn't race.
+func (p *KeypadPanel) Render(g *Graphics) error {
+	if pressed, ok := p.cpu.LatchedKeys(); ok {
+		p.pressed = pressed
+	}
+
+	// Leave a gap between the graphics array and the keypad.
+	left := g.Width() + 2
+
+	for r, row := range KeypadView(p.pressed) {
+		for c, cell := range row {
+			fg := p.display.fg
+			if cell.Held {
+				fg |= AttrReverse
+			}
+
+			label := fmt.Sprintf(" %X ", cell.Key)
+			for i, ch := range label {
+				p.display.terminal.SetCell(left+c*len(label)+i, r, ch, fg, p.display.bg)
+			}
+		}
+	}
+
+	return p.display.Render(g)
+}
@@ -0,0 +1,62 @@
+package chip8
+
+import "testing"
+
+func TestKeypadView(t *testing.T) {
+	// 5, A and F held.
+	cells := KeypadView(1<<0x5 | 1<<0xA | 1<<0xF)
+
+	held := make(map[[2]int]bool)
+	for r, row := range cells {
+		for c, cell := range row {
+			if cell.Key != KeypadLayout[r][c] {
+				t.Errorf("cell %d,%d => key %X; want %X", r, c, cell.Key, KeypadLayout[r][c])
+			}
+			if cell.Held {
+				held[[2]int{r, c}] = true
+			}
+		}
+	}
+
+	want := map[[2]int]bool{
+		{1, 1}: true, // 5
+		{3, 0}: true, // A
+		{3, 3}: true, // F
+	}
+	if len(held) != len(want) {
+		t.Fatalf("held => %v; want %v", held, want)
+	}
+	for cell := range want {
+		if !held[cell] {
+			t.Errorf("Expected cell %v to be held", cell)
+		}
+	}
+}
+
+func TestCPU_LatchedKeys(t *testing.T) {
+	k := new(MemoryKeypad)
+	k.Press(0x4)
+
+	c := newCPU(t)
+	c.Keypad = k
+	c.LatchKeys = true
+	c.LoadBytes([]byte{
+		0x60, 0x04, // LD V0, 0x04
+		0xE0, 0x9E, // SKP V0
+	})
+
+	if _, ok := c.LatchedKeys(); ok {
+		t.Fatal("Expected no keys to be latched before they're read")
+	}
+
+	// At 60 Hz, every Step ends the frame and releases the latch, so
+	// dispatch SKP without one.
+	c.Step()
+	c.Dispatch(0xE09E)
+
+	pressed, ok := c.LatchedKeys()
+	if !ok {
+		t.Fatal("Expected the keys to be latched")
+	}
+	checkHex(t, "pressed", pressed, uint16(1<<0x4))
+}
@@ -0,0 +1,95 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build prometheus
+// +build prometheus
+
+package chip8
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	instructionsDesc = prometheus.NewDesc(
+		"chip8_instructions_total",
+		"The number of instructions executed.",
+		[]string{"cpu"}, nil,
+	)
+	framesDesc = prometheus.NewDesc(
+		"chip8_frames_total",
+		"The number of frames drawn.",
+		[]string{"cpu"}, nil,
+	)
+	collisionsDesc = prometheus.NewDesc(
+		"chip8_collisions_total",
+		"The number of sprites drawn that collided with lit pixels.",
+		[]string{"cpu"}, nil,
+	)
+	beepsDesc = prometheus.NewDesc(
+		"chip8_beeps_total",
+		"The number of times the sound timer was started.",
+		[]string{"cpu"}, nil,
+	)
+)
+
+// Collector is a prometheus.Collector that exports the Stats of a set of
+// named CPUs. It's only built with the prometheus build tag.
+type Collector struct {
+	mu   sync.Mutex
+	cpus map[string]*CPU
+}
+
+// NewCollector returns a new Collector with no CPUs.
+func NewCollector() *Collector {
+	return &Collector{
+		cpus: make(map[string]*CPU),
+	}
+}
+
+// Add adds a CPU to the collector. Its metrics are labeled with the name.
+func (c *Collector) Add(name string, cpu *CPU) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cpus[name] = cpu
+}
+
+// Remove removes the named CPU from the collector.
+func (c *Collector) Remove(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.cpus, name)
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- instructionsDesc
+	ch <- framesDesc
+	ch <- collisionsDesc
+	ch <- beepsDesc
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make([]string, 0, len(c.cpus))
+	for name := range c.cpus {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		s := c.cpus[name].Stats()
+
+		ch <- prometheus.MustNewConstMetric(instructionsDesc, prometheus.CounterValue, float64(s.Cycles), name)
+		ch <- prometheus.MustNewConstMetric(framesDesc, prometheus.CounterValue, float64(s.Frames), name)
+		ch <- prometheus.MustNewConstMetric(collisionsDesc, prometheus.CounterValue, float64(s.Collisions), name)
+		ch <- prometheus.MustNewConstMetric(beepsDesc, prometheus.CounterValue, float64(s.Beeps), name)
+	}
+}
@@ -0,0 +1,55 @@
+//go:build prometheus
+// +build prometheus
+
+package chip8
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollector(t *testing.T) {
+	c := newHeadlessCPU(t, loadProgram(t, "pong.ch8"), new(MemoryDisplay))
+	if err := c.RunSteps(1000); err != nil {
+		t.Fatal(err)
+	}
+
+	collector := NewCollector()
+	collector.Add("pong", c)
+
+	r := prometheus.NewRegistry()
+	if err := r.Register(collector); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := r.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := make(map[string]float64)
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			if got := m.GetLabel()[0].GetValue(); got != "pong" {
+				t.Fatalf("cpu label => %q; want %q", got, "pong")
+			}
+			values[f.GetName()] = m.GetCounter().GetValue()
+		}
+	}
+
+	for _, name := range []string{
+		"chip8_instructions_total",
+		"chip8_frames_total",
+		"chip8_collisions_total",
+		"chip8_beeps_total",
+	} {
+		if _, ok := values[name]; !ok {
+			t.Errorf("Expected %s to be collected", name)
+		}
+	}
+
+	if got, want := values["chip8_instructions_total"], 1000.0; got != want {
+		t.Errorf("chip8_instructions_total => %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OptionsError is returned by Options.Validate, and NewCPU, when any of the
+// Options are invalid. It describes every invalid option, so they can all
+// be fixed at once.
+type OptionsError struct {
+	// What's wrong with each invalid option, like "invalid memory size:
+	// 100".
+	Problems []string
+}
+
+func (e *OptionsError) Error() string {
+	if len(e.Problems) == 1 {
+		return "chip8: " + e.Problems[0]
+	}
+
+	return "chip8: invalid options: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks the options, returning an OptionsError that describes
+// every invalid option, or nil if they're all valid.
+func (o *Options) Validate() error {
+	var problems []string
+	invalid := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	size := o.memorySize()
+	if size <= 0x200 || size > 0x10000 {
+		invalid("invalid memory size: %d", size)
+	}
+
+	// The Clock ticks once per instruction, so it needs a clock speed.
+	if o.ClockSpeed < 0 || (o.ClockSpeed == 0 && !o.Unthrottled) {
+		invalid("invalid clock speed: %d", o.ClockSpeed)
+	}
+
+	// SP is a byte, so it can't count past 255.
+	if levels := o.stackSize(); levels < 0 || levels > 0xFF {
+		invalid("invalid stack size: %d", levels)
+	}
+
+	if o.TraceSize < 0 {
+		invalid("invalid trace size: %d", o.TraceSize)
+	}
+
+	if o.EndOfMemory < EndOfMemoryHalt || o.EndOfMemory > EndOfMemoryError {
+		invalid("invalid end of memory policy: %d", o.EndOfMemory)
+	}
+
+	// There has to be a whole instruction at the entry point.
+	if o.EntryPoint != 0 && int(o.EntryPoint)+1 >= size {
+		invalid("invalid entry point: 0x%03X", o.EntryPoint)
+	}
+
+	if s := o.PersistentStore; s != nil && (s.Start > s.End || int(s.End) >= size) {
+		invalid("invalid persistent store range: 0x%03X-0x%03X", s.Start, s.End)
+	}
+
+	if o.Banks < 0 || o.Banks > MaxBanks {
+		invalid("invalid number of banks: %d", o.Banks)
+	}
+
+	if len(problems) > 0 {
+		return &OptionsError{Problems: problems}
+	}
+
+	return nil
+}
+
+// memorySize returns the amount of memory, in bytes.
+func (o *Options) memorySize() int {
+	if o.MemorySize == 0 {
+		return DefaultMemorySize
+	}
+	return o.MemorySize
+}
+
+// stackSize returns the number of stack levels.
+func (o *Options) stackSize() int {
+	if o.StackSize == 0 {
+		return DefaultStackSize
+	}
+	return o.StackSize
+}
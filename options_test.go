@@ -0,0 +1,57 @@
+package chip8
+
+import "testing"
+
+func TestOptions_Validate(t *testing.T) {
+	if err := DefaultOptions.Validate(); err != nil {
+		t.Fatalf("Validate() => %v", err)
+	}
+
+	o := &Options{
+		ClockSpeed:      0,
+		MemorySize:      0x100,
+		StackSize:       -1,
+		EntryPoint:      0x200,
+		PersistentStore: &PersistentStore{Start: 0x300, End: 0x200},
+		Banks:           -1,
+	}
+
+	err := o.Validate()
+	e, ok := err.(*OptionsError)
+	if !ok {
+		t.Fatalf("Validate() => %v; want an OptionsError", err)
+	}
+
+	// Every invalid option is reported, not just the first.
+	want := []string{
+		"invalid memory size: 256",
+		"invalid clock speed: 0",
+		"invalid stack size: -1",
+		"invalid entry point: 0x200",
+		"invalid persistent store range: 0x300-0x200",
+		"invalid number of banks: -1",
+	}
+	if len(e.Problems) != len(want) {
+		t.Fatalf("Problems => %q; want %q", e.Problems, want)
+	}
+	for i := range want {
+		if e.Problems[i] != want[i] {
+			t.Errorf("Problems[%d] => %q; want %q", i, e.Problems[i], want[i])
+		}
+	}
+
+	if got, want := err.Error(), "chip8: invalid options: invalid memory size: 256; invalid clock speed: 0; invalid stack size: -1; invalid entry point: 0x200; invalid persistent store range: 0x300-0x200; invalid number of banks: -1"; got != want {
+		t.Errorf("Error() => %q; want %q", got, want)
+	}
+
+	// NewCPU refuses them too.
+	if _, err := NewCPU(o); err == nil {
+		t.Error("Expected NewCPU to validate the options")
+	}
+
+	// A single problem reads like any other error.
+	err = (&Options{ClockSpeed: DefaultClockSpeed, TraceSize: -1}).Validate()
+	if got, want := err.Error(), "chip8: invalid trace size: -1"; got != want {
+		t.Errorf("Error() => %q; want %q", got, want)
+	}
+}
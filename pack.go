@@ -0,0 +1,43 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+import "fmt"
+
+// PackBits returns the graphics array packed 8 pixels to a byte, row by row,
+// with the leftmost pixel in the most significant bit, like sprites are.
+// That's 256 bytes in low resolution mode and 1024 in high resolution mode,
+// which is much smaller than Pixels for sending frames over a network.
+func (g *Graphics) PackBits() []byte {
+	p := make([]byte, g.Width()*g.Height()/8)
+
+	g.EachPixel(func(_, _ uint16, addr int) {
+		if g.Pixels[addr] != 0x00 {
+			p[addr/8] |= 0x80 >> uint(addr%8)
+		}
+	})
+
+	return p
+}
+
+// UnpackBits replaces the graphics array with pixels packed by PackBits.
+// The resolution is switched to match the size of p, and an error is
+// returned if it's not the size of either resolution.
+func (g *Graphics) UnpackBits(p []byte) error {
+	switch len(p) {
+	case GraphicsWidth * GraphicsHeight / 8:
+		g.SetHiRes(false)
+	case HiResWidth * HiResHeight / 8:
+		g.SetHiRes(true)
+	default:
+		return fmt.Errorf("chip8: invalid packed graphics size: %d bytes", len(p))
+	}
+
+	g.EachPixel(func(_, _ uint16, addr int) {
+		g.Pixels[addr] = p[addr/8] >> uint(7-addr%8) & 0x01
+	})
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package chip8
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestGraphics_PackBits(t *testing.T) {
+	for _, hiRes := range []bool{false, true} {
+		g := new(Graphics)
+		g.SetHiRes(hiRes)
+
+		r := rand.New(rand.NewSource(1))
+		g.EachPixel(func(_, _ uint16, addr int) {
+			g.Pixels[addr] = byte(r.Intn(2))
+		})
+
+		// The corners, including the last row and column.
+		w, h := uint16(g.Width()), uint16(g.Height())
+		g.Pixels[0] = 0x01
+		g.Pixels[w-1] = 0x01
+		g.Pixels[(h-1)*w] = 0x01
+		g.Pixels[h*w-1] = 0x01
+
+		p := g.PackBits()
+		if want := g.Width() * g.Height() / 8; len(p) != want {
+			t.Fatalf("hiRes=%v: len(PackBits()) => %d; want %d", hiRes, len(p), want)
+		}
+
+		u := new(Graphics)
+		if err := u.UnpackBits(p); err != nil {
+			t.Fatal(err)
+		}
+
+		if u.HiRes != hiRes {
+			t.Errorf("hiRes=%v: HiRes => %v", hiRes, u.HiRes)
+		}
+		if u.Pixels != g.Pixels {
+			t.Errorf("hiRes=%v: Expected the unpacked pixels to match", hiRes)
+		}
+	}
+
+	// The leftmost pixel is the most significant bit.
+	g := new(Graphics)
+	g.WriteSprite([]byte{0xA0}, 8, 0)
+	p := g.PackBits()
+	checkHex(t, "p[0]", p[0], 0x00)
+	checkHex(t, "p[1]", p[1], 0xA0)
+
+	if err := g.UnpackBits(make([]byte, 100)); err == nil {
+		t.Error("Expected an error for a packed size that doesn't match a resolution")
+	}
+}
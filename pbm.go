@@ -0,0 +1,85 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// PBMDisplay is an implementation of the Display interface that writes each
+// frame to an io.Writer as a NetPBM bitmap, which can be piped into tools
+// like pnmtopng. Pixels that are on are black.
+type PBMDisplay struct {
+	// Where frames are written.
+	W io.Writer
+
+	// The width and height, in bitmap pixels, of each pixel. The zero value
+	// is 1.
+	Scale int
+
+	// When true, frames are written in the plain (P1) format, which is
+	// ASCII, instead of the raw (P4) format.
+	Plain bool
+}
+
+// NewPBMDisplay returns a new PBMDisplay that writes raw bitmaps to w.
+func NewPBMDisplay(w io.Writer, scale int) *PBMDisplay {
+	return &PBMDisplay{W: w, Scale: scale}
+}
+
+// Render writes the graphics array to W as a single bitmap.
+func (d *PBMDisplay) Render(g *Graphics) error {
+	scale := d.Scale
+	if scale < 1 {
+		scale = 1
+	}
+
+	stride := g.Width()
+	w, h := stride*scale, g.Height()*scale
+
+	var b bytes.Buffer
+	if d.Plain {
+		fmt.Fprintf(&b, "P1\n%d %d\n", w, h)
+	} else {
+		fmt.Fprintf(&b, "P4\n%d %d\n", w, h)
+	}
+
+	// Raw rows are packed 8 pixels to a byte, padded out to a whole byte.
+	row := make([]byte, (w+7)/8)
+
+	for y := 0; y < h; y++ {
+		for i := range row {
+			row[i] = 0
+		}
+
+		for x := 0; x < w; x++ {
+			on := g.Pixels[(y/scale)*stride+x/scale] != 0x00
+
+			if d.Plain {
+				if on {
+					b.WriteByte('1')
+				} else {
+					b.WriteByte('0')
+				}
+				continue
+			}
+
+			if on {
+				row[x/8] |= 0x80 >> uint(x%8)
+			}
+		}
+
+		if d.Plain {
+			b.WriteByte('\n')
+		} else {
+			b.Write(row)
+		}
+	}
+
+	_, err := d.W.Write(b.Bytes())
+	return err
+}
@@ -0,0 +1,67 @@
+package chip8
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPBMDisplay(t *testing.T) {
+	g := new(Graphics)
+	g.WriteSprite([]byte{0xA0, 0x40}, 0, 0)
+
+	var b bytes.Buffer
+	d := NewPBMDisplay(&b, 1)
+	if err := d.Render(g); err != nil {
+		t.Fatal(err)
+	}
+
+	header := "P4\n64 32\n"
+	if got := b.String()[:len(header)]; got != header {
+		t.Fatalf("header => %q; want %q", got, header)
+	}
+
+	bitmap := b.Bytes()[len(header):]
+	if len(bitmap) != 8*32 {
+		t.Fatalf("len(bitmap) => %d; want %d", len(bitmap), 8*32)
+	}
+
+	want := make([]byte, 8*32)
+	want[0] = 0xA0
+	want[8] = 0x40
+	if !bytes.Equal(bitmap, want) {
+		t.Errorf("bitmap => %X; want %X", bitmap, want)
+	}
+}
+
+func TestPBMDisplay_Plain(t *testing.T) {
+	g := new(Graphics)
+	g.WriteSprite([]byte{0x80}, 0, 0)
+
+	var b bytes.Buffer
+	d := &PBMDisplay{W: &b, Scale: 2, Plain: true}
+	if err := d.Render(g); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(b.String(), "\n")
+	if lines[0] != "P1" || lines[1] != "128 64" {
+		t.Fatalf("header => %q; want %q", lines[:2], []string{"P1", "128 64"})
+	}
+
+	rows := lines[2 : len(lines)-1]
+	if len(rows) != 64 {
+		t.Fatalf("len(rows) => %d; want 64", len(rows))
+	}
+
+	lit := "11" + strings.Repeat("0", 126)
+	for y, row := range rows {
+		want := strings.Repeat("0", 128)
+		if y < 2 {
+			want = lit
+		}
+		if row != want {
+			t.Errorf("row %d => %q; want %q", y, row, want)
+		}
+	}
+}
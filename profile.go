@@ -0,0 +1,105 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Profiler builds a call tree of a running program, following CALL and RET,
+// and counts the instructions executed, and the time spent executing them,
+// in each subroutine. Set it as the CPU's Profiler to start profiling. The
+// zero value is ready to use.
+//
+// The program's entry point is the "main" frame, and subroutines are named
+// after their address, like "0x2A4".
+type Profiler struct {
+	// the folded names of the subroutines that are currently called,
+	// below main, like ";0x210;0x2A4".
+	stack string
+
+	entries map[string]*ProfileEntry
+}
+
+// ProfileEntry is the time spent in one path through the call tree, not
+// counting the subroutines it calls.
+type ProfileEntry struct {
+	// The path through the call tree, outermost first, separated by
+	// semicolons, like "main;0x210;0x2A4".
+	Stack string
+
+	// The number of instructions executed.
+	Instructions uint64
+
+	// How long they took to execute.
+	Time time.Duration
+}
+
+// NewProfiler returns a new Profiler.
+func NewProfiler() *Profiler {
+	return new(Profiler)
+}
+
+// record attributes an executed opcode to the current subroutine, and
+// follows it into or out of a subroutine.
+func (p *Profiler) record(op uint16, d time.Duration) {
+	if p.entries == nil {
+		p.entries = make(map[string]*ProfileEntry)
+	}
+
+	stack := "main" + p.stack
+	e, ok := p.entries[stack]
+	if !ok {
+		e = &ProfileEntry{Stack: stack}
+		p.entries[stack] = e
+	}
+	e.Instructions++
+	e.Time += d
+
+	switch {
+	// CALL addr
+	case op&0xF000 == 0x2000:
+		p.stack += fmt.Sprintf(";0x%03X", op&0x0FFF)
+
+	// RET
+	case op == 0x00EE && p.stack != "":
+		p.stack = p.stack[:strings.LastIndex(p.stack, ";")]
+	}
+}
+
+// Entries returns every path through the call tree that's executed an
+// instruction, sorted by Stack.
+func (p *Profiler) Entries() []ProfileEntry {
+	entries := make([]ProfileEntry, 0, len(p.entries))
+	for _, e := range p.entries {
+		entries = append(entries, *e)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Stack < entries[j].Stack
+	})
+
+	return entries
+}
+
+// WriteFolded writes the call tree in the folded stack format that
+// flamegraph tools read, like Brendan Gregg's flamegraph.pl, with one line
+// per path through the call tree, weighted by instructions executed:
+//
+//	main 12
+//	main;0x210 40
+//	main;0x210;0x2A4 96
+func (p *Profiler) WriteFolded(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	for _, e := range p.Entries() {
+		fmt.Fprintf(b, "%s %d\n", e.Stack, e.Instructions)
+	}
+	return b.Flush()
+}
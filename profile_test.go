@@ -0,0 +1,72 @@
+package chip8
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestProfiler(t *testing.T) {
+	c := newHeadlessCPU(t, []byte{
+		0x22, 0x08, // 0x200: CALL 0x208
+		0x22, 0x0E, // 0x202: CALL 0x20E
+		0x00, 0xFD, // 0x204: EXIT
+		0x00, 0x00, // 0x206: Padding
+		0x60, 0x01, // 0x208: LD V0, 0x01
+		0x22, 0x0E, // 0x20A: CALL 0x20E
+		0x00, 0xEE, // 0x20C: RET
+		0x70, 0x01, // 0x20E: ADD V0, 0x01
+		0x70, 0x01, // 0x210: ADD V0, 0x01
+		0x00, 0xEE, // 0x212: RET
+	}, NullDisplay)
+
+	// The clock moves forward every time it's read, so every instruction
+	// takes some time.
+	c.timeSource = &steppingTimeSource{t: time.Unix(0, 0), step: time.Millisecond}
+
+	// The zero value is ready to use.
+	p := new(Profiler)
+	c.Profiler = p
+
+	if err := c.RunSteps(100); err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	if err := p.WriteFolded(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	// The subroutine at 0x20E is called from main and from 0x208, and
+	// the EXIT that stops the CPU isn't counted.
+	want := `main 2
+main;0x208 3
+main;0x208;0x20E 3
+main;0x20E 3
+`
+	if got := b.String(); got != want {
+		t.Errorf("WriteFolded() =>\n%s\nwant:\n%s", got, want)
+	}
+
+	for _, e := range p.Entries() {
+		if min := time.Duration(e.Instructions) * time.Millisecond; e.Time < min {
+			t.Errorf("%s: Time => %v; want at least %v", e.Stack, e.Time, min)
+		}
+	}
+}
+
+// steppingTimeSource is a TimeSource whose time moves forward by step every
+// time it's read.
+type steppingTimeSource struct {
+	t    time.Time
+	step time.Duration
+}
+
+func (s *steppingTimeSource) Now() time.Time {
+	s.t = s.t.Add(s.step)
+	return s.t
+}
+
+func (s *steppingTimeSource) Tick(d time.Duration) <-chan time.Time {
+	return nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+import (
+	"embed"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+)
+
+// ErrUnknownProgram is returned by OpenProgram when there's no bundled
+// program with the name.
+var ErrUnknownProgram = errors.New("chip8: unknown program")
+
+// programs are the demo programs in the programs directory.
+//
+//go:embed programs/*.ch8
+var programs embed.FS
+
+// Programs returns the names of the demo programs that are bundled with the
+// package, like "pong.ch8", in alphabetical order.
+func Programs() []string {
+	entries, err := fs.ReadDir(programs, "programs")
+	if err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+// OpenProgram opens one of the bundled demo programs, by one of the names
+// returned by Programs. The caller should close it.
+func OpenProgram(name string) (io.ReadCloser, error) {
+	if !fs.ValidPath(name) || path.Base(name) != name {
+		return nil, ErrUnknownProgram
+	}
+
+	f, err := programs.Open(path.Join("programs", name))
+	if err != nil {
+		return nil, ErrUnknownProgram
+	}
+	return f, nil
+}
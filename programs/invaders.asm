@@ -0,0 +1,98 @@
+; Invaders: a fleet marches back and forth across the screen, stepping down
+; at each edge. The cannon is moved with 4 and 6 and fires with 5.
+start:
+	CLS
+	LD V0, 0
+	LD V1, 2
+	LD V2, 1
+	LD V3, 28
+	LD V4, 28
+	LD V5, 0
+	LD V6, 0
+	CALL fleet
+	LD I, cannon
+	DRW V3, V4, 3
+loop:
+	LD VC, 3
+	LD DT, VC
+wait:
+	LD VC, DT
+	SE VC, 0
+	JP wait
+	CALL fleet
+	ADD V0, V2
+	SNE V0, 0
+	CALL turn
+	SNE V0, 16
+	CALL turn
+	CALL fleet
+	LD I, cannon
+	DRW V3, V4, 3
+	LD VC, 4
+	SKNP VC
+	ADD V3, 0xFF
+	LD VC, 6
+	SKNP VC
+	ADD V3, 1
+	SNE V3, 0xFF
+	LD V3, 0
+	SNE V3, 57
+	LD V3, 56
+	DRW V3, V4, 3
+	SE V5, 0
+	JP shot
+	LD VC, 5
+	SKP VC
+	JP loop
+	LD V6, V3
+	ADD V6, 3
+	LD V5, 27
+	LD I, bullet
+	DRW V6, V5, 1
+	JP loop
+shot:
+	LD I, bullet
+	DRW V6, V5, 1
+	ADD V5, 0xFF
+	SNE V5, 0
+	JP loop
+	DRW V6, V5, 1
+	SE VF, 0
+	JP hit
+	JP loop
+hit:
+	DRW V6, V5, 1
+	LD V5, 0
+	JP loop
+turn:
+	LD VC, 0
+	SUB VC, V2
+	LD V2, VC
+	ADD V1, 2
+	SNE V1, 14
+	LD V1, 2
+	RET
+fleet:
+	LD I, invader
+	LD V8, V1
+	LD VA, 2
+row:
+	LD V7, V0
+	LD V9, 4
+col:
+	DRW V7, V8, 4
+	ADD V7, 12
+	ADD V9, 0xFF
+	SE V9, 0
+	JP col
+	ADD V8, 6
+	ADD VA, 0xFF
+	SE VA, 0
+	JP row
+	RET
+invader:
+	DB 0x3C, 0x5A, 0x7E, 0x24
+cannon:
+	DB 0x18, 0x7E, 0xFF
+bullet:
+	DB 0x80
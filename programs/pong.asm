@@ -0,0 +1,110 @@
+; Pong: the left paddle is moved with 1 and 4, the right paddle follows the
+; ball. Scores are shown at the top of the screen.
+start:
+	CLS
+	LD VA, 0
+	LD VB, 0
+	CALL scores
+serve:
+	LD V0, 13
+	LD V1, 13
+	LD V2, 32
+	RND V3, 0x0F
+	ADD V3, 8
+	LD V4, 1
+	LD V5, 1
+	LD V7, 2
+	LD V8, 61
+	LD I, paddle
+	DRW V7, V0, 6
+	DRW V8, V1, 6
+	LD I, ball
+	DRW V2, V3, 1
+loop:
+	LD V6, 2
+	LD DT, V6
+wait:
+	LD V6, DT
+	SE V6, 0
+	JP wait
+	LD I, paddle
+	DRW V7, V0, 6
+	LD V6, 1
+	SKNP V6
+	ADD V0, 0xFF
+	LD V6, 4
+	SKNP V6
+	ADD V0, 1
+	SNE V0, 0xFF
+	LD V0, 0
+	SNE V0, 27
+	LD V0, 26
+	DRW V7, V0, 6
+	DRW V8, V1, 6
+	LD V6, V1
+	ADD V6, 2
+	SUB V6, V3
+	SE VF, 0
+	ADD V1, 0xFF
+	SE VF, 1
+	ADD V1, 1
+	SNE V1, 0xFF
+	LD V1, 0
+	SNE V1, 27
+	LD V1, 26
+	DRW V8, V1, 6
+	LD I, ball
+	DRW V2, V3, 1
+	SNE V3, 0
+	LD V5, 1
+	SNE V3, 31
+	LD V5, 0xFF
+	ADD V2, V4
+	ADD V3, V5
+	SNE V2, 0
+	JP right
+	SNE V2, 63
+	JP left
+	DRW V2, V3, 1
+	SE VF, 0
+	JP hit
+	JP loop
+hit:
+	DRW V2, V3, 1
+	LD V6, 0
+	SUB V6, V4
+	LD V4, V6
+	ADD V2, V4
+	ADD V2, V4
+	DRW V2, V3, 1
+	JP loop
+right:
+	CALL scores
+	ADD VB, 1
+	SNE VB, 10
+	LD VB, 0
+	JP scored
+left:
+	CALL scores
+	ADD VA, 1
+	SNE VA, 10
+	LD VA, 0
+scored:
+	CALL scores
+	LD I, paddle
+	DRW V7, V0, 6
+	DRW V8, V1, 6
+	JP serve
+scores:
+	LD V6, 20
+	LD V9, 1
+	LD F, VA
+	DRW V6, V9, 5
+	LD V6, 40
+	LD F, VB
+	DRW V6, V9, 5
+	RET
+paddle:
+	DB 0x80, 0x80, 0x80, 0x80, 0x80, 0x80
+ball:
+	DB 0x80
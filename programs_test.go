@@ -0,0 +1,41 @@
+package chip8
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestPrograms(t *testing.T) {
+	names := Programs()
+	if len(names) == 0 {
+		t.Fatal("Expected bundled programs")
+	}
+
+	for _, name := range names {
+		f, err := OpenProgram(name)
+		if err != nil {
+			t.Fatalf("OpenProgram(%q) => error %v", name, err)
+		}
+
+		p, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(p) == 0 {
+			t.Errorf("OpenProgram(%q) => empty program", name)
+		}
+		if len(p) > DefaultMemorySize-ProgramStart {
+			t.Errorf("OpenProgram(%q) => %d bytes; too large to load", name, len(p))
+		}
+	}
+}
+
+func TestOpenProgram_Unknown(t *testing.T) {
+	for _, name := range []string{"missing.ch8", "pong.asm", "../programs/pong.ch8", ""} {
+		if _, err := OpenProgram(name); err != ErrUnknownProgram {
+			t.Errorf("OpenProgram(%q) => %v; want %v", name, err, ErrUnknownProgram)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+import "fmt"
+
+// Quirks toggles opcode behavior that differs between CHIP-8 interpreters.
+// The zero value matches the behavior this package has always had.
+type Quirks struct {
+	// ShiftUsesVy makes 8xy6 and 8xyE shift Vy and store the result in Vx,
+	// like the original COSMAC VIP interpreter. When false, Vx is shifted
+	// in place and Vy is ignored.
+	ShiftUsesVy bool
+
+	// LoadStoreIncrementsI makes Fx55 and Fx65 leave I pointing just past
+	// the last register transferred.
+	LoadStoreIncrementsI bool
+
+	// ResetVF makes the logical opcodes 8xy1, 8xy2 and 8xy3 clear VF.
+	ResetVF bool
+
+	// KeyReleaseWait makes Fx0A wait for a key to be pressed and then
+	// released before storing it, like the COSMAC VIP. When false, Fx0A
+	// returns as soon as a key is pressed. This only applies to Keypads
+	// that implement KeyState.
+	KeyReleaseWait bool
+
+	// IgnoreWrapCollisions makes Dxyn only set VF for collisions within
+	// the sprite's own bounds, and not for pixels that wrap around the
+	// edges of the screen.
+	IgnoreWrapCollisions bool
+
+	// JumpUsesVx makes Bnnn behave like SUPER-CHIP's Bxnn, which jumps to
+	// xnn plus Vx instead of nnn plus V0.
+	JumpUsesVx bool
+
+	// DrawVFUnchangedOnNoCollision makes Dxyn leave VF alone when there's
+	// no collision, instead of clearing it. Only a few programs expect
+	// this.
+	DrawVFUnchangedOnNoCollision bool
+
+	// ClipX and ClipY make Dxyn cut sprites off at the right and bottom
+	// edges of the screen, like Graphics.ClipX and Graphics.ClipY. They
+	// say which axes don't wrap, rather than which do, so that the zero
+	// value wraps on both like this package always has.
+	ClipX, ClipY bool
+}
+
+// Quirk profiles for well known interpreters.
+var (
+	// QuirksCOSMAC is the behavior of the original COSMAC VIP interpreter.
+	QuirksCOSMAC = Quirks{
+		ShiftUsesVy:          true,
+		LoadStoreIncrementsI: true,
+		ResetVF:              true,
+		KeyReleaseWait:       true,
+		ClipX:                true,
+		ClipY:                true,
+	}
+
+	// QuirksSCHIP is the behavior of SUPER-CHIP 1.1 on the HP48.
+	QuirksSCHIP = Quirks{
+		JumpUsesVx: true,
+		ClipX:      true,
+		ClipY:      true,
+	}
+
+	// QuirksXOCHIP is the behavior of XO-CHIP, as implemented by Octo.
+	QuirksXOCHIP = Quirks{
+		ShiftUsesVy:          true,
+		LoadStoreIncrementsI: true,
+	}
+)
+
+// QuirksProfile returns the Quirks for the named interpreter. Valid names
+// are "cosmac", "schip" and "xochip".
+func QuirksProfile(name string) (Quirks, error) {
+	switch name {
+	case "cosmac":
+		return QuirksCOSMAC, nil
+	case "schip":
+		return QuirksSCHIP, nil
+	case "xochip":
+		return QuirksXOCHIP, nil
+	default:
+		return Quirks{}, fmt.Errorf("chip8: unknown quirks profile: %q", name)
+	}
+}
+
+// DetectQuirks guesses which interpreter a program was written for by
+// looking for opcodes that only exist in the SUPER-CHIP and XO-CHIP
+// extensions. Programs that only use the original instruction set get the
+// zero value.
+func DetectQuirks(p []byte) Quirks {
+	var schip bool
+
+	for i := 0; i+1 < len(p); i += 2 {
+		op := uint16(p[i])<<8 | uint16(p[i+1])
+
+		switch extension(op) {
+		case extensionXOCHIP:
+			return QuirksXOCHIP
+		case extensionSCHIP:
+			schip = true
+		}
+	}
+
+	if schip {
+		return QuirksSCHIP
+	}
+
+	return Quirks{}
+}
+
+// Names of the extensions to the CHIP-8 instruction set.
+const (
+	extensionSCHIP  = "SUPER-CHIP"
+	extensionXOCHIP = "XO-CHIP"
+)
+
+// extension returns the name of the extension that op belongs to, or an
+// empty string if it's not an extension opcode.
+func extension(op uint16) string {
+	switch {
+	// 00Dn, 5xy2, 5xy3, F000, F002, Fx01, Fx3A
+	case op&0xFFF0 == 0x00D0,
+		op&0xF00F == 0x5002,
+		op&0xF00F == 0x5003,
+		op == 0xF000,
+		op == 0xF002,
+		op&0xF0FF == 0xF001,
+		op&0xF0FF == 0xF03A:
+		return extensionXOCHIP
+
+	// 00Cn, 00FB, 00FC, 00FD, 00FE, 00FF, Fx30, Fx75, Fx85
+	case op&0xFFF0 == 0x00C0,
+		op >= 0x00FB && op <= 0x00FF,
+		op&0xF0FF == 0xF030,
+		op&0xF0FF == 0xF075,
+		op&0xF0FF == 0xF085:
+		return extensionSCHIP
+	}
+
+	return ""
+}
@@ -0,0 +1,52 @@
+package chip8
+
+import "testing"
+
+func TestQuirksProfile(t *testing.T) {
+	tests := []struct {
+		name   string
+		quirks Quirks
+		err    bool
+	}{
+		{"cosmac", QuirksCOSMAC, false},
+		{"schip", QuirksSCHIP, false},
+		{"xochip", QuirksXOCHIP, false},
+		{"vip", Quirks{}, true},
+	}
+
+	for _, tt := range tests {
+		q, err := QuirksProfile(tt.name)
+		if (err != nil) != tt.err {
+			t.Errorf("QuirksProfile(%q) => error %v", tt.name, err)
+		}
+
+		if q != tt.quirks {
+			t.Errorf("QuirksProfile(%q) => %+v; want %+v", tt.name, q, tt.quirks)
+		}
+	}
+}
+
+func TestDetectQuirks(t *testing.T) {
+	tests := []struct {
+		program []byte
+		quirks  Quirks
+	}{
+		// CLS; JP 0x200
+		{[]byte{0x00, 0xE0, 0x12, 0x00}, Quirks{}},
+
+		// HIGH; EXIT
+		{[]byte{0x00, 0xFF, 0x00, 0xFD}, QuirksSCHIP},
+
+		// HIGH; SAVE V1 - V2
+		{[]byte{0x00, 0xFF, 0x51, 0x22}, QuirksXOCHIP},
+
+		// LD I, long 0x1234
+		{[]byte{0xF0, 0x00, 0x12, 0x34}, QuirksXOCHIP},
+	}
+
+	for _, tt := range tests {
+		if q := DetectQuirks(tt.program); q != tt.quirks {
+			t.Errorf("DetectQuirks(% X) => %+v; want %+v", tt.program, q, tt.quirks)
+		}
+	}
+}
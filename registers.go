@@ -0,0 +1,98 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+import "errors"
+
+// ErrInvalidRegister is returned when a register index isn't between 0x0 and
+// 0xF.
+var ErrInvalidRegister = errors.New("chip8: invalid register")
+
+// The methods in this file read and write registers between instructions,
+// so unlike the fields themselves, they're safe to call from other
+// goroutines while the CPU is running, however it's run. The registers are
+// unlocked while the CPU calls the Display, the Keypad and OnFrame, so
+// those can use these methods too, and they don't block while Fx0A waits
+// for a key. The other callbacks, like MemWriteTracer, are called with the
+// registers locked, and deadlock if they use them.
+
+// GetRegister returns the value of register Vi.
+func (c *CPU) GetRegister(i int) (byte, error) {
+	if i < 0 || i >= len(c.V) {
+		return 0, ErrInvalidRegister
+	}
+
+	c.regMu.Lock()
+	defer c.regMu.Unlock()
+	return c.V[i], nil
+}
+
+// SetRegister sets register Vi to v.
+func (c *CPU) SetRegister(i int, v byte) error {
+	if i < 0 || i >= len(c.V) {
+		return ErrInvalidRegister
+	}
+
+	c.regMu.Lock()
+	defer c.regMu.Unlock()
+	c.V[i] = v
+	return nil
+}
+
+// GetI returns the value of the I register.
+func (c *CPU) GetI() uint16 {
+	c.regMu.Lock()
+	defer c.regMu.Unlock()
+	return c.I
+}
+
+// SetI sets the I register.
+func (c *CPU) SetI(v uint16) {
+	c.regMu.Lock()
+	defer c.regMu.Unlock()
+	c.I = v
+}
+
+// GetPC returns the program counter.
+func (c *CPU) GetPC() uint16 {
+	c.regMu.Lock()
+	defer c.regMu.Unlock()
+	return c.PC
+}
+
+// SetPC sets the program counter, so the next instruction is read from v.
+func (c *CPU) SetPC(v uint16) {
+	c.regMu.Lock()
+	defer c.regMu.Unlock()
+	c.PC = v
+}
+
+// GetDT returns the value of the delay timer.
+func (c *CPU) GetDT() byte {
+	c.regMu.Lock()
+	defer c.regMu.Unlock()
+	return c.DT
+}
+
+// SetDT sets the delay timer.
+func (c *CPU) SetDT(v byte) {
+	c.regMu.Lock()
+	defer c.regMu.Unlock()
+	c.DT = v
+}
+
+// GetST returns the value of the sound timer.
+func (c *CPU) GetST() byte {
+	c.regMu.Lock()
+	defer c.regMu.Unlock()
+	return c.ST
+}
+
+// SetST sets the sound timer.
+func (c *CPU) SetST(v byte) {
+	c.regMu.Lock()
+	defer c.regMu.Unlock()
+	c.ST = v
+}
@@ -0,0 +1,160 @@
+package chip8
+
+import "testing"
+
+func TestCPU_Register(t *testing.T) {
+	c := newCPU(t)
+
+	for i := 0; i < 16; i++ {
+		if err := c.SetRegister(i, byte(i*2)); err != nil {
+			t.Fatalf("SetRegister(%d) => %v", i, err)
+		}
+	}
+
+	for i := 0; i < 16; i++ {
+		v, err := c.GetRegister(i)
+		if err != nil {
+			t.Fatalf("GetRegister(%d) => %v", i, err)
+		}
+		checkHex(t, "V", v, byte(i*2))
+		checkHex(t, "V", c.V[i], byte(i*2))
+	}
+
+	for _, i := range []int{-1, 16} {
+		if err := c.SetRegister(i, 0xFF); err != ErrInvalidRegister {
+			t.Errorf("SetRegister(%d) => %v; want %v", i, err, ErrInvalidRegister)
+		}
+		if _, err := c.GetRegister(i); err != ErrInvalidRegister {
+			t.Errorf("GetRegister(%d) => %v; want %v", i, err, ErrInvalidRegister)
+		}
+	}
+
+	c.SetI(0x300)
+	c.SetPC(0x202)
+	c.SetDT(0x10)
+	c.SetST(0x20)
+
+	checkHex(t, "I", c.GetI(), 0x300)
+	checkHex(t, "PC", c.GetPC(), 0x202)
+	checkHex(t, "DT", c.GetDT(), 0x10)
+	checkHex(t, "ST", c.GetST(), 0x20)
+}
+
+func TestCPU_Register_Running(t *testing.T) {
+	c := newHeadlessCPU(t, []byte{
+		0x70, 0x01, // ADD V0, 0x01
+		0x12, 0x00, // JP 0x200
+	}, NullDisplay)
+
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- c.run(stop)
+	}()
+
+	// Safe to do while the CPU is running, which the race detector
+	// checks.
+	for i := 0; i < 100; i++ {
+		if err := c.SetRegister(0x1, byte(i)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.GetRegister(0x0); err != nil {
+			t.Fatal(err)
+		}
+		c.GetPC()
+	}
+
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	checkHex(t, "V1", c.V[1], 99)
+}
+
+func TestCPU_Register_Callbacks(t *testing.T) {
+	c := newHeadlessCPU(t, []byte{
+		0xD0, 0x01, // 0x200: DRW V0, V0, 1
+		0xF1, 0x0A, // 0x202: LD V1, K
+		0x00, 0xFD, // 0x204: EXIT
+	}, NullDisplay)
+
+	// The Display, the Keypad and OnFrame are called with the registers
+	// unlocked, so using the accessors from them doesn't deadlock.
+	var renders, frames int
+	c.Graphics.Display = DisplayFunc(func(*Graphics) error {
+		renders++
+		checkHex(t, "PC while rendering", c.GetPC(), 0x202)
+		return nil
+	})
+	c.OnFrame = func(uint64, *Graphics) {
+		frames++
+		c.GetPC()
+	}
+	c.Keypad = KeypadFunc(func() (byte, error) {
+		if err := c.SetRegister(0x2, 0x22); err != nil {
+			t.Fatal(err)
+		}
+		return 0x7, nil
+	})
+
+	if err := c.RunSteps(3); err != nil {
+		t.Fatal(err)
+	}
+
+	if renders != 1 || frames != 2 {
+		t.Errorf("renders, frames => %d, %d; want 1, 2", renders, frames)
+	}
+	checkHex(t, "V1", c.V[1], 0x7)
+	checkHex(t, "V2", c.V[2], 0x22)
+}
+
+func TestCPU_Register_RunUntil(t *testing.T) {
+	c := newHeadlessCPU(t, []byte{
+		0x70, 0x01, // ADD V0, 0x01
+		0x12, 0x00, // JP 0x200
+	}, NullDisplay)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.RunUntil(func(c *CPU) bool {
+			return c.Cycles == 1000
+		}, 1000)
+	}()
+
+	// RunUntil locks the registers like Run does, which the race detector
+	// checks.
+	for i := 0; i < 100; i++ {
+		c.SetRegister(0x1, byte(i))
+		c.GetPC()
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCPU_TickTimers_Running(t *testing.T) {
+	c := newHeadlessCPU(t, []byte{
+		0xF0, 0x15, // LD DT, V0
+		0x12, 0x00, // JP 0x200
+	}, NullDisplay)
+
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- c.run(stop)
+	}()
+
+	// Safe to do while the CPU is running, which the race detector
+	// checks.
+	for i := 0; i < 100; i++ {
+		c.TickTimers()
+		c.GetDT()
+	}
+
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
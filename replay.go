@@ -0,0 +1,146 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Recording is a record of a run of a program: the seed for the random
+// numbers, the keys that were read from the Keypad, and hashes of the
+// graphics array at regular checkpoints. Replaying it with the same program
+// should produce the same frames.
+type Recording struct {
+	Seed        int64
+	Keys        []byte
+	Checkpoints []Checkpoint
+}
+
+// Checkpoint is the hash of the graphics array after a number of cycles.
+type Checkpoint struct {
+	// The number of instructions executed since the recording started.
+	Cycle uint64
+
+	// The Graphics.Hash at that cycle.
+	Hash string
+}
+
+// Divergence is returned by Replay when a frame doesn't match the
+// recording.
+type Divergence struct {
+	Checkpoint
+
+	// The hash of the graphics array that was produced instead.
+	Got string
+}
+
+// Error implements the error interface.
+func (e *Divergence) Error() string {
+	return fmt.Sprintf("chip8: replay diverged at cycle %d: got frame %s, want %s", e.Cycle, e.Got, e.Hash)
+}
+
+// RecordingKeypad is a Keypad that records the keys read from another
+// Keypad.
+type RecordingKeypad struct {
+	Keypad Keypad
+
+	// The keys that have been read, in order.
+	Keys []byte
+}
+
+// ReadByte reads a key from the underlying Keypad and records it.
+func (k *RecordingKeypad) ReadByte() (byte, error) {
+	b, err := k.Keypad.ReadByte()
+	if err == nil {
+		k.Keys = append(k.Keys, b)
+	}
+	return b, err
+}
+
+// Record executes n instructions with a random number generator seeded with
+// seed, recording the keys read from the Keypad and a checkpoint every
+// interval instructions. When n isn't a multiple of interval, the
+// instructions after the last checkpoint are still executed. Keypads that
+// implement KeyState are polled rather than read, so they can't be recorded.
+// The Keypad and random number generator are put back afterwards.
+func (c *CPU) Record(n, interval int, seed int64) (*Recording, error) {
+	defer c.restoreInputs(c.Keypad, c.Rand)
+
+	k := &RecordingKeypad{Keypad: c.keypad()}
+	c.Keypad = k
+	c.Rand = rand.New(rand.NewSource(seed))
+
+	r := &Recording{Seed: seed}
+	err := c.checkpoints(n, interval, func(cp Checkpoint) error {
+		r.Checkpoints = append(r.Checkpoints, cp)
+		return nil
+	})
+	r.Keys = k.Keys
+
+	return r, err
+}
+
+// Replay replays a Recording, feeding the recorded keys to the program and
+// seeding the random number generator the same way. The program should be
+// loaded, and the CPU in the same state it was in when the recording
+// started. It returns a *Divergence at the first checkpoint whose frame
+// doesn't match. Like Record, it puts the Keypad and random number generator
+// back afterwards.
+func (c *CPU) Replay(r *Recording) error {
+	if len(r.Checkpoints) == 0 {
+		return nil
+	}
+
+	defer c.restoreInputs(c.Keypad, c.Rand)
+
+	c.Keypad = &ScriptedKeypad{Keys: r.Keys}
+	c.Rand = rand.New(rand.NewSource(r.Seed))
+
+	// The checkpoints are evenly spaced.
+	last := r.Checkpoints[len(r.Checkpoints)-1]
+	interval := int(r.Checkpoints[0].Cycle)
+
+	i := 0
+	return c.checkpoints(int(last.Cycle), interval, func(cp Checkpoint) error {
+		want := r.Checkpoints[i]
+		i++
+
+		if cp != want {
+			return &Divergence{Checkpoint: want, Got: cp.Hash}
+		}
+
+		return nil
+	})
+}
+
+// restoreInputs puts back the Keypad and random number generator that Record
+// and Replay replace.
+func (c *CPU) restoreInputs(k Keypad, r *rand.Rand) {
+	c.Keypad = k
+	c.Rand = r
+}
+
+// checkpoints executes n instructions, calling fn with a checkpoint every
+// interval instructions. Any instructions after the last checkpoint are
+// executed without one.
+func (c *CPU) checkpoints(n, interval int, fn func(Checkpoint) error) error {
+	if interval <= 0 {
+		return fmt.Errorf("chip8: invalid checkpoint interval: %d", interval)
+	}
+
+	cycle := interval
+	for ; cycle <= n; cycle += interval {
+		if err := c.RunSteps(interval); err != nil {
+			return err
+		}
+
+		if err := fn(Checkpoint{Cycle: uint64(cycle), Hash: c.Graphics.Hash()}); err != nil {
+			return err
+		}
+	}
+
+	return c.RunSteps(n - (cycle - interval))
+}
@@ -0,0 +1,83 @@
+package chip8
+
+import "testing"
+
+func TestCPU_Replay(t *testing.T) {
+	// Draws digits at random positions, which depend on the ShiftUsesVy
+	// quirk, and reads the keypad each time.
+	p := []byte{
+		0x61, 0x08, // LD V1, 0x08
+		0xC0, 0x3F, // RND V0, 0x3F
+		0x82, 0x00, // LD V2, V0
+		0x82, 0x16, // SHR V2, V1
+		0xA0, 0x00, // LD I, 0x000
+		0xD2, 0x35, // DRW V2, V3, 5
+		0xE4, 0x9E, // SKP V4
+		0x73, 0x01, // ADD V3, 0x01
+		0x12, 0x02, // JP 0x202
+	}
+
+	record := newHeadlessCPU(t, p, new(MemoryDisplay))
+	keypad, rnd := record.Keypad, record.Rand
+
+	r, err := record.Record(1000, 100, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if record.Keypad != keypad || record.Rand != rnd {
+		t.Error("Expected Record to put back the Keypad and Rand")
+	}
+
+	if len(r.Checkpoints) != 10 {
+		t.Fatalf("%d checkpoints; want 10", len(r.Checkpoints))
+	}
+	if len(r.Keys) == 0 {
+		t.Fatal("Expected keys to be recorded")
+	}
+
+	// A faithful replay matches.
+	c := newHeadlessCPU(t, p, new(MemoryDisplay))
+	keypad, rnd = c.Keypad, c.Rand
+	if err := c.Replay(r); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Keypad != keypad || c.Rand != rnd {
+		t.Error("Expected Replay to put back the Keypad and Rand")
+	}
+
+	// A change in behavior is caught at the first checkpoint.
+	c = newHeadlessCPU(t, p, new(MemoryDisplay))
+	c.Quirks.ShiftUsesVy = true
+
+	err = c.Replay(r)
+	d, ok := err.(*Divergence)
+	if !ok {
+		t.Fatalf("err => %v; want a *Divergence", err)
+	}
+	checkHex(t, "Cycle", d.Cycle, 100)
+}
+
+func TestCPU_Record_Remainder(t *testing.T) {
+	p := []byte{
+		0x70, 0x01, // ADD V0, 0x01
+		0x12, 0x00, // JP 0x200
+	}
+
+	c := newHeadlessCPU(t, p, new(MemoryDisplay))
+
+	r, err := c.Record(10, 3, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.Checkpoints) != 3 {
+		t.Fatalf("%d checkpoints; want 3", len(r.Checkpoints))
+	}
+
+	// The instruction after the last checkpoint is still executed.
+	if c.Cycles != 10 {
+		t.Errorf("Cycles => %d; want 10", c.Cycles)
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+// Scroll moves every pixel in the graphics array dx pixels to the right and
+// dy pixels down, at the current resolution. Negative values move them left
+// and up. Pixels that are moved off an edge are lost rather than wrapping
+// around, and the pixels they leave behind are turned off.
+//
+// The SUPER-CHIP scroll instructions move the graphics array by the same
+// number of pixels in both resolutions, like Octo does, rather than by half
+// as many in low resolution mode, like SUPER-CHIP 1.1 did.
+func (g *Graphics) Scroll(dx, dy int) {
+	w, h := g.Width(), g.Height()
+	src := g.Pixels
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sx, sy := x-dx, y-dy
+
+			var v byte
+			if sx >= 0 && sx < w && sy >= 0 && sy < h {
+				v = src[sy*w+sx]
+			}
+			g.Pixels[y*w+x] = v
+		}
+	}
+}
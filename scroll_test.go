@@ -0,0 +1,124 @@
+package chip8
+
+import "testing"
+
+// scrollPattern draws the digits 0 to 3 in the corners of the graphics
+// array, so they're cut off by a scroll in any direction.
+func scrollPattern(t *testing.T, hiRes bool) *CPU {
+	c := newCPU(t)
+	c.Graphics.Display = NullDisplay
+	c.SetHiRes(hiRes)
+
+	w, h := byte(c.Width()), byte(c.Height())
+	for i, pos := range [][2]byte{{0, 0}, {w - 4, 0}, {0, h - 5}, {w - 4, h - 5}} {
+		c.I = uint16(i * 5)
+		c.V[0], c.V[1] = pos[0], pos[1]
+		if err := c.Dispatch(0xD015); err != nil { // DRW V0, V1, 5
+			t.Fatal(err)
+		}
+	}
+
+	return c
+}
+
+func TestCPU_Scroll_Golden(t *testing.T) {
+	tests := []struct {
+		name  string
+		hiRes bool
+		ops   []uint16
+		hash  string
+	}{
+		{"lo-res", false, nil,
+			"27189dc04cd5da9313e7916067ea596f2ed03d8900e3b9eeba66c51c6f62005b"},
+		{"lo-res SCD 1", false, []uint16{0x00C1},
+			"3ff935864b1a53e4c74c2a543695a5316d69cf455ef20a4dde94ecb3061a4ff2"},
+		{"lo-res SCD 15", false, []uint16{0x00CF},
+			"b79a65fc96546c616fb80e201491387b7b35a76ac134f2fd8e48f12858058fb9"},
+		{"lo-res SCR", false, []uint16{0x00FB},
+			"e4c35eecc55ff1a21753f5b3021686230270fcde86684f0ea7ce7ccde0bed2aa"},
+		{"lo-res SCL", false, []uint16{0x00FC},
+			"d7ec686f05bc2a3f45e67fbcd03bb3f2666fccb29e2e59f5f661f774cde6e2fa"},
+		// Pixels scrolled off an edge are lost, so scrolling back
+		// doesn't bring them back.
+		{"lo-res SCR SCL", false, []uint16{0x00FB, 0x00FC},
+			"ec8186fe9c838189ee3a552b33a4c640446a61a626a2df6e2e25e48ded19a4ac"},
+		{"hi-res", true, nil,
+			"78b48a10136d18284dffc3b63f027966729422c88ace37484d703acd70f071d0"},
+		{"hi-res SCD 1", true, []uint16{0x00C1},
+			"91ee41e1e89dce9b7d4205947dcb4e14f8827b704f3bc7910cd526a57aa0196e"},
+		{"hi-res SCD 15", true, []uint16{0x00CF},
+			"b5cd5afcc915ac2e87df354c20b393e400febf6bd230c113e75768bb41e27d6f"},
+		{"hi-res SCR", true, []uint16{0x00FB},
+			"30ed1f777408ba4e7c27bd4d0777a3f65618fbefb9e9d9761d123c2febb24a13"},
+		{"hi-res SCL", true, []uint16{0x00FC},
+			"681a03e9fec16efbfa934a7f51cb9782e0ca8f872d7ea7b28a900f0607f861af"},
+		// Pixels scrolled off an edge are lost, so scrolling back
+		// doesn't bring them back.
+		{"hi-res SCR SCL", true, []uint16{0x00FB, 0x00FC},
+			"79306f88729c9a93fcb619e40552ca7339eda458feeff6dfc345606ab057112a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := scrollPattern(t, tt.hiRes)
+
+			for _, op := range tt.ops {
+				if err := c.Dispatch(op); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			checkGraphics(t, &c.Graphics, tt.hash)
+		})
+	}
+}
+
+func TestGraphics_Scroll(t *testing.T) {
+	tests := []struct {
+		hiRes  bool
+		x, y   uint16
+		dx, dy int
+
+		// Where the pixel ends up, if it's still on the screen.
+		want     [2]uint16
+		onScreen bool
+	}{
+		{false, 10, 10, 4, 0, [2]uint16{14, 10}, true},
+		{false, 10, 10, -4, 0, [2]uint16{6, 10}, true},
+		{false, 10, 10, 0, 3, [2]uint16{10, 13}, true},
+
+		// Pixels at the edges don't wrap around.
+		{false, 63, 0, 4, 0, [2]uint16{}, false},
+		{false, 0, 0, -4, 0, [2]uint16{}, false},
+		{false, 0, 31, 0, 1, [2]uint16{}, false},
+		{true, 127, 0, 4, 0, [2]uint16{}, false},
+		{true, 0, 0, -4, 0, [2]uint16{}, false},
+		{true, 0, 63, 0, 1, [2]uint16{}, false},
+
+		// The edges of the low resolution array aren't the edges of
+		// the high resolution one.
+		{true, 63, 31, 4, 1, [2]uint16{67, 32}, true},
+	}
+
+	for _, tt := range tests {
+		g := new(Graphics)
+		g.SetHiRes(tt.hiRes)
+		g.Set(tt.x, tt.y, true)
+
+		g.Scroll(tt.dx, tt.dy)
+
+		var lit [][2]uint16
+		g.EachPixel(func(x, y uint16, addr int) {
+			if g.Pixels[addr] != 0 {
+				lit = append(lit, [2]uint16{x, y})
+			}
+		})
+
+		switch {
+		case !tt.onScreen && len(lit) != 0:
+			t.Errorf("Scroll(%d, %d) of %d, %d => %v; want it scrolled off", tt.dx, tt.dy, tt.x, tt.y, lit)
+		case tt.onScreen && (len(lit) != 1 || lit[0] != tt.want):
+			t.Errorf("Scroll(%d, %d) of %d, %d => %v; want %v", tt.dx, tt.dy, tt.x, tt.y, lit, tt.want)
+		}
+	}
+}
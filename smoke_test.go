@@ -0,0 +1,62 @@
+package chip8
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the expected hashes in testdata")
+
+// TestSmoke_Pong plays pong end to end, with scripted input, a seeded random
+// number generator and a clock that only moves when it's told to, and
+// compares the final frame to the hash in testdata/pong.hash. When a change
+// to the CPU changes the frame on purpose, regenerate the hash with:
+//
+//	go test -run TestSmoke_Pong -update
+func TestSmoke_Pong(t *testing.T) {
+	const cycles = 20000
+
+	keys := []byte{0x01, 0x01, 0x04, 0x01, 0x04, 0x04, 0x00, 0x01, 0x04}
+
+	play := func() string {
+		c := NewTestCPU(
+			WithProgram(loadProgram(t, "pong.ch8")),
+			WithKeys(keys...),
+			WithSeed(1980),
+		)
+
+		if err := c.RunSteps(cycles); err != nil {
+			t.Fatal(err)
+		}
+		if c.Cycles != cycles {
+			t.Fatalf("Ran %d cycles; want %d", c.Cycles, cycles)
+		}
+
+		return c.Graphics.Hash()
+	}
+
+	hash := play()
+
+	// The run is deterministic.
+	if again := play(); again != hash {
+		t.Fatalf("Second run => %s; want %s", again, hash)
+	}
+
+	fname := filepath.Join("testdata", "pong.hash")
+	if *update {
+		if err := ioutil.WriteFile(fname, []byte(hash+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := strings.TrimSpace(string(want)); hash != w {
+		t.Errorf("Final frame => %s; want %s from %s", hash, w, fname)
+	}
+}
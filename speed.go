@@ -0,0 +1,61 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+import (
+	"math"
+	"time"
+)
+
+// The bounds that SetSpeedMultiplier clamps to.
+const (
+	MinSpeedMultiplier = 0.1
+	MaxSpeedMultiplier = 16
+)
+
+// SetSpeedMultiplier scales the number of instructions executed per second
+// relative to the ClockSpeed, for fast forwarding or slow motion, so m = 2
+// runs the program twice as fast and m = 0.5 half as fast. The timers still
+// count down at 60 Hz. It's safe to call while the CPU is running.
+//
+// m is clamped to between MinSpeedMultiplier and MaxSpeedMultiplier.
+func (c *CPU) SetSpeedMultiplier(m float64) {
+	if math.IsNaN(m) {
+		m = 1
+	}
+	m = math.Max(MinSpeedMultiplier, math.Min(MaxSpeedMultiplier, m))
+
+	c.speedMu.Lock()
+	defer c.speedMu.Unlock()
+	c.speedMultiplier = m
+}
+
+// SpeedMultiplier returns the multiplier set by SetSpeedMultiplier, which is
+// 1 by default.
+func (c *CPU) SpeedMultiplier() float64 {
+	c.speedMu.Lock()
+	defer c.speedMu.Unlock()
+
+	if c.speedMultiplier == 0 {
+		return 1
+	}
+	return c.speedMultiplier
+}
+
+// speed returns the number of instructions executed per second, with the
+// speed multiplier applied.
+func (c *CPU) speed() time.Duration {
+	m := c.SpeedMultiplier()
+	if m == 1 || c.clockSpeed <= 0 {
+		return c.clockSpeed
+	}
+
+	// Don't let slow motion stop the CPU entirely.
+	hz := time.Duration(math.Round(float64(c.clockSpeed) * m))
+	if hz < 1 {
+		hz = 1
+	}
+	return hz
+}
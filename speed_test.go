@@ -0,0 +1,89 @@
+package chip8
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCPU_SetSpeedMultiplier(t *testing.T) {
+	tests := []struct {
+		m      float64
+		cycles uint64
+	}{
+		{1, 600},
+		{2, 1200},
+		{0.5, 300},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%gx", tt.m), func(t *testing.T) {
+			ts := NewManualTimeSource(time.Unix(0, 0))
+
+			c, err := NewCPU(&Options{
+				ClockSpeed: 600,
+				TimeSource: ts,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.LoadBytes([]byte{
+				0x12, 0x00, // JP 0x200
+			})
+			c.DT = 0xFF
+			c.ST = 0xFF
+			c.SetSpeedMultiplier(tt.m)
+
+			done := make(chan error)
+			go func() {
+				done <- c.Run()
+			}()
+
+			// Simulate a second.
+			ts.Advance(0)
+			for i := 0; i < 10; i++ {
+				ts.Advance(100 * time.Millisecond)
+			}
+
+			c.Stop()
+			if err := <-done; err != nil {
+				t.Fatal(err)
+			}
+
+			if c.Cycles != tt.cycles {
+				t.Errorf("Cycles => %d; want %d", c.Cycles, tt.cycles)
+			}
+
+			// The timers don't care how fast the program runs.
+			checkHex(t, "DT", c.DT, 0xFF-60)
+			checkHex(t, "ST", c.ST, 0xFF-60)
+		})
+	}
+}
+
+func TestCPU_SetSpeedMultiplier_Clamp(t *testing.T) {
+	tests := []struct {
+		m    float64
+		want float64
+	}{
+		{1.5, 1.5},
+		{0, MinSpeedMultiplier},
+		{-1, MinSpeedMultiplier},
+		{100, MaxSpeedMultiplier},
+		{math.Inf(1), MaxSpeedMultiplier},
+		{math.NaN(), 1},
+	}
+
+	c := newCPU(t)
+	if got := c.SpeedMultiplier(); got != 1 {
+		t.Errorf("SpeedMultiplier() => %g; want 1", got)
+	}
+
+	for _, tt := range tests {
+		c.SetSpeedMultiplier(tt.m)
+		if got := c.SpeedMultiplier(); got != tt.want {
+			t.Errorf("SetSpeedMultiplier(%g) => %g; want %g", tt.m, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+// spriteRow is a decoded row of sprite data, from left to right.
+type spriteRow [8]bool
+
+// decodeSpriteRow decodes a byte of sprite data.
+func decodeSpriteRow(r byte) (row spriteRow) {
+	for xl := range row {
+		row[xl] = r&(0x80>>uint(xl)) != 0
+	}
+	return
+}
+
+// spriteKey identifies a sprite by its address and height.
+type spriteKey struct {
+	addr int
+	n    int
+}
+
+// spriteCache memoizes the decoded rows of the sprites drawn by Dxyn.
+// Entries are invalidated when memory they were decoded from is written to,
+// so it relies on all writes going through writeByte.
+type spriteCache struct {
+	sprites map[spriteKey][]spriteRow
+
+	// the range of memory covered by the cached sprites, so writes
+	// elsewhere can skip looking for entries to invalidate.
+	lo, hi int
+}
+
+// get returns the decoded rows of the n byte sprite at addr in memory,
+// decoding and caching it if necessary.
+func (s *spriteCache) get(memory []byte, addr, n int) []spriteRow {
+	k := spriteKey{addr, n}
+	if rows, ok := s.sprites[k]; ok {
+		return rows
+	}
+
+	if s.sprites == nil {
+		s.sprites = make(map[spriteKey][]spriteRow)
+		s.lo, s.hi = addr, addr+n
+	}
+
+	rows := make([]spriteRow, n)
+	for i := range rows {
+		rows[i] = decodeSpriteRow(memory[addr+i])
+	}
+	s.sprites[k] = rows
+
+	if addr < s.lo {
+		s.lo = addr
+	}
+	if addr+n > s.hi {
+		s.hi = addr + n
+	}
+
+	return rows
+}
+
+// invalidate drops any sprites that were decoded from addr.
+func (s *spriteCache) invalidate(addr int) {
+	if s.sprites == nil || addr < s.lo || addr >= s.hi {
+		return
+	}
+
+	for k := range s.sprites {
+		if addr >= k.addr && addr < k.addr+k.n {
+			delete(s.sprites, k)
+		}
+	}
+}
+
+// SpriteAt returns a copy of the n bytes of memory at i, which is the sprite
+// that Dxyn would draw with I = i, for previewing it in a debugger. It
+// returns nil if the sprite doesn't fit in memory.
+func (c *CPU) SpriteAt(i uint16, n int) []byte {
+	// Memory is read directly, so previews don't show up in a
+	// MemReadTracer.
+	if n < 0 || int(i)+n > len(c.Memory) {
+		return nil
+	}
+
+	return append([]byte(nil), c.Memory[int(i):int(i)+n]...)
+}
+
+// SpriteArt draws a sprite as text, one row per byte, with '#' for the
+// pixels that are set and '.' for the pixels that aren't.
+func SpriteArt(sprite []byte) []string {
+	rows := make([]string, len(sprite))
+	for i, b := range sprite {
+		rows[i] = spriteArt(b)
+	}
+	return rows
+}
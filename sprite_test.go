@@ -0,0 +1,43 @@
+package chip8
+
+import "testing"
+
+func TestCPU_SpriteAt(t *testing.T) {
+	c := newCPU(t)
+
+	// The font is loaded at 0x000, so I = 0 points at the 0 glyph.
+	sprite := c.SpriteAt(0x000, 5)
+
+	want := []byte{0xF0, 0x90, 0x90, 0x90, 0xF0}
+	if len(sprite) != len(want) {
+		t.Fatalf("SpriteAt(0x000, 5) => % X; want % X", sprite, want)
+	}
+	for i := range want {
+		checkHex(t, "sprite", sprite[i], want[i])
+	}
+
+	// It's a copy.
+	sprite[0] = 0x00
+	checkHex(t, "Memory[0x000]", c.Memory[0x000], 0xF0)
+
+	art := SpriteArt(want)
+	for i, row := range []string{
+		"####....",
+		"#..#....",
+		"#..#....",
+		"#..#....",
+		"####....",
+	} {
+		if art[i] != row {
+			t.Errorf("row %d => %q; want %q", i, art[i], row)
+		}
+	}
+
+	// Sprites that run off the end of memory are out of bounds.
+	if sprite := c.SpriteAt(0xFFE, 5); sprite != nil {
+		t.Errorf("SpriteAt(0xFFE, 5) => % X; want nil", sprite)
+	}
+	if sprite := c.SpriteAt(0xFFE, -1); sprite != nil {
+		t.Errorf("SpriteAt(0xFFE, -1) => % X; want nil", sprite)
+	}
+}
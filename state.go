@@ -0,0 +1,216 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// ErrInvalidState is returned when restoring a save state that's truncated
+// or wasn't written by MarshalBinary.
+var ErrInvalidState = errors.New("chip8: invalid save state")
+
+// ErrStateVersion is returned when restoring a save state that was written
+// with a different version of the format.
+var ErrStateVersion = errors.New("chip8: unsupported save state version")
+
+// StateVersion is the version of the save state format written by
+// MarshalBinary. It's incremented whenever the format changes, and save
+// states with any other version are rejected with ErrStateVersion.
+const StateVersion = 5
+
+// stateMagic identifies a save state.
+var stateMagic = [4]byte{'C', 'H', '8', 'S'}
+
+// stateHeader is the fixed size start of a save state. It's followed by the
+// stack, memory and pixels. With more than one memory bank, every bank is
+// saved, in order, in place of the memory.
+type stateHeader struct {
+	Magic   [4]byte
+	Version uint8
+
+	V      [16]byte
+	I      uint16
+	PC     uint16
+	SP     byte
+	DT     byte
+	ST     byte
+	Cycles uint64
+	Beeps  uint64
+
+	// How far the timers are into the current 60 Hz frame.
+	FrameAcc int64
+
+	// The state of the instruction being executed, and of the keys
+	// within the frame.
+	Drawn         bool
+	KeyLatched    bool
+	KeyLatch      uint16
+	KeyWaitKey    byte
+	KeyWaitActive bool
+	KeyWaitPolled bool
+	KeyWaitHeld   uint16
+
+	HiRes      bool
+	Frames     uint64
+	Collisions uint64
+
+	StackSize  uint16
+	MemorySize uint32
+
+	// The number of memory banks, which is 0 without Options.Banks, and
+	// the active one.
+	Banks uint16
+	Bank  uint16
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface. It saves
+// the registers, timers, stack, memory and pixels, along with where the
+// timers are within the current frame, the keys latched for the frame and
+// the resolution, so a program restored with UnmarshalBinary carries on
+// exactly where it left off, even in the middle of a frame.
+//
+// The Keypad, Display and other peripherals, and the options that the CPU
+// was created with, aren't saved. With Options.Banks, every memory bank is
+// saved, along with which one is active.
+func (c *CPU) MarshalBinary() ([]byte, error) {
+	h := stateHeader{
+		Magic:         stateMagic,
+		Version:       StateVersion,
+		V:             c.V,
+		I:             c.I,
+		PC:            c.PC,
+		SP:            c.SP,
+		DT:            c.DT,
+		ST:            c.ST,
+		Cycles:        c.Cycles,
+		Beeps:         c.Beeps,
+		FrameAcc:      c.frameAcc,
+		Drawn:         c.drawn,
+		KeyLatched:    c.keyLatch.valid,
+		KeyLatch:      c.keyLatch.pressed,
+		KeyWaitKey:    c.keyWait.key,
+		KeyWaitActive: c.keyWait.pressed,
+		KeyWaitPolled: c.keyWait.polled,
+		KeyWaitHeld:   c.keyWait.held,
+		HiRes:         c.HiRes,
+		Frames:        c.Frames,
+		Collisions:    c.Collisions,
+		StackSize:     uint16(len(c.Stack)),
+		MemorySize:    uint32(len(c.Memory)),
+		Banks:         uint16(len(c.banks)),
+		Bank:          uint16(c.bank),
+	}
+
+	memory := [][]byte{c.Memory}
+	if c.banks != nil {
+		memory = c.banks
+	}
+
+	values := []interface{}{h, c.Stack}
+	for _, m := range memory {
+		values = append(values, m)
+	}
+	values = append(values, c.Pixels)
+
+	var b bytes.Buffer
+	for _, v := range values {
+		if err := binary.Write(&b, binary.BigEndian, v); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.Bytes(), nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface. It
+// restores a save state written by MarshalBinary, replacing the memory, the
+// memory banks and the stack with the saved ones.
+func (c *CPU) UnmarshalBinary(data []byte) error {
+	// Check the version before anything else, since the rest of the
+	// format depends on it.
+	if len(data) < len(stateMagic)+1 || !bytes.Equal(data[:len(stateMagic)], stateMagic[:]) {
+		return ErrInvalidState
+	}
+
+	if data[len(stateMagic)] != StateVersion {
+		return ErrStateVersion
+	}
+
+	r := bytes.NewReader(data)
+
+	var h stateHeader
+	if err := binary.Read(r, binary.BigEndian, &h); err != nil {
+		return ErrInvalidState
+	}
+
+	if int(h.SP) > int(h.StackSize) || int(h.MemorySize) > 0x10000 {
+		return ErrInvalidState
+	}
+
+	if h.Banks == 1 || h.Banks > MaxBanks || (h.Bank > 0 && h.Bank >= h.Banks) {
+		return ErrInvalidState
+	}
+
+	banks := make([][]byte, h.Banks)
+	if h.Banks == 0 {
+		banks = make([][]byte, 1)
+	}
+	for i := range banks {
+		banks[i] = make([]byte, h.MemorySize)
+	}
+
+	var (
+		stack  = make([]uint16, h.StackSize)
+		pixels [HiResWidth * HiResHeight]byte
+	)
+
+	values := []interface{}{stack}
+	for _, m := range banks {
+		values = append(values, m)
+	}
+	values = append(values, &pixels)
+
+	for _, v := range values {
+		if err := binary.Read(r, binary.BigEndian, v); err != nil {
+			return ErrInvalidState
+		}
+	}
+
+	if r.Len() != 0 {
+		return ErrInvalidState
+	}
+
+	c.V = h.V
+	c.I = h.I
+	c.PC = h.PC
+	c.SP = h.SP
+	c.DT = h.DT
+	c.ST = h.ST
+	c.Cycles = h.Cycles
+	c.Beeps = h.Beeps
+	c.frameAcc = h.FrameAcc
+	c.drawn = h.Drawn
+	c.keyLatch = keyLatch{valid: h.KeyLatched, pressed: h.KeyLatch}
+	c.keyWait = keyWait{pressed: h.KeyWaitActive, key: h.KeyWaitKey, polled: h.KeyWaitPolled, held: h.KeyWaitHeld}
+	c.Stack = stack
+	c.Memory = banks[h.Bank]
+	c.bank = int(h.Bank)
+	c.banks = nil
+	if h.Banks > 0 {
+		c.banks = banks
+	}
+	c.HiRes = h.HiRes
+	c.Frames = h.Frames
+	c.Collisions = h.Collisions
+	c.Pixels = pixels
+
+	// Anything decoded from the old memory is stale.
+	c.resetCaches()
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package chip8
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCPU_MarshalBinary(t *testing.T) {
+	c := newHeadlessCPU(t, loadProgram(t, "pong.ch8"), nil)
+	if err := c.RunSteps(1000); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := newCPU(t)
+	if err := r.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if r.V != c.V {
+		t.Errorf("V => %v; want %v", r.V, c.V)
+	}
+	checkHex(t, "I", r.I, c.I)
+	checkHex(t, "PC", r.PC, c.PC)
+	checkHex(t, "SP", r.SP, c.SP)
+	checkHex(t, "DT", r.DT, c.DT)
+	checkHex(t, "ST", r.ST, c.ST)
+	if r.Cycles != c.Cycles {
+		t.Errorf("Cycles => %d; want %d", r.Cycles, c.Cycles)
+	}
+	if len(r.Stack) != len(c.Stack) {
+		t.Fatalf("len(Stack) => %d; want %d", len(r.Stack), len(c.Stack))
+	}
+	for i := range c.Stack {
+		checkHex(t, "Stack", r.Stack[i], c.Stack[i])
+	}
+	if !bytes.Equal(r.Memory, c.Memory) {
+		t.Error("Expected the memory to be restored")
+	}
+	checkGraphics(t, &r.Graphics, c.Hash())
+}
+
+func TestCPU_UnmarshalBinary_Invalid(t *testing.T) {
+	data, err := newCPU(t).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"Empty", nil, ErrInvalidState.Error()},
+		{"Truncated", data[:len(data)-1], ErrInvalidState.Error()},
+		{"Trailing", append(append([]byte(nil), data...), 0x00), ErrInvalidState.Error()},
+		{"Magic", append([]byte("NOPE"), data[4:]...), ErrInvalidState.Error()},
+		{"Version", append(append([]byte(nil), data[:4]...), append([]byte{StateVersion + 1}, data[5:]...)...), ErrStateVersion.Error()},
+	}
+
+	for _, tt := range tests {
+		c := newCPU(t)
+		err := c.UnmarshalBinary(tt.data)
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%s: err => %v; want %s", tt.name, err, tt.err)
+		}
+
+		// Nothing is restored from an invalid state.
+		checkHex(t, "PC", c.PC, 0x200)
+	}
+}
+
+func TestCPU_MarshalBinary_MidFrame(t *testing.T) {
+	newStateCPU := func() *CPU {
+		c, err := NewCPU(&Options{
+			ClockSpeed:  600,
+			Unthrottled: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Graphics.Display = NullDisplay
+		c.LatchKeys = true
+		c.Keypad = &ScriptedKeypad{}
+		return c
+	}
+
+	c := newStateCPU()
+	c.LoadBytes([]byte{
+		0x00, 0xE0, // CLS
+		0xD0, 0x05, // DRW V0, V0, 5
+		0x12, 0x02, // JP 0x202
+	})
+	c.SetHiRes(true)
+	c.DT = 0x80
+	c.ST = 0x40
+
+	// Stop partway through a frame, after a draw.
+	if err := c.RunSteps(24); err != nil {
+		t.Fatal(err)
+	}
+	if c.frameAcc == 0 || !c.drawn {
+		t.Fatal("Expected to stop partway through a frame, after a draw")
+	}
+
+	// The keys seen so far this frame.
+	c.keyLatch = keyLatch{valid: true, pressed: 0x0012}
+	c.keyWait = keyWait{pressed: true, key: 0x3, polled: true, held: 0x0008}
+
+	data, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := newStateCPU()
+	if err := r.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	checkHex(t, "DT", r.DT, c.DT)
+	checkHex(t, "ST", r.ST, c.ST)
+	if r.Cycles != c.Cycles {
+		t.Errorf("Cycles => %d; want %d", r.Cycles, c.Cycles)
+	}
+	if r.frameAcc != c.frameAcc {
+		t.Errorf("frameAcc => %d; want %d", r.frameAcc, c.frameAcc)
+	}
+	if r.drawn != c.drawn {
+		t.Errorf("drawn => %v; want %v", r.drawn, c.drawn)
+	}
+	if r.keyLatch != c.keyLatch {
+		t.Errorf("keyLatch => %+v; want %+v", r.keyLatch, c.keyLatch)
+	}
+	if r.keyWait != c.keyWait {
+		t.Errorf("keyWait => %+v; want %+v", r.keyWait, c.keyWait)
+	}
+	if !r.HiRes {
+		t.Error("Expected high resolution mode to be restored")
+	}
+	if r.Frames != c.Frames || r.Collisions != c.Collisions {
+		t.Errorf("Frames, Collisions => %d, %d; want %d, %d", r.Frames, r.Collisions, c.Frames, c.Collisions)
+	}
+	checkGraphics(t, &r.Graphics, c.Hash())
+
+	// Both carry on in lockstep, with the timers counting down on the
+	// same instructions.
+	for i := 0; i < 100; i++ {
+		c.Step()
+		r.Step()
+
+		if r.DT != c.DT || r.ST != c.ST {
+			t.Fatalf("step %d: DT, ST => 0x%02X, 0x%02X; want 0x%02X, 0x%02X", i, r.DT, r.ST, c.DT, c.ST)
+		}
+	}
+}
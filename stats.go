@@ -0,0 +1,53 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+// Stats is a snapshot of the counters of a CPU.
+type Stats struct {
+	// The number of instructions that have been executed.
+	Cycles uint64
+
+	// The number of frames that have been drawn.
+	Frames uint64
+
+	// The number of sprites drawn that collided with lit pixels.
+	Collisions uint64
+
+	// The number of times the sound timer has been started.
+	Beeps uint64
+
+	// Whether the buzzer was on.
+	Beeping bool
+}
+
+// Stats returns the counters of the CPU as of the last time they were
+// published by Run or RunSteps. Unlike the counters themselves, it's safe to
+// call from other goroutines while the CPU is running.
+func (c *CPU) Stats() Stats {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	return c.stats
+}
+
+// Beeping returns true while the sound timer is active, which is when the
+// buzzer is on.
+func (c *CPU) Beeping() bool {
+	return c.ST > 0
+}
+
+// publishStats takes a snapshot of the counters for Stats.
+func (c *CPU) publishStats() {
+	s := Stats{
+		Cycles:     c.Cycles,
+		Frames:     c.Graphics.Frames,
+		Collisions: c.Graphics.Collisions,
+		Beeps:      c.Beeps,
+		Beeping:    c.Beeping(),
+	}
+
+	c.statsMu.Lock()
+	c.stats = s
+	c.statsMu.Unlock()
+}
@@ -0,0 +1,78 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// PersistentStore maps a range of memory to a file, like the EEPROM in some
+// modern CHIP-8 carts, so games can keep high scores and saves between runs.
+// Whenever Fx55 stores registers into the range, the whole range is written
+// to the file, and it's read back into memory when the program is loaded.
+//
+// Each program gets its own file, named after the SHA-1 of the program, so
+// programs can't read each other's saves.
+type PersistentStore struct {
+	// The directory that the files are kept in.
+	Dir string
+
+	// The range of memory that's persisted, inclusive.
+	Start, End uint16
+}
+
+// Path returns the file that the range is persisted to for the program p.
+func (s *PersistentStore) Path(p []byte) string {
+	sum := sha1.Sum(p)
+	return filepath.Join(s.Dir, hex.EncodeToString(sum[:])+".sav")
+}
+
+// contains returns true if any of the addresses from start to end,
+// inclusive, are in the range.
+func (s *PersistentStore) contains(start, end int) bool {
+	return start <= int(s.End) && end >= int(s.Start)
+}
+
+// restore reads the persisted range for the program p back into memory. A
+// program that hasn't saved anything yet has no file, which isn't an error.
+func (c *CPU) restore(p []byte) error {
+	c.storePath = c.store.Path(p)
+
+	b, err := ioutil.ReadFile(c.storePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("chip8: could not restore persistent store: %v", err)
+	}
+
+	// Only restore what fits, in case the range has changed since it was
+	// saved.
+	for i := 0; i < len(b) && int(c.store.Start)+i <= int(c.store.End); i++ {
+		c.writeByte(int(c.store.Start)+i, b[i])
+	}
+
+	return nil
+}
+
+// persist writes the persisted range to the file, if addresses from start
+// to end, inclusive, were written to.
+func (c *CPU) persist(start, end int) error {
+	if c.store == nil || c.storePath == "" || !c.store.contains(start, end) {
+		return nil
+	}
+
+	b := c.Memory[c.store.Start : int(c.store.End)+1]
+	if err := ioutil.WriteFile(c.storePath, b, 0644); err != nil {
+		return fmt.Errorf("chip8: could not save persistent store: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package chip8
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestPersistentStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chip8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := &PersistentStore{
+		Dir:   dir,
+		Start: 0xE00,
+		End:   0xE0F,
+	}
+
+	program := []byte{
+		0xAE, 0x00, // LD I, 0xE00
+		0xF2, 0x55, // LD [I], V2
+	}
+
+	newStoreCPU := func(p []byte) *CPU {
+		t.Helper()
+
+		c, err := NewCPU(&Options{
+			ClockSpeed:      DefaultClockSpeed,
+			Unthrottled:     true,
+			PersistentStore: store,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.LoadBytes(p); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	c := newStoreCPU(program)
+	c.V[0], c.V[1], c.V[2] = 0x01, 0x02, 0x03
+	if err := c.RunSteps(2); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new CPU running the same program gets the saved values back.
+	c = newStoreCPU(program)
+	checkHex(t, "0xE00", c.Memory[0xE00], 0x01)
+	checkHex(t, "0xE01", c.Memory[0xE01], 0x02)
+	checkHex(t, "0xE02", c.Memory[0xE02], 0x03)
+
+	// Other programs don't.
+	c = newStoreCPU(append(program, 0x00, 0xE0))
+	checkHex(t, "0xE00", c.Memory[0xE00], 0x00)
+
+	// Stores outside the range aren't saved.
+	other := []byte{
+		0xAD, 0xFF, // LD I, 0xDFF
+		0xF0, 0x55, // LD [I], V0
+	}
+	c = newStoreCPU(other)
+	c.V[0] = 0xFF
+	if err := c.RunSteps(2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(store.Path(other)); !os.IsNotExist(err) {
+		t.Errorf("Expected no file for a store outside the range, got %v", err)
+	}
+}
+
+func TestPersistentStore_Invalid(t *testing.T) {
+	_, err := NewCPU(&Options{
+		ClockSpeed:      DefaultClockSpeed,
+		PersistentStore: &PersistentStore{Start: 0xE00, End: 0x1000},
+	})
+	if err == nil {
+		t.Fatal("Expected an error for a range outside of memory")
+	}
+}
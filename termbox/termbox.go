@@ -0,0 +1,95 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package termbox implements chip8.Terminal with termbox-go, so programs can
+// be run inside a real terminal.
+package termbox
+
+import (
+	"github.com/ejholmes/chip8"
+	termbox "github.com/nsf/termbox-go"
+)
+
+// Terminal is a chip8.Terminal for the terminal that the process is running
+// in. There's only one, so only one Terminal should be open at a time.
+type Terminal struct{}
+
+// Open takes over the terminal, hiding the cursor, and returns a Terminal
+// for it. Close gives it back.
+func Open() (*Terminal, error) {
+	if err := termbox.Init(); err != nil {
+		return nil, err
+	}
+
+	termbox.HideCursor()
+
+	return &Terminal{}, nil
+}
+
+// SetCell implements the chip8.Terminal interface.
+func (t *Terminal) SetCell(x, y int, ch rune, fg, bg chip8.Attribute) {
+	termbox.SetCell(x, y, ch, attribute(fg), attribute(bg))
+}
+
+// Flush implements the chip8.Terminal interface.
+func (t *Terminal) Flush() error {
+	return termbox.Flush()
+}
+
+// Clear implements the chip8.Terminal interface.
+func (t *Terminal) Clear(fg, bg chip8.Attribute) error {
+	return termbox.Clear(attribute(fg), attribute(bg))
+}
+
+// Size implements the chip8.Terminal interface.
+func (t *Terminal) Size() (width, height int) {
+	return termbox.Size()
+}
+
+// PollEvent implements the chip8.Terminal interface. Events other than key
+// presses, like resizes, are skipped.
+func (t *Terminal) PollEvent() chip8.Event {
+	for {
+		e := termbox.PollEvent()
+		if e.Type != termbox.EventKey {
+			continue
+		}
+
+		return event(e)
+	}
+}
+
+// Close restores the terminal to the way it was before Open.
+func (t *Terminal) Close() {
+	termbox.Close()
+}
+
+// attributes maps the chip8 flags to the termbox ones.
+var attributes = map[chip8.Attribute]termbox.Attribute{
+	chip8.AttrBold:      termbox.AttrBold,
+	chip8.AttrUnderline: termbox.AttrUnderline,
+	chip8.AttrReverse:   termbox.AttrReverse,
+}
+
+// attribute returns the termbox attribute for a chip8 one. The colors are
+// in the same order as termbox's.
+func attribute(a chip8.Attribute) termbox.Attribute {
+	attr := termbox.Attribute(a & 0xFF)
+	for flag, tb := range attributes {
+		if a&flag != 0 {
+			attr |= tb
+		}
+	}
+	return attr
+}
+
+// event returns the chip8 event for a termbox key event.
+func event(e termbox.Event) chip8.Event {
+	switch e.Key {
+	case termbox.KeyF5:
+		return chip8.Event{Key: chip8.KeyF5}
+	}
+
+	return chip8.Event{Ch: e.Ch}
+}
@@ -0,0 +1,44 @@
+package termbox
+
+import (
+	"testing"
+
+	"github.com/ejholmes/chip8"
+	termbox "github.com/nsf/termbox-go"
+)
+
+func TestAttribute(t *testing.T) {
+	tests := []struct {
+		attr chip8.Attribute
+		want termbox.Attribute
+	}{
+		{chip8.ColorDefault, termbox.ColorDefault},
+		{chip8.ColorBlack, termbox.ColorBlack},
+		{chip8.ColorGreen, termbox.ColorGreen},
+		{chip8.ColorWhite, termbox.ColorWhite},
+		{chip8.ColorYellow | chip8.AttrReverse, termbox.ColorYellow | termbox.AttrReverse},
+		{chip8.ColorRed | chip8.AttrBold | chip8.AttrUnderline, termbox.ColorRed | termbox.AttrBold | termbox.AttrUnderline},
+	}
+
+	for _, tt := range tests {
+		if got := attribute(tt.attr); got != tt.want {
+			t.Errorf("attribute(0x%04X) => 0x%04X; want 0x%04X", tt.attr, got, tt.want)
+		}
+	}
+}
+
+func TestEvent(t *testing.T) {
+	tests := []struct {
+		event termbox.Event
+		want  chip8.Event
+	}{
+		{termbox.Event{Type: termbox.EventKey, Ch: 'w'}, chip8.Event{Ch: 'w'}},
+		{termbox.Event{Type: termbox.EventKey, Key: termbox.KeyF5}, chip8.Event{Key: chip8.KeyF5}},
+	}
+
+	for _, tt := range tests {
+		if got := event(tt.event); got != tt.want {
+			t.Errorf("event(%+v) => %+v; want %+v", tt.event, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+// Terminal is a grid of character cells that the TerminalDisplay draws to,
+// and that the TerminalKeypad reads key presses from. The termbox
+// subpackage implements it with termbox-go, so this package doesn't depend
+// on a real terminal, and can be tested with a fake one.
+type Terminal interface {
+	// SetCell sets the character and colors of the cell at x, y. It
+	// isn't shown until Flush is called.
+	SetCell(x, y int, ch rune, fg, bg Attribute)
+
+	// Flush shows the cells that have been set.
+	Flush() error
+
+	// Clear sets every cell to a space with the given colors.
+	Clear(fg, bg Attribute) error
+
+	// Size returns the width and height of the terminal, in cells.
+	Size() (width, height int)
+
+	// PollEvent waits for the next key press.
+	PollEvent() Event
+}
+
+// Attribute is the color of a Terminal cell, combined with any of the
+// AttrBold, AttrUnderline and AttrReverse flags.
+type Attribute uint16
+
+// Colors for Terminal cells.
+const (
+	// ColorDefault is the terminal's own foreground or background
+	// color.
+	ColorDefault Attribute = iota
+	ColorBlack
+	ColorRed
+	ColorGreen
+	ColorYellow
+	ColorBlue
+	ColorMagenta
+	ColorCyan
+	ColorWhite
+)
+
+// Flags that change how a Terminal cell is drawn.
+const (
+	AttrBold Attribute = 1 << (iota + 9)
+	AttrUnderline
+	AttrReverse
+)
+
+// Key is a key on the keyboard that doesn't type a character.
+type Key int
+
+const (
+	// KeyNone means that the key typed a character, which is in
+	// Event.Ch.
+	KeyNone Key = iota
+
+	// KeyF5 is the F5 function key.
+	KeyF5
+)
+
+// Event is a key press read from a Terminal.
+type Event struct {
+	// The character that was typed, if any.
+	Ch rune
+
+	// The key that was pressed, if it doesn't type a character.
+	Key Key
+}
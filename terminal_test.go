@@ -0,0 +1,264 @@
+package chip8
+
+import "testing"
+
+// fakeTerminal is a Terminal that keeps its cells in memory, and returns
+// key presses from a list.
+type fakeTerminal struct {
+	width, height int
+
+	cells   map[[2]int]fakeCell
+	flushes int
+
+	events []Event
+}
+
+// fakeCell is a cell of a fakeTerminal.
+type fakeCell struct {
+	ch     rune
+	fg, bg Attribute
+}
+
+func newFakeTerminal(events ...Event) *fakeTerminal {
+	return &fakeTerminal{
+		width:  80,
+		height: 40,
+		cells:  make(map[[2]int]fakeCell),
+		events: events,
+	}
+}
+
+func (t *fakeTerminal) SetCell(x, y int, ch rune, fg, bg Attribute) {
+	t.cells[[2]int{x, y}] = fakeCell{ch, fg, bg}
+}
+
+func (t *fakeTerminal) Flush() error {
+	t.flushes++
+	return nil
+}
+
+func (t *fakeTerminal) Clear(fg, bg Attribute) error {
+	t.cells = make(map[[2]int]fakeCell)
+	return nil
+}
+
+func (t *fakeTerminal) Size() (width, height int) {
+	return t.width, t.height
+}
+
+// PollEvent returns the next event, or the escape key once they run out.
+func (t *fakeTerminal) PollEvent() Event {
+	if len(t.events) == 0 {
+		return Event{Ch: escapeKey}
+	}
+
+	e := t.events[0]
+	t.events = t.events[1:]
+	return e
+}
+
+func TestTerminalDisplay(t *testing.T) {
+	term := newFakeTerminal()
+
+	d, err := NewTerminalDisplay(term, ColorGreen, ColorBlack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if term.flushes != 1 {
+		t.Errorf("flushes => %d; want 1 after clearing", term.flushes)
+	}
+
+	g := new(Graphics)
+	g.WriteSprite([]byte{0xC0}, 10, 5)
+
+	if err := d.Render(g); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(term.cells) != GraphicsWidth*GraphicsHeight {
+		t.Errorf("%d cells set; want %d", len(term.cells), GraphicsWidth*GraphicsHeight)
+	}
+	for _, tt := range []struct {
+		x, y int
+		ch   rune
+	}{
+		{10, 5, '█'},
+		{11, 5, '█'},
+		{12, 5, ' '},
+		{10, 6, ' '},
+	} {
+		want := fakeCell{tt.ch, ColorGreen, ColorBlack}
+		if got := term.cells[[2]int{tt.x, tt.y}]; got != want {
+			t.Errorf("cell %d,%d => %+v; want %+v", tt.x, tt.y, got, want)
+		}
+	}
+	if term.flushes != 2 {
+		t.Errorf("flushes => %d; want 2", term.flushes)
+	}
+
+	// High resolution uses a cell for every pixel too.
+	g.SetHiRes(true)
+	g.Set(HiResWidth-1, HiResHeight-1, true)
+	if err := d.Render(g); err != nil {
+		t.Fatal(err)
+	}
+	if got := term.cells[[2]int{HiResWidth - 1, HiResHeight - 1}].ch; got != '█' {
+		t.Errorf("bottom right cell => %q; want %q", got, '█')
+	}
+}
+
+func TestTerminalDisplay_SetGlyphs(t *testing.T) {
+	term := newFakeTerminal()
+
+	d, err := NewTerminalDisplay(term, ColorGreen, ColorBlack)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := new(Graphics)
+	g.WriteSprite([]byte{0x80}, 10, 5)
+
+	for _, tt := range []struct {
+		on, off         rune
+		wantOn, wantOff rune
+	}{
+		{'*', '.', '*', '.'},
+		{'#', 0, '#', DefaultOffGlyph},
+		{0, 0, DefaultOnGlyph, DefaultOffGlyph},
+	} {
+		d.SetGlyphs(tt.on, tt.off)
+
+		if err := d.Render(g); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := term.cells[[2]int{10, 5}].ch; got != tt.wantOn {
+			t.Errorf("SetGlyphs(%q, %q): on cell => %q; want %q", tt.on, tt.off, got, tt.wantOn)
+		}
+		if got := term.cells[[2]int{11, 5}].ch; got != tt.wantOff {
+			t.Errorf("SetGlyphs(%q, %q): off cell => %q; want %q", tt.on, tt.off, got, tt.wantOff)
+		}
+	}
+}
+
+func TestNewTerminalDisplayGlyphs(t *testing.T) {
+	term := newFakeTerminal()
+
+	d, err := NewTerminalDisplayGlyphs(term, ColorGreen, ColorBlack, '*', '.')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Pixels that are only on in the second plane are on too.
+	g := new(Graphics)
+	g.Pixels[5*GraphicsWidth+10] = 0x01
+	g.Pixels[5*GraphicsWidth+12] = 0x02
+
+	if err := d.Render(g); err != nil {
+		t.Fatal(err)
+	}
+
+	for x, want := range map[int]rune{10: '*', 11: '.', 12: '*'} {
+		if got := term.cells[[2]int{x, 5}].ch; got != want {
+			t.Errorf("cell %d, 5 => %q; want %q", x, got, want)
+		}
+	}
+}
+
+func TestNewTerminalDisplayTheme(t *testing.T) {
+	term := newFakeTerminal()
+
+	d, err := NewTerminalDisplayTheme(term, Themes["amber"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := new(Graphics)
+	g.Set(0, 0, true)
+	d.Render(g)
+
+	want := fakeCell{'█', ColorYellow, ColorBlack}
+	if got := term.cells[[2]int{0, 0}]; got != want {
+		t.Errorf("cell 0,0 => %+v; want %+v", got, want)
+	}
+}
+
+func TestTerminalKeypad(t *testing.T) {
+	term := newFakeTerminal(
+		Event{Ch: '1'},
+		Event{Ch: 'v'},
+		Event{Ch: 'p'},
+		Event{Key: KeyF5},
+	)
+	k := NewTerminalKeypad(term)
+
+	for _, want := range []byte{0x1, 0xF} {
+		key, err := k.ReadByte()
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkHex(t, "key", key, want)
+	}
+
+	if _, err := k.ReadByte(); err == nil {
+		t.Error("Expected an error for an unknown key")
+	}
+	if _, err := k.ReadByte(); err != ErrReset {
+		t.Errorf("err => %v; want %v", err, ErrReset)
+	}
+
+	// The fake returns the escape key once it runs out.
+	if _, err := k.ReadByte(); err != ErrQuit {
+		t.Errorf("err => %v; want %v", err, ErrQuit)
+	}
+}
+
+func TestKeypadPanel(t *testing.T) {
+	term := newFakeTerminal()
+	d, err := NewTerminalDisplay(term, ColorWhite, ColorBlack)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k := new(MemoryKeypad)
+	k.Press(0x5)
+
+	// Fast enough that the keys are still latched when it draws.
+	c, err := NewCPU(&Options{
+		ClockSpeed:  600,
+		Unthrottled: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Keypad = k
+	c.LatchKeys = true
+	c.Graphics.Display = NewKeypadPanel(d, c)
+	c.LoadBytes([]byte{
+		0x60, 0x05, // LD V0, 0x05
+		0xE0, 0xA1, // SKNP V0
+		0xD0, 0x01, // DRW V0, V0, 1
+	})
+
+	if err := c.RunSteps(3); err != nil {
+		t.Fatal(err)
+	}
+
+	// The keypad is drawn to the right of the graphics array, a row per
+	// row of keys, with 3 cells per key.
+	left := GraphicsWidth + 2
+	for r, row := range KeypadLayout {
+		for col, key := range row {
+			cell := term.cells[[2]int{left + col*3 + 1, r}]
+
+			if want := rune("0123456789ABCDEF"[key]); cell.ch != want {
+				t.Errorf("key %X => %q; want %q", key, cell.ch, want)
+			}
+
+			held := cell.fg&AttrReverse != 0
+			if held != (key == 0x5) {
+				t.Errorf("key %X: held => %v; want %v", key, held, key == 0x5)
+			}
+		}
+	}
+}
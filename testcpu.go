@@ -0,0 +1,81 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+import (
+	"math/rand"
+	"time"
+)
+
+// TestOption configures a CPU built by NewTestCPU.
+type TestOption func(*CPU)
+
+// NewTestCPU returns a CPU that's set up for deterministic tests: it runs
+// unthrottled on a ManualTimeSource that starts at the Unix epoch, renders
+// to a MemoryDisplay, reads keys from an empty ScriptedKeypad, so a program
+// that waits for a key stops with ErrQuit, and gets random numbers from a
+// generator seeded with 1. The options are applied in order, after the
+// defaults.
+//
+// NewTestCPU panics if the CPU can't be built, since that's a bug in the
+// test.
+func NewTestCPU(opts ...TestOption) *CPU {
+	c, err := NewCPU(&Options{
+		ClockSpeed:  DefaultClockSpeed,
+		Unthrottled: true,
+		TimeSource:  NewManualTimeSource(time.Unix(0, 0)),
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	c.Graphics.Display = new(MemoryDisplay)
+	c.Keypad = new(ScriptedKeypad)
+	c.Rand = rand.New(rand.NewSource(1))
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// WithProgram loads the program p into memory.
+func WithProgram(p []byte) TestOption {
+	return func(c *CPU) {
+		if _, err := c.LoadBytes(p); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// WithKeys makes the keypad return keys, in order, starting over once
+// they're exhausted.
+func WithKeys(keys ...byte) TestOption {
+	return func(c *CPU) {
+		c.Keypad = &ScriptedKeypad{Keys: keys}
+	}
+}
+
+// WithSeed seeds the random number generator used by Cxkk.
+func WithSeed(seed int64) TestOption {
+	return func(c *CPU) {
+		c.Rand = rand.New(rand.NewSource(seed))
+	}
+}
+
+// WithDisplay renders to d instead of a MemoryDisplay.
+func WithDisplay(d Display) TestOption {
+	return func(c *CPU) {
+		c.Graphics.Display = d
+	}
+}
+
+// WithQuirks sets the Quirks.
+func WithQuirks(q Quirks) TestOption {
+	return func(c *CPU) {
+		c.Quirks = q
+	}
+}
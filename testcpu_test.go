@@ -0,0 +1,87 @@
+package chip8
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewTestCPU(t *testing.T) {
+	p := []byte{
+		0x60, 0x05, // LD V0, 0x05
+		0xF0, 0x29, // LD F, V0
+		0xD1, 0x15, // DRW V1, V1, 5
+		0xC2, 0xFF, // RND V2, 0xFF
+		0xF3, 0x0A, // LD V3, K
+	}
+	c := NewTestCPU(WithProgram(p))
+
+	// Waiting for a key stops the CPU, since there are no keys.
+	if err := c.RunSteps(100); err != nil {
+		t.Fatal(err)
+	}
+	checkHex(t, "PC", c.PC, 0x208)
+
+	// The sprite was rendered to memory.
+	d, ok := c.Graphics.Display.(*MemoryDisplay)
+	if !ok {
+		t.Fatalf("Display => %T; want *MemoryDisplay", c.Graphics.Display)
+	}
+	if d.Frames != 1 {
+		t.Errorf("Frames => %d; want 1", d.Frames)
+	}
+	if d.Pixels != c.Graphics.Pixels {
+		t.Error("Expected the MemoryDisplay to have the last frame")
+	}
+
+	// The random numbers come from a generator seeded with 1.
+	seeded := NewTestCPU(WithProgram(p), WithSeed(1))
+	if err := seeded.RunSteps(100); err != nil {
+		t.Fatal(err)
+	}
+	checkHex(t, "V2", c.V[2], seeded.V[2])
+
+	// The clock doesn't move on its own.
+	if got := c.now(); !got.Equal(time.Unix(0, 0)) {
+		t.Errorf("now() => %v; want the Unix epoch", got)
+	}
+}
+
+func TestNewTestCPU_Options(t *testing.T) {
+	build := func() *CPU {
+		c := NewTestCPU(
+			WithProgram([]byte{
+				0xF0, 0x0A, // LD V0, K
+				0xF1, 0x0A, // LD V1, K
+				0xC2, 0xFF, // RND V2, 0xFF
+				0xC3, 0xFF, // RND V3, 0xFF
+				0xD0, 0x15, // DRW V0, V1, 5
+			}),
+			WithKeys(0x0A, 0x0B),
+			WithSeed(42),
+			WithDisplay(NullDisplay),
+			WithQuirks(QuirksCOSMAC),
+		)
+
+		if err := c.RunSteps(5); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	c := build()
+
+	checkHex(t, "V0", c.V[0], 0x0A)
+	checkHex(t, "V1", c.V[1], 0x0B)
+
+	// CPUs with the same seed get the same random numbers.
+	if other := build(); c.V != other.V {
+		t.Errorf("V => %X; want %X", c.V, other.V)
+	}
+
+	if _, ok := c.Graphics.Display.(*MemoryDisplay); ok {
+		t.Error("Expected WithDisplay to replace the MemoryDisplay")
+	}
+	if c.Quirks != QuirksCOSMAC {
+		t.Errorf("Quirks => %+v; want %+v", c.Quirks, QuirksCOSMAC)
+	}
+}
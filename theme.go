@@ -0,0 +1,128 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+import (
+	"image"
+	"image/color"
+)
+
+// Theme is a color scheme for displays.
+type Theme struct {
+	// The color of pixels that are off.
+	Background color.Color
+
+	// The color of pixels that are on.
+	Foreground color.Color
+
+	// The colors of pixels that are only on in XO-CHIP's second plane, and
+	// of pixels that are on in both planes. When nil, the Foreground is
+	// used.
+	Plane2 color.Color
+	Both   color.Color
+}
+
+// Themes are the built in themes, by name.
+var Themes = map[string]Theme{
+	"classic": {
+		Background: color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		Foreground: color.RGBA{0xFF, 0xFF, 0xFF, 0xFF},
+		Plane2:     color.RGBA{0xAA, 0xAA, 0xAA, 0xFF},
+		Both:       color.RGBA{0x55, 0x55, 0x55, 0xFF},
+	},
+	"green": {
+		Background: color.RGBA{0x0A, 0x1A, 0x0A, 0xFF},
+		Foreground: color.RGBA{0x33, 0xFF, 0x33, 0xFF},
+		Plane2:     color.RGBA{0x1F, 0x99, 0x1F, 0xFF},
+		Both:       color.RGBA{0x99, 0xFF, 0x99, 0xFF},
+	},
+	"amber": {
+		Background: color.RGBA{0x1A, 0x10, 0x00, 0xFF},
+		Foreground: color.RGBA{0xFF, 0xB0, 0x00, 0xFF},
+		Plane2:     color.RGBA{0x99, 0x69, 0x00, 0xFF},
+		Both:       color.RGBA{0xFF, 0xD8, 0x80, 0xFF},
+	},
+	"lcd": {
+		Background: color.RGBA{0x9B, 0xBC, 0x0F, 0xFF},
+		Foreground: color.RGBA{0x0F, 0x38, 0x0F, 0xFF},
+		Plane2:     color.RGBA{0x8B, 0xAC, 0x0F, 0xFF},
+		Both:       color.RGBA{0x30, 0x62, 0x30, 0xFF},
+	},
+}
+
+// DefaultTheme is the name of the theme used when none is chosen.
+var DefaultTheme = "classic"
+
+// LookupTheme returns the built in theme with the given name.
+func LookupTheme(name string) (Theme, bool) {
+	t, ok := Themes[name]
+	return t, ok
+}
+
+// Palette returns the Theme's colors as a Palette.
+func (t Theme) Palette() Palette {
+	p := Palette{t.Background, t.Foreground, t.Plane2, t.Both}
+	for i := 2; i < len(p); i++ {
+		if p[i] == nil {
+			p[i] = t.Foreground
+		}
+	}
+	return p
+}
+
+// ThemedImage is like Image, but with the colors from a Theme.
+func (g *Graphics) ThemedImage(scale int, t Theme) image.Image {
+	p := t.Palette()
+	return g.PaletteImage(scale, &p)
+}
+
+// NewTerminalDisplayTheme returns a new TerminalDisplay that draws to term
+// with the closest terminal colors to the Theme.
+func NewTerminalDisplayTheme(term Terminal, t Theme) (*TerminalDisplay, error) {
+	return NewTerminalDisplay(term, terminalColor(t.Foreground), terminalColor(t.Background))
+}
+
+// terminalColors are the basic terminal colors.
+var terminalColors = []struct {
+	attr    Attribute
+	r, g, b uint32
+}{
+	{ColorBlack, 0x00, 0x00, 0x00},
+	{ColorRed, 0xCD, 0x00, 0x00},
+	{ColorGreen, 0x00, 0xCD, 0x00},
+	{ColorYellow, 0xCD, 0xCD, 0x00},
+	{ColorBlue, 0x00, 0x00, 0xEE},
+	{ColorMagenta, 0xCD, 0x00, 0xCD},
+	{ColorCyan, 0x00, 0xCD, 0xCD},
+	{ColorWhite, 0xE5, 0xE5, 0xE5},
+}
+
+// terminalColor returns the basic terminal color closest to c.
+func terminalColor(c color.Color) Attribute {
+	if c == nil {
+		return ColorDefault
+	}
+
+	r, g, b, _ := c.RGBA()
+	r, g, b = r>>8, g>>8, b>>8
+
+	best, bestDist := ColorDefault, uint32(1<<32-1)
+	for _, tc := range terminalColors {
+		dist := sq(r, tc.r) + sq(g, tc.g) + sq(b, tc.b)
+		if dist < bestDist {
+			best, bestDist = tc.attr, dist
+		}
+	}
+
+	return best
+}
+
+// sq returns the square of the difference between a and b.
+func sq(a, b uint32) uint32 {
+	if a < b {
+		a, b = b, a
+	}
+	return (a - b) * (a - b)
+}
@@ -0,0 +1,74 @@
+package chip8
+
+import "testing"
+
+func TestLookupTheme(t *testing.T) {
+	for _, name := range []string{"classic", "green", "amber", "lcd"} {
+		theme, ok := LookupTheme(name)
+		if !ok {
+			t.Fatalf("LookupTheme(%q) => not found", name)
+		}
+
+		g := new(Graphics)
+		g.WriteSprite([]byte{0x80}, 0, 0)
+
+		img := g.ThemedImage(1, theme)
+		if got := img.At(0, 0); !sameColor(got, theme.Foreground) {
+			t.Errorf("%s: At(0, 0) => %v; want %v", name, got, theme.Foreground)
+		}
+		if got := img.At(1, 0); !sameColor(got, theme.Background) {
+			t.Errorf("%s: At(1, 0) => %v; want %v", name, got, theme.Background)
+		}
+	}
+
+	if _, ok := LookupTheme("sepia"); ok {
+		t.Error("Expected an unknown theme not to be found")
+	}
+
+	if _, ok := LookupTheme(DefaultTheme); !ok {
+		t.Error("Expected the DefaultTheme to be found")
+	}
+}
+
+func TestTheme_Palette(t *testing.T) {
+	classic := Themes["classic"]
+	if got, want := classic.Palette(), (Palette{classic.Background, classic.Foreground, classic.Plane2, classic.Both}); got != want {
+		t.Errorf("Palette() => %v; want %v", got, want)
+	}
+
+	// Without colors for the second plane, it's drawn in the Foreground.
+	mono := Theme{Background: classic.Background, Foreground: classic.Foreground}
+	if got, want := mono.Palette(), (Palette{classic.Background, classic.Foreground, classic.Foreground, classic.Foreground}); got != want {
+		t.Errorf("Palette() => %v; want %v", got, want)
+	}
+}
+
+func TestTerminalColor(t *testing.T) {
+	tests := []struct {
+		theme string
+		fg    Attribute
+		bg    Attribute
+	}{
+		{"classic", ColorWhite, ColorBlack},
+		{"green", ColorGreen, ColorBlack},
+		{"amber", ColorYellow, ColorBlack},
+	}
+
+	for _, tt := range tests {
+		theme := Themes[tt.theme]
+		if fg := terminalColor(theme.Foreground); fg != tt.fg {
+			t.Errorf("%s: foreground => %v; want %v", tt.theme, fg, tt.fg)
+		}
+		if bg := terminalColor(theme.Background); bg != tt.bg {
+			t.Errorf("%s: background => %v; want %v", tt.theme, bg, tt.bg)
+		}
+	}
+}
+
+func sameColor(a, b interface {
+	RGBA() (r, g, b, a uint32)
+}) bool {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	return ar == br && ag == bg && ab == bb && aa == ba
+}
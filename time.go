@@ -0,0 +1,103 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+import (
+	"sync"
+	"time"
+)
+
+// TimeSource is where the CPU gets the time from. The clock that paces
+// instructions is derived from it, and the timers are derived from the
+// instructions, so a TimeSource that's controlled by hand makes runs
+// reproducible.
+type TimeSource interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// Tick returns a channel that delivers ticks every d.
+	Tick(d time.Duration) <-chan time.Time
+}
+
+// RealTime is the TimeSource for the real, wall clock, time.
+var RealTime TimeSource = realTime{}
+
+// realTime is the TimeSource for RealTime.
+type realTime struct{}
+
+func (realTime) Now() time.Time {
+	return now()
+}
+
+func (realTime) Tick(d time.Duration) <-chan time.Time {
+	return time.Tick(d)
+}
+
+// ManualTimeSource is a TimeSource whose time only moves when it's
+// advanced, which makes a running CPU deterministic. Its ticks are only
+// delivered by Advance.
+type ManualTimeSource struct {
+	mu sync.Mutex
+	t  time.Time
+
+	ticks chan time.Time
+}
+
+// NewManualTimeSource returns a new ManualTimeSource that starts at t.
+func NewManualTimeSource(t time.Time) *ManualTimeSource {
+	return &ManualTimeSource{
+		t:     t,
+		ticks: make(chan time.Time),
+	}
+}
+
+// Now implements the TimeSource interface.
+func (m *ManualTimeSource) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.t
+}
+
+// Tick implements the TimeSource interface. The ticks aren't periodic;
+// there's one for every call to Advance.
+func (m *ManualTimeSource) Tick(d time.Duration) <-chan time.Time {
+	return m.ticks
+}
+
+// Advance moves the time forward by d and ticks, so a running CPU executes
+// the instructions that are due. It blocks until the CPU has executed them,
+// so the CPU can be inspected afterwards.
+//
+// Advance must only be called while the CPU is running; there's nothing to
+// receive the ticks otherwise, and it blocks forever. The CPU doesn't catch
+// up on more than maxCatchUp at once, so longer durations are advanced in
+// maxCatchUp sized steps, each with its own tick.
+func (m *ManualTimeSource) Advance(d time.Duration) {
+	for {
+		step := d
+		if step > maxCatchUp {
+			step = maxCatchUp
+		}
+		m.advance(step)
+
+		d -= step
+		if d <= 0 {
+			return
+		}
+	}
+}
+
+// advance moves the time forward by d and ticks once.
+func (m *ManualTimeSource) advance(d time.Duration) {
+	m.mu.Lock()
+	m.t = m.t.Add(d)
+	t := m.t
+	m.mu.Unlock()
+
+	// The CPU only waits on the next tick once it's finished with the
+	// last one.
+	m.ticks <- t
+	m.ticks <- t
+}
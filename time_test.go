@@ -0,0 +1,110 @@
+package chip8
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManualTimeSource(t *testing.T) {
+	// Waits half a second on the delay timer, then draws a 0.
+	p := []byte{
+		0x60, 0x1E, // LD V0, 0x1E
+		0xF0, 0x15, // LD DT, V0
+		0xF1, 0x07, // LD V1, DT
+		0x31, 0x00, // SE V1, 0x00
+		0x12, 0x04, // JP 0x204
+		0xD2, 0x25, // DRW V2, V2, 5
+		0x12, 0x0C, // JP 0x20C
+	}
+
+	run := func() (hash string, cycles uint64, dt byte) {
+		ts := NewManualTimeSource(time.Unix(0, 0))
+
+		c, err := NewCPU(&Options{
+			ClockSpeed: 600,
+			TimeSource: ts,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.LoadBytes(p)
+
+		done := make(chan error)
+		go func() {
+			done <- c.Run()
+		}()
+
+		// Wait for Run to start.
+		ts.Advance(0)
+
+		// A quarter of a second in, the delay timer is still
+		// running.
+		ts.Advance(250 * time.Millisecond)
+		dt = c.DT
+		if c.Pixels[0] != 0x00 {
+			t.Error("Expected nothing to be drawn yet")
+		}
+
+		ts.Advance(250 * time.Millisecond)
+		ts.Advance(50 * time.Millisecond)
+
+		c.Stop()
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+
+		return c.Hash(), c.Cycles, dt
+	}
+
+	hash, cycles, dt := run()
+
+	checkHex(t, "DT", dt, 0x1E-15)
+	if cycles != 330 {
+		t.Errorf("Cycles => %d; want 330", cycles)
+	}
+	if hash == new(Graphics).Hash() {
+		t.Error("Expected the 0 to be drawn once the delay timer ran out")
+	}
+
+	// Runs are reproducible.
+	for i := 0; i < 3; i++ {
+		if h, c, d := run(); h != hash || c != cycles || d != dt {
+			t.Fatalf("run %d => %s, %d, %d; want %s, %d, %d", i, h, c, d, hash, cycles, dt)
+		}
+	}
+}
+
+func TestManualTimeSource_Advance_Long(t *testing.T) {
+	ts := NewManualTimeSource(time.Unix(0, 0))
+
+	c, err := NewCPU(&Options{
+		ClockSpeed: 600,
+		TimeSource: ts,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.LoadBytes([]byte{
+		0x12, 0x00, // JP 0x200
+	})
+
+	done := make(chan error)
+	go func() {
+		done <- c.Run()
+	}()
+
+	ts.Advance(0)
+
+	// A whole second is more than the CPU catches up on at once, but
+	// none of it is dropped.
+	ts.Advance(time.Second)
+
+	c.Stop()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Cycles != 600 {
+		t.Errorf("Cycles => %d; want 600", c.Cycles)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+import "fmt"
+
+// TraceEntry is an instruction that was executed.
+type TraceEntry struct {
+	// The address of the instruction.
+	PC uint16
+
+	// The opcode.
+	Opcode uint16
+}
+
+// String returns the entry in the same format as a disassembly listing.
+func (e TraceEntry) String() string {
+	return fmt.Sprintf("0x%03X  %04X  %s", e.PC, e.Opcode, Disassemble(e.Opcode))
+}
+
+// RecentInstructions returns the last instructions that were executed,
+// oldest first, including the one that's executing. It's empty unless
+// Options.TraceSize is set.
+func (c *CPU) RecentInstructions() []TraceEntry {
+	return c.trace.entries()
+}
+
+// traceRing is a fixed size ring buffer of the instructions executed.
+type traceRing struct {
+	buf []TraceEntry
+
+	// the index of the next entry to write, and whether buf has wrapped.
+	next int
+	full bool
+}
+
+// add records an instruction, replacing the oldest when the buffer is full.
+func (r *traceRing) add(pc, op uint16) {
+	if len(r.buf) == 0 {
+		return
+	}
+
+	r.buf[r.next] = TraceEntry{PC: pc, Opcode: op}
+	r.next++
+	if r.next == len(r.buf) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// entries returns a copy of the buffer, oldest first.
+func (r *traceRing) entries() []TraceEntry {
+	if !r.full {
+		return append([]TraceEntry(nil), r.buf[:r.next]...)
+	}
+
+	return append(append([]TraceEntry(nil), r.buf[r.next:]...), r.buf[:r.next]...)
+}
@@ -0,0 +1,64 @@
+package chip8
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCPU_RecentInstructions(t *testing.T) {
+	c, err := NewCPU(&Options{
+		ClockSpeed:  DefaultClockSpeed,
+		Unthrottled: true,
+		TraceSize:   3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.LoadBytes([]byte{
+		0x61, 0x02, // LD V1, 0x02
+		0x62, 0x03, // LD V2, 0x03
+		0x63, 0x04, // LD V3, 0x04
+		0x12, 0x0A, // JP 0x20A
+		0x00, 0x00, // Skipped
+		0x51, 0x21, // Invalid
+	})
+
+	if got := c.RecentInstructions(); len(got) != 0 {
+		t.Fatalf("RecentInstructions() => %v; want none", got)
+	}
+
+	err = c.RunSteps(10)
+
+	e, ok := err.(*UnknownOpcode)
+	if !ok {
+		t.Fatalf("err => %v; want an UnknownOpcode", err)
+	}
+
+	want := []TraceEntry{
+		{0x204, 0x6304},
+		{0x206, 0x120A},
+		{0x20A, 0x5121},
+	}
+
+	recent := c.RecentInstructions()
+	if len(recent) != len(want) {
+		t.Fatalf("RecentInstructions() => %v; want %v", recent, want)
+	}
+	for i := range want {
+		if recent[i] != want[i] {
+			t.Errorf("RecentInstructions()[%d] => %v; want %v", i, recent[i], want[i])
+		}
+		if e.Recent[i] != want[i] {
+			t.Errorf("Recent[%d] => %v; want %v", i, e.Recent[i], want[i])
+		}
+	}
+
+	trace := `Recent instructions:
+  0x204  6304  LD V3, 0x04
+  0x206  120A  JP 0x20A
+  0x20A  5121  DW 0x5121
+`
+	if got := err.Error(); !strings.HasSuffix(got, trace) {
+		t.Errorf("Error() =>\n%s\nwant a suffix of:\n%s", got, trace)
+	}
+}
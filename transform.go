@@ -0,0 +1,58 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+// Transform determines how the graphics array is reoriented before it's
+// displayed.
+type Transform int
+
+const (
+	// TransformNone leaves the graphics array as is.
+	TransformNone Transform = iota
+
+	// TransformFlipHorizontal mirrors the graphics array left to right.
+	TransformFlipHorizontal
+
+	// TransformFlipVertical mirrors the graphics array top to bottom.
+	TransformFlipVertical
+
+	// TransformRotate180 turns the graphics array upside down, which is
+	// both flips at once.
+	TransformRotate180
+)
+
+// TransformDisplay returns a Display that hands inner a copy of the graphics
+// array with its pixels reoriented by t.
+func TransformDisplay(inner Display, t Transform) Display {
+	return DisplayFunc(func(g *Graphics) error {
+		c := g.Clone()
+		c.Pixels = t.apply(g)
+		return inner.Render(c)
+	})
+}
+
+// apply returns the pixels of g reoriented by t.
+func (t Transform) apply(g *Graphics) (out [HiResWidth * HiResHeight]byte) {
+	flipX := t == TransformFlipHorizontal || t == TransformRotate180
+	flipY := t == TransformFlipVertical || t == TransformRotate180
+
+	w, h := g.Width(), g.Height()
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			xp, yp := x, y
+			if flipX {
+				xp = w - 1 - x
+			}
+			if flipY {
+				yp = h - 1 - y
+			}
+
+			out[yp*w+xp] = g.Pixels[y*w+x]
+		}
+	}
+
+	return
+}
@@ -0,0 +1,41 @@
+package chip8
+
+import "testing"
+
+func TestTransformDisplay(t *testing.T) {
+	// An L, which looks different in every orientation:
+	//
+	//	#.
+	//	##
+	g := new(Graphics)
+	g.WriteSprite([]byte{0x80, 0xC0}, 0, 0)
+
+	tests := []struct {
+		transform Transform
+		on        [][2]int
+	}{
+		{TransformNone, [][2]int{{0, 0}, {0, 1}, {1, 1}}},
+		{TransformFlipHorizontal, [][2]int{{63, 0}, {63, 1}, {62, 1}}},
+		{TransformFlipVertical, [][2]int{{0, 31}, {0, 30}, {1, 30}}},
+		{TransformRotate180, [][2]int{{63, 31}, {63, 30}, {62, 30}}},
+	}
+
+	for _, tt := range tests {
+		d := new(MemoryDisplay)
+		if err := TransformDisplay(d, tt.transform).Render(g); err != nil {
+			t.Fatal(err)
+		}
+
+		var want [HiResWidth * HiResHeight]byte
+		for _, p := range tt.on {
+			want[p[1]*GraphicsWidth+p[0]] = 0x01
+		}
+
+		if d.Pixels != want {
+			t.Errorf("Transform(%d) =>\n%s\nwant:\n%s", tt.transform, &Graphics{Pixels: d.Pixels}, &Graphics{Pixels: want})
+		}
+	}
+
+	// The graphics array itself is left alone.
+	checkHex(t, "Pixels[0]", g.Pixels[0], 0x01)
+}
@@ -0,0 +1,136 @@
+// Copyright 2014 Eric Holmes.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chip8
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationIssue describes an opcode in a program that the CPU can't
+// execute.
+type ValidationIssue struct {
+	// The address of the opcode, assuming the program is loaded at 0x200.
+	Addr uint16
+
+	// The opcode.
+	Opcode uint16
+
+	// Why the opcode can't be executed.
+	Reason string
+}
+
+// String returns the issue in the same format as a disassembly listing.
+func (i ValidationIssue) String() string {
+	return fmt.Sprintf("0x%03X  %04X  %s", i.Addr, i.Opcode, i.Reason)
+}
+
+// Validate reports the opcodes in a program that the CPU can't execute when
+// running with the quirks q, without running the program. Programs can mix
+// data in with their instructions, and data is validated like any other
+// opcode, so an issue only means the program might fail.
+func Validate(p []byte, q Quirks) []ValidationIssue {
+	var issues []ValidationIssue
+
+	for i := 0; i+1 < len(p); i += 2 {
+		op := uint16(p[i])<<8 | uint16(p[i+1])
+
+		if reason := unsupported(op, q); reason != "" {
+			issues = append(issues, ValidationIssue{
+				Addr:   uint16(0x200 + i),
+				Opcode: op,
+				Reason: reason,
+			})
+		}
+	}
+
+	return issues
+}
+
+// unsupported returns the reason that op can't be executed, or an empty
+// string if it can.
+func unsupported(op uint16, q Quirks) string {
+	switch {
+	case op == 0x00E0, op == 0x00EE, op == 0x00FD:
+		return ""
+
+	// SUPER-CHIP scrolling.
+	case op&0xFFF0 == 0x00C0, op == 0x00FB, op == 0x00FC:
+		return ""
+	}
+
+	// The rest of the extensions aren't implemented yet, regardless of
+	// the quirks.
+	if ext := extension(op); ext != "" {
+		return fmt.Sprintf("%s instruction", ext)
+	}
+
+	m := Disassemble(op)
+	switch {
+	case strings.HasPrefix(m, "SYS"):
+		return fmt.Sprintf("%s calls a machine code routine", m)
+	case strings.HasPrefix(m, "DW"):
+		return "unknown opcode"
+	}
+
+	return ""
+}
+
+// Region is a range of memory.
+type Region struct {
+	// What the region is used for.
+	Name string
+
+	// The first and last addresses in the region.
+	Start, End uint16
+}
+
+// ReservedRegions are the regions of memory that the COSMAC VIP interpreter
+// uses for itself. Programs that extend into them work here, but won't on
+// the real hardware.
+var ReservedRegions = []Region{
+	{"stack", 0xEA0, 0xEFF},
+	{"display refresh", 0xF00, 0xFFF},
+}
+
+// LayoutWarning describes a part of a program that overlaps a reserved
+// region of memory.
+type LayoutWarning struct {
+	// The region that's overlapped.
+	Region Region
+
+	// The first and last addresses of the program within the region,
+	// assuming the program is loaded at 0x200.
+	Start, End uint16
+}
+
+// String returns a description of the overlap.
+func (w LayoutWarning) String() string {
+	return fmt.Sprintf("0x%03X-0x%03X overlaps the %s area (0x%03X-0x%03X)", w.Start, w.End, w.Region.Name, w.Region.Start, w.Region.End)
+}
+
+// ValidateLayout reports the ReservedRegions that a program would overlap
+// when loaded at 0x200.
+func ValidateLayout(p []byte) []LayoutWarning {
+	var warnings []LayoutWarning
+
+	start, end := ProgramStart, ProgramStart+len(p)-1
+	for _, r := range ReservedRegions {
+		if end < int(r.Start) || start > int(r.End) {
+			continue
+		}
+
+		w := LayoutWarning{Region: r, Start: r.Start, End: r.End}
+		if start > int(r.Start) {
+			w.Start = uint16(start)
+		}
+		if end < int(r.End) {
+			w.End = uint16(end)
+		}
+		warnings = append(warnings, w)
+	}
+
+	return warnings
+}
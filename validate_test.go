@@ -0,0 +1,79 @@
+package chip8
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	p := []byte{
+		0x00, 0xE0, // CLS
+		0x01, 0x23, // SYS 0x123
+		0x00, 0xFF, // HIGH
+		0x80, 0x18, // Unknown ALU opcode
+		0xF0, 0x01, // PLANE 0
+		0xD0, 0x15, // DRW V0, V1, 5
+		0x00, 0xEE, // RET
+	}
+
+	issues := Validate(p, Quirks{})
+
+	want := []ValidationIssue{
+		{0x202, 0x0123, "SYS 0x123 calls a machine code routine"},
+		{0x204, 0x00FF, "SUPER-CHIP instruction"},
+		{0x206, 0x8018, "unknown opcode"},
+		{0x208, 0xF001, "XO-CHIP instruction"},
+	}
+
+	if len(issues) != len(want) {
+		t.Fatalf("Validate() => %v; want %v", issues, want)
+	}
+
+	for i := range want {
+		if issues[i] != want[i] {
+			t.Errorf("issues[%d] => %v; want %v", i, issues[i], want[i])
+		}
+	}
+
+	if issues := Validate(loadProgram(t, "pong.ch8"), Quirks{}); len(issues) != 0 {
+		t.Errorf("Expected pong.ch8 to be valid, got %v", issues)
+	}
+}
+
+func TestValidateLayout(t *testing.T) {
+	tests := []struct {
+		size int
+		want []LayoutWarning
+	}{
+		{0, nil},
+		{0xCA0, nil},
+		{0xCA1, []LayoutWarning{
+			{ReservedRegions[0], 0xEA0, 0xEA0},
+		}},
+		{0xD10, []LayoutWarning{
+			{ReservedRegions[0], 0xEA0, 0xEFF},
+			{ReservedRegions[1], 0xF00, 0xF0F},
+		}},
+		{0xE00, []LayoutWarning{
+			{ReservedRegions[0], 0xEA0, 0xEFF},
+			{ReservedRegions[1], 0xF00, 0xFFF},
+		}},
+	}
+
+	for _, tt := range tests {
+		warnings := ValidateLayout(make([]byte, tt.size))
+
+		if len(warnings) != len(tt.want) {
+			t.Errorf("ValidateLayout(%d bytes) => %v; want %v", tt.size, warnings, tt.want)
+			continue
+		}
+
+		for i := range tt.want {
+			if warnings[i] != tt.want[i] {
+				t.Errorf("ValidateLayout(%d bytes)[%d] => %v; want %v", tt.size, i, warnings[i], tt.want[i])
+			}
+		}
+	}
+
+	w := LayoutWarning{ReservedRegions[1], 0xF00, 0xF0F}
+	if got, want := w.String(), "0xF00-0xF0F overlaps the display refresh area (0xF00-0xFFF)"; got != want {
+		t.Errorf("String() => %q; want %q", got, want)
+	}
+}